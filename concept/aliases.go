@@ -0,0 +1,138 @@
+package concept
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// AliasOrder is the order in which the normalised aliases of a concept are listed.
+type AliasOrder string
+
+const (
+	// AlphabeticalAliasOrder sorts the aliases case-insensitively, so the order does not depend on the sources.
+	AlphabeticalAliasOrder AliasOrder = "alphabetical"
+	// SourceAliasOrder keeps the aliases in the order they first appear in merge order.
+	SourceAliasOrder AliasOrder = "source"
+)
+
+// AliasRule controls how the aliases of a concept are normalised.
+type AliasRule struct {
+	// ExcludePrefLabel drops the aliases that are equivalent to the prefLabel of the concept.
+	ExcludePrefLabel bool `json:"excludePrefLabel"`
+	// CaseSensitive keeps aliases that only differ in case, which are otherwise treated as duplicates.
+	CaseSensitive bool       `json:"caseSensitive"`
+	Order         AliasOrder `json:"order"`
+}
+
+// AliasRules holds the alias rule for each concept type, falling back to Default for types that are not listed.
+type AliasRules struct {
+	Default AliasRule            `json:"default"`
+	Types   map[string]AliasRule `json:"types"`
+}
+
+// DefaultAliasRules keeps the prefLabel among the aliases and folds case-insensitive duplicates into an alphabetical list.
+func DefaultAliasRules() AliasRules {
+	return AliasRules{
+		Default: AliasRule{Order: AlphabeticalAliasOrder},
+		Types:   map[string]AliasRule{},
+	}
+}
+
+// LoadAliasRules reads alias rules from the JSON file at path. An empty path returns DefaultAliasRules.
+func LoadAliasRules(path string) (AliasRules, error) {
+	rules := DefaultAliasRules()
+	if path == "" {
+		return rules, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return AliasRules{}, fmt.Errorf("failed to read alias rules: %w", err)
+	}
+	if err = json.Unmarshal(data, &rules); err != nil {
+		return AliasRules{}, fmt.Errorf("failed to parse alias rules: %w", err)
+	}
+	if rules.Types == nil {
+		rules.Types = map[string]AliasRule{}
+	}
+	return rules, rules.Validate()
+}
+
+// Validate checks that every rule uses a known order.
+func (r AliasRules) Validate() error {
+	if err := r.Default.validate(); err != nil {
+		return fmt.Errorf("alias rules: default %w", err)
+	}
+	for conceptType, rule := range r.Types {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("alias rules: type %q %w", conceptType, err)
+		}
+	}
+	return nil
+}
+
+func (r AliasRule) validate() error {
+	switch r.Order {
+	case "", AlphabeticalAliasOrder, SourceAliasOrder:
+		return nil
+	}
+	return fmt.Errorf("uses unknown order %q", r.Order)
+}
+
+func (r AliasRules) forType(conceptType string) AliasRule {
+	if rule, ok := r.Types[conceptType]; ok {
+		return rule
+	}
+	return r.Default
+}
+
+// normalise trims the aliases, converts them to their composed Unicode form and removes empty values and duplicates.
+// Aliases are duplicates when they have the same compatibility form, ignoring case unless the rule is case sensitive.
+// Of a set of duplicates the form equivalent to prefLabel is kept, otherwise the one that appears last in merge order,
+// which is the one supplied by the source closest to the canonical concept.
+func (r AliasRule) normalise(aliases []string, prefLabel string) []string {
+	prefLabel = norm.NFC.String(strings.TrimSpace(prefLabel))
+	prefLabelKey := r.aliasKey(prefLabel)
+
+	var keys []string
+	preferred := map[string]string{}
+	for _, a := range aliases {
+		a = norm.NFC.String(strings.TrimSpace(a))
+		if a == "" {
+			continue
+		}
+		key := r.aliasKey(a)
+		if key == prefLabelKey {
+			if r.ExcludePrefLabel {
+				continue
+			}
+			a = prefLabel
+		}
+		if _, seen := preferred[key]; !seen {
+			keys = append(keys, key)
+		}
+		preferred[key] = a
+	}
+
+	if r.Order != SourceAliasOrder {
+		sort.Strings(keys)
+	}
+
+	var out []string
+	for _, key := range keys {
+		out = append(out, preferred[key])
+	}
+	return out
+}
+
+func (r AliasRule) aliasKey(alias string) string {
+	key := norm.NFKC.String(alias)
+	if !r.CaseSensitive {
+		key = strings.ToLower(key)
+	}
+	return key
+}
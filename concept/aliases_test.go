@@ -0,0 +1,126 @@
+package concept
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAliasRule_Normalise(t *testing.T) {
+	testCases := map[string]struct {
+		rule      AliasRule
+		aliases   []string
+		prefLabel string
+		expected  []string
+	}{
+		"Sorted regardless of input order": {
+			rule:     AliasRule{Order: AlphabeticalAliasOrder},
+			aliases:  []string{"Steam Plc", "castletown Thermostats", "Strix Group"},
+			expected: []string{"castletown Thermostats", "Steam Plc", "Strix Group"},
+		},
+		"Whitespace trimmed and empty aliases dropped": {
+			rule:     AliasRule{},
+			aliases:  []string{"  Strix Group ", "", "   ", "Strix Group"},
+			expected: []string{"Strix Group"},
+		},
+		"Unicode equivalent forms folded": {
+			rule:     AliasRule{},
+			aliases:  []string{"Socie\u0301te\u0301 Ge\u0301ne\u0301rale", "Soci\u00e9t\u00e9 G\u00e9n\u00e9rale", "\ufb01nance", "finance"},
+			expected: []string{"finance", "Soci\u00e9t\u00e9 G\u00e9n\u00e9rale"},
+		},
+		"Case-insensitive duplicates keep the prefLabel casing": {
+			rule:      AliasRule{},
+			aliases:   []string{"STRIX GROUP PLC", "Strix Group Plc", "strix group plc"},
+			prefLabel: "Strix Group Plc",
+			expected:  []string{"Strix Group Plc"},
+		},
+		"Case-insensitive duplicates keep the last casing in merge order": {
+			rule:     AliasRule{},
+			aliases:  []string{"STRIX GROUP", "Strix group", "Strix Group"},
+			expected: []string{"Strix Group"},
+		},
+		"Case sensitive": {
+			rule:     AliasRule{CaseSensitive: true},
+			aliases:  []string{"FT", "Ft", "FT"},
+			expected: []string{"FT", "Ft"},
+		},
+		"PrefLabel excluded": {
+			rule:      AliasRule{ExcludePrefLabel: true},
+			aliases:   []string{"Strix Group", "STRIX GROUP PLC", "Strix Group Plc"},
+			prefLabel: "Strix Group Plc",
+			expected:  []string{"Strix Group"},
+		},
+		"Source order": {
+			rule:     AliasRule{Order: SourceAliasOrder},
+			aliases:  []string{"Strix Group", "Steam Plc", "strix group", "Castletown Thermostats"},
+			expected: []string{"strix group", "Steam Plc", "Castletown Thermostats"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.rule.normalise(tc.aliases, tc.prefLabel))
+		})
+	}
+}
+
+func TestLoadAliasRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alias-rules")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rules, err := LoadAliasRules("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultAliasRules(), rules)
+
+	path := filepath.Join(dir, "rules.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"types": {"Person": {"excludePrefLabel": true, "order": "source"}}}`), 0600))
+	rules, err = LoadAliasRules(path)
+	assert.NoError(t, err)
+	assert.Equal(t, AliasRule{ExcludePrefLabel: true, Order: SourceAliasOrder}, rules.forType("Person"))
+	assert.Equal(t, AliasRule{Order: AlphabeticalAliasOrder}, rules.forType("Brand"))
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"types": {"Person": {"order": "random"}}}`), 0600))
+	_, err = LoadAliasRules(path)
+	assert.EqualError(t, err, `alias rules: type "Person" uses unknown order "random"`)
+
+	_, err = LoadAliasRules(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestAggregateService_GetConcordedConcept_AliasRules(t *testing.T) {
+	svc, s3mock, _, _, _, _, _ := setupTestService(200, payload)
+	s3mock.concepts["a141f50f-31d7-4f89-8143-eec971e54ba8"] = struct {
+		transactionID string
+		concept       s3.Concept
+	}{
+		transactionID: "tid_636",
+		concept: s3.Concept{
+			UUID:      "a141f50f-31d7-4f89-8143-eec971e54ba8",
+			PrefLabel: "Strix Group Plc",
+			Authority: "Smartlogic",
+			AuthValue: "a141f50f-31d7-4f89-8143-eec971e54ba8",
+			Type:      "Organisation",
+			Aliases:   []string{" Strix "},
+		},
+	}
+
+	first, _, err := svc.GetConcordedConcept(context.Background(), "a141f50f-31d7-4f89-8143-eec971e54ba8", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Castletown Thermostats", "Steam Plc", "Strix", "Strix Group", "Strix Group Plc"}, first.Aliases)
+	for i := 0; i < 5; i++ {
+		again, _, err := svc.GetConcordedConcept(context.Background(), "a141f50f-31d7-4f89-8143-eec971e54ba8", "")
+		assert.NoError(t, err)
+		assert.Equal(t, first.Aliases, again.Aliases)
+	}
+
+	svc.aliasRules.Types["PublicCompany"] = AliasRule{ExcludePrefLabel: true, Order: SourceAliasOrder}
+	c, _, err := svc.GetConcordedConcept(context.Background(), "a141f50f-31d7-4f89-8143-eec971e54ba8", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Strix Group", "Castletown Thermostats", "Steam Plc", "Strix"}, c.Aliases)
+}
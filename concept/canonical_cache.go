@@ -0,0 +1,87 @@
+package concept
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// canonicalCache remembers the prefUUID that concept UUIDs resolve to, so that concepts referenced by many
+// others are not looked up again for every aggregation. It holds at most size entries, evicting the least
+// recently used, and entries expire after ttl so that concordance changes made elsewhere are picked up.
+// A size of zero or less disables caching.
+type canonicalCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type canonicalCacheEntry struct {
+	UUID      string
+	canonical string
+	expires   time.Time
+}
+
+func newCanonicalCache(size int, ttl time.Duration) *canonicalCache {
+	return &canonicalCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+func (c *canonicalCache) get(UUID string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[UUID]
+	if !ok {
+		return "", false
+	}
+	entry := el.Value.(*canonicalCacheEntry)
+	if c.now().After(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, UUID)
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return entry.canonical, true
+}
+
+func (c *canonicalCache) put(UUID string, canonical string) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(c.ttl)
+	if el, ok := c.entries[UUID]; ok {
+		entry := el.Value.(*canonicalCacheEntry)
+		entry.canonical = canonical
+		entry.expires = expires
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[UUID] = c.order.PushFront(&canonicalCacheEntry{UUID: UUID, canonical: canonical, expires: expires})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*canonicalCacheEntry).UUID)
+	}
+}
+
+// forget drops the entries of concepts whose concordances may have changed.
+func (c *canonicalCache) forget(UUIDs ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, UUID := range UUIDs {
+		if el, ok := c.entries[UUID]; ok {
+			c.order.Remove(el)
+			delete(c.entries, UUID)
+		}
+	}
+}
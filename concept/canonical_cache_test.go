@@ -0,0 +1,42 @@
+package concept
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalCache(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newCanonicalCache(2, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cache.put("a", "canonical-a")
+	cache.put("b", "canonical-b")
+	canonical, ok := cache.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "canonical-a", canonical)
+
+	cache.put("c", "canonical-c")
+	_, ok = cache.get("b")
+	assert.False(t, ok, "least recently used entry should be evicted")
+	_, ok = cache.get("a")
+	assert.True(t, ok)
+
+	cache.forget("a")
+	_, ok = cache.get("a")
+	assert.False(t, ok)
+
+	now = now.Add(2 * time.Minute)
+	_, ok = cache.get("c")
+	assert.False(t, ok, "entry should expire")
+	assert.Empty(t, cache.entries)
+}
+
+func TestCanonicalCache_Disabled(t *testing.T) {
+	cache := newCanonicalCache(0, time.Minute)
+	cache.put("a", "canonical-a")
+	_, ok := cache.get("a")
+	assert.False(t, ok)
+}
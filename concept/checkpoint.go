@@ -0,0 +1,82 @@
+package concept
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/sqs"
+)
+
+// checkpointStore keeps, by prefUUID and transaction ID, how far through the sink pipeline a concept got before
+// a sink failed. A retry of the message resumes from the failed sink with the changes Neo4j reported the first
+// time, which a second Neo4j write would no longer report. Checkpoints expire after ttl, and a ttl of zero or
+// less disables checkpointing. The store is in memory, so it only helps retries received by the same instance.
+type checkpointStore struct {
+	mu          sync.Mutex
+	ttl         time.Duration
+	now         func() time.Time
+	checkpoints map[string]checkpoint
+}
+
+type checkpoint struct {
+	completed []string
+	changes   sqs.ConceptChanges
+	expires   time.Time
+}
+
+func newCheckpointStore(ttl time.Duration) *checkpointStore {
+	return &checkpointStore{
+		ttl:         ttl,
+		now:         time.Now,
+		checkpoints: map[string]checkpoint{},
+	}
+}
+
+// restore fills in the progress recorded for w, returning false when there is none.
+func (c *checkpointStore) restore(w *SinkWrite) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := checkpointKey(w)
+	cp, ok := c.checkpoints[key]
+	if !ok {
+		return false
+	}
+	if c.now().After(cp.expires) {
+		delete(c.checkpoints, key)
+		return false
+	}
+	w.Completed = append([]string{}, cp.completed...)
+	w.Changes = cp.changes
+	return true
+}
+
+// save records the progress of w, unless it has not completed any sink.
+func (c *checkpointStore) save(w *SinkWrite) {
+	if c.ttl <= 0 || len(w.Completed) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for key, cp := range c.checkpoints {
+		if now.After(cp.expires) {
+			delete(c.checkpoints, key)
+		}
+	}
+	c.checkpoints[checkpointKey(w)] = checkpoint{
+		completed: append([]string{}, w.Completed...),
+		changes:   w.Changes,
+		expires:   now.Add(c.ttl),
+	}
+}
+
+// clear drops the progress of w once it has been through the whole pipeline.
+func (c *checkpointStore) clear(w *SinkWrite) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.checkpoints, checkpointKey(w))
+}
+
+func checkpointKey(w *SinkWrite) string {
+	return w.Concept.PrefUUID + "/" + w.TransactionID
+}
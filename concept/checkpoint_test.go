@@ -0,0 +1,77 @@
+package concept
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpointStore(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := newCheckpointStore(time.Minute)
+	store.now = func() time.Time { return now }
+	changes := sqs.ConceptChanges{UpdatedIds: []string{"a"}}
+
+	w := &SinkWrite{Concept: ConcordedConcept{PrefUUID: "a"}, TransactionID: "tid_1"}
+	assert.False(t, store.restore(w))
+
+	store.save(w)
+	assert.False(t, store.restore(w), "nothing completed, so there is nothing to resume")
+
+	w.Completed = []string{Neo4jSink, VarnishSink}
+	w.Changes = changes
+	store.save(w)
+
+	retry := &SinkWrite{Concept: ConcordedConcept{PrefUUID: "a"}, TransactionID: "tid_1"}
+	assert.True(t, store.restore(retry))
+	assert.Equal(t, []string{Neo4jSink, VarnishSink}, retry.Completed)
+	assert.Equal(t, changes, retry.Changes)
+
+	updated := &SinkWrite{Concept: ConcordedConcept{PrefUUID: "a"}, TransactionID: "tid_2"}
+	assert.False(t, store.restore(updated), "a new transaction should go through the whole pipeline")
+
+	store.clear(retry)
+	assert.False(t, store.restore(retry))
+
+	store.save(w)
+	now = now.Add(2 * time.Minute)
+	assert.False(t, store.restore(w), "checkpoint should expire")
+}
+
+func TestCheckpointStore_Disabled(t *testing.T) {
+	store := newCheckpointStore(0)
+	w := &SinkWrite{Concept: ConcordedConcept{PrefUUID: "a"}, TransactionID: "tid_1", Completed: []string{Neo4jSink}}
+	store.save(w)
+	assert.False(t, store.restore(&SinkWrite{Concept: w.Concept, TransactionID: w.TransactionID}))
+}
+
+func TestAggregateService_ProcessMessage_RetryResumesFromFailedSink(t *testing.T) {
+	svc, _, _, eventsQueue, kinesis, _, _ := setupTestService(200, payload)
+	kinesis.err = errors.New("stream unavailable")
+
+	err := svc.ProcessMessage(context.Background(), "28090964-9997-4bc2-9638-7a11135aaff9", "")
+	assert.EqualError(t, err, "stream unavailable")
+	assert.Len(t, eventsQueue.eventList, 3)
+
+	// Neo4j already has the concept, so it would report no changes
+	kinesis.err = nil
+	client := svc.httpClient.(*mockHTTPClient)
+	client.resp = `{"events": [], "updatedIDs": []}`
+	client.called = nil
+
+	err = svc.ProcessMessage(context.Background(), "28090964-9997-4bc2-9638-7a11135aaff9", "")
+	assert.NoError(t, err)
+	assert.Empty(t, client.called, "completed sinks should not be sent the concept again")
+	assert.Len(t, eventsQueue.eventList, 3, "events should not be sent again")
+	assert.Equal(t, []string{`["28090964-9997-4bc2-9638-7a11135aaff9","34a571fb-d779-4610-a7ba-2e127676db4d"]`}, kinesis.records)
+
+	// Once through the whole pipeline the next update starts from the beginning again
+	err = svc.ProcessMessage(context.Background(), "28090964-9997-4bc2-9638-7a11135aaff9", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"concepts-rw-neo4j/people/28090964-9997-4bc2-9638-7a11135aaff9"}, client.called)
+	assert.Len(t, kinesis.records, 1)
+}
@@ -10,9 +10,15 @@ import (
 type mockConcordancesClient struct {
 	concordances map[string][]concordances.ConcordanceRecord
 	err          error
+	uuidErrs     map[string]error
+	calls        []string
 }
 
 func (d *mockConcordancesClient) GetConcordance(ctx context.Context, uuid string, bookmark string) ([]concordances.ConcordanceRecord, error) {
+	d.calls = append(d.calls, uuid)
+	if err, ok := d.uuidErrs[uuid]; ok {
+		return nil, err
+	}
 	if cons, ok := d.concordances[uuid]; ok {
 		return cons, d.err
 	}
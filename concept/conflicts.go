@@ -0,0 +1,55 @@
+package concept
+
+import "github.com/Financial-Times/aggregate-concept-transformer/s3"
+
+// Conflict records that the sources of a concept supplied different values for a scalar field.
+type Conflict struct {
+	Field  string          `json:"field"`
+	Values []ConflictValue `json:"values"`
+}
+
+// ConflictValue is the value a single source supplied for a conflicting field.
+type ConflictValue struct {
+	Authority  string      `json:"authority"`
+	SourceUUID string      `json:"sourceUUID"`
+	Value      interface{} `json:"value"`
+}
+
+// detectConflicts compares the scalar fields of the secondary sources, which are all sources apart from the
+// last one when the concept has a primary authority. Empty values are not treated as a disagreement, and
+// neither are fields the policy only ever takes from the primary concept.
+func detectConflicts(sources []s3.Concept, hasPrimary bool, policy MergePolicy) []Conflict {
+	secondaries := sources
+	if hasPrimary && len(sources) > 0 {
+		secondaries = sources[:len(sources)-1]
+	}
+
+	var conflicts []Conflict
+	for _, f := range mergeFields {
+		if f.kind == listField || policy.Fields[f.name].Strategy == PrimaryOnlyStrategy {
+			continue
+		}
+		var values []ConflictValue
+		distinct := map[interface{}]bool{}
+		for _, s := range secondaries {
+			v := f.value(s)
+			if isEmptyValue(v) {
+				continue
+			}
+			values = append(values, ConflictValue{Authority: s.Authority, SourceUUID: s.UUID, Value: v})
+			distinct[v] = true
+		}
+		if len(distinct) > 1 {
+			conflicts = append(conflicts, Conflict{Field: f.name, Values: values})
+		}
+	}
+	return conflicts
+}
+
+func conflictingFields(conflicts []Conflict) []string {
+	fields := make([]string, len(conflicts))
+	for i, c := range conflicts {
+		fields[i] = c.Field
+	}
+	return fields
+}
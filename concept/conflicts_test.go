@@ -0,0 +1,74 @@
+package concept
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectConflicts(t *testing.T) {
+	factset := s3.Concept{UUID: "factset", Authority: "FACTSET", PrefLabel: "Strix Group Plc", YearFounded: 1951, CountryCode: "GB", LeiCode: "213800KZEW5W6BZMNT62"}
+	tme := s3.Concept{UUID: "tme", Authority: "TME", PrefLabel: "Strix", YearFounded: 1952, CountryCode: "GB", TradeNames: []string{"Strix Kettles"}}
+	wikidata := s3.Concept{UUID: "wikidata", Authority: "Wikidata", CountryCode: "IM"}
+	smartlogic := s3.Concept{UUID: "smartlogic", Authority: "Smartlogic", PrefLabel: "Strix", YearFounded: 1960}
+
+	testCases := map[string]struct {
+		sources    []s3.Concept
+		hasPrimary bool
+		expected   []Conflict
+	}{
+		"Secondary sources disagree": {
+			sources:    []s3.Concept{factset, tme, wikidata, smartlogic},
+			hasPrimary: true,
+			expected: []Conflict{
+				{
+					Field: "countryCode",
+					Values: []ConflictValue{
+						{Authority: "FACTSET", SourceUUID: "factset", Value: "GB"},
+						{Authority: "TME", SourceUUID: "tme", Value: "GB"},
+						{Authority: "Wikidata", SourceUUID: "wikidata", Value: "IM"},
+					},
+				},
+				{
+					Field: "yearFounded",
+					Values: []ConflictValue{
+						{Authority: "FACTSET", SourceUUID: "factset", Value: 1951},
+						{Authority: "TME", SourceUUID: "tme", Value: 1952},
+					},
+				},
+			},
+		},
+		"Primary source is not compared": {
+			sources:    []s3.Concept{factset, smartlogic},
+			hasPrimary: true,
+		},
+		"All sources are compared without a primary": {
+			sources: []s3.Concept{factset, wikidata},
+			expected: []Conflict{
+				{
+					Field: "countryCode",
+					Values: []ConflictValue{
+						{Authority: "FACTSET", SourceUUID: "factset", Value: "GB"},
+						{Authority: "Wikidata", SourceUUID: "wikidata", Value: "IM"},
+					},
+				},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, detectConflicts(tc.sources, tc.hasPrimary, DefaultMergePolicy()))
+		})
+	}
+}
+
+func TestAggregateService_Aggregate_Conflicts(t *testing.T) {
+	svc, _, _, _, _, _, _ := setupTestService(200, payload)
+
+	result, err := svc.Aggregate(context.Background(), "a141f50f-31d7-4f89-8143-eec971e54ba8", "")
+	assert.NoError(t, err)
+	assert.Empty(t, result.Conflicts)
+}
@@ -0,0 +1,87 @@
+package concept
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/sqs"
+)
+
+const (
+	// FailureTransient is the class of failures that may succeed when the concept update is retried.
+	FailureTransient = "transient"
+	// FailurePermanent is the class of failures caused by the source data, which fail on every retry.
+	FailurePermanent = "permanent"
+
+	maxAttemptHistory = 10
+	attemptHistoryTTL = 24 * time.Hour
+)
+
+// classifyFailure returns FailurePermanent for concepts that are invalid, whose canonical concept is missing
+// from S3 or whose concordance has no single canonical concept, and FailureTransient for anything else.
+// The concepts related to the one being processed that cannot be aggregated are skipped by the supersession and
+// hierarchy walks, so their failures never reach here.
+func classifyFailure(err error) string {
+	var validationErr *ValidationError
+	var notFound *canonicalNotFoundError
+	var noCanonical *noCanonicalConceptError
+	if errors.As(err, &validationErr) || errors.As(err, &notFound) || errors.As(err, &noCanonical) {
+		return FailurePermanent
+	}
+	return FailureTransient
+}
+
+// attemptLog keeps the failed attempts to process each concept update, so that they can be described when
+// the update is dead-lettered. Only the last maxAttemptHistory attempts of an update are kept, and updates
+// that have not failed for attemptHistoryTTL are forgotten.
+type attemptLog struct {
+	mu       sync.Mutex
+	now      func() time.Time
+	attempts map[string][]sqs.Attempt
+}
+
+func newAttemptLog() *attemptLog {
+	return &attemptLog{
+		now:      time.Now,
+		attempts: map[string][]sqs.Attempt{},
+	}
+}
+
+// record adds a failed attempt to process n and returns every attempt recorded for it.
+func (l *attemptLog) record(n sqs.ConceptUpdate, class string, err error) []sqs.Attempt {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	for key, attempts := range l.attempts {
+		if now.Sub(attempts[len(attempts)-1].Time) > attemptHistoryTTL {
+			delete(l.attempts, key)
+		}
+	}
+	key := attemptKey(n)
+	attempts := append(l.attempts[key], sqs.Attempt{
+		Time:         now,
+		ReceiveCount: n.ReceiveCount,
+		ErrorClass:   class,
+		Error:        err.Error(),
+	})
+	if len(attempts) > maxAttemptHistory {
+		attempts = attempts[len(attempts)-maxAttemptHistory:]
+	}
+	l.attempts[key] = attempts
+	return append([]sqs.Attempt{}, attempts...)
+}
+
+// forget drops the attempts of n once it has left the queue.
+func (l *attemptLog) forget(n sqs.ConceptUpdate) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.attempts, attemptKey(n))
+}
+
+func attemptKey(n sqs.ConceptUpdate) string {
+	if n.MessageID != "" {
+		return n.MessageID
+	}
+	return n.UUID + "/" + n.Bookmark
+}
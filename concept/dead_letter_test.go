@@ -0,0 +1,196 @@
+package concept
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/concordances"
+	"github.com/Financial-Times/aggregate-concept-transformer/sqs"
+	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockDeadLetterClient struct {
+	letters []sqs.DeadLetter
+	err     error
+}
+
+func (d *mockDeadLetterClient) SendDeadLetter(ctx context.Context, letter sqs.DeadLetter) error {
+	if d.err != nil {
+		return d.err
+	}
+	d.letters = append(d.letters, letter)
+	return nil
+}
+
+func (d *mockDeadLetterClient) Healthcheck() fthealth.Check {
+	return fthealth.Check{
+		Checker: func() (string, error) {
+			return "", nil
+		},
+	}
+}
+
+func TestClassifyFailure(t *testing.T) {
+	testCases := map[string]struct {
+		err      error
+		expected string
+	}{
+		"Validation error": {
+			err:      &ValidationError{UUID: "a", Type: "Topic"},
+			expected: FailurePermanent,
+		},
+		"Canonical concept missing": {
+			err:      &canonicalNotFoundError{UUID: "a"},
+			expected: FailurePermanent,
+		},
+		"No single canonical concept": {
+			err:      &noCanonicalConceptError{cause: errors.New("more than 1 Smartlogic primary authority")},
+			expected: FailurePermanent,
+		},
+		"Writer unavailable": {
+			err:      errors.New("Request to concepts-rw-neo4j/people/a returned status: 503; skipping a"),
+			expected: FailureTransient,
+		},
+		"Timeout": {
+			err:      context.DeadlineExceeded,
+			expected: FailureTransient,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, classifyFailure(tc.err))
+		})
+	}
+}
+
+func TestAttemptLog(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	log := newAttemptLog()
+	log.now = func() time.Time { return now }
+	update := sqs.ConceptUpdate{UUID: "a", MessageID: "message-a"}
+
+	var attempts []sqs.Attempt
+	for i := 1; i <= maxAttemptHistory+2; i++ {
+		update.ReceiveCount = i
+		attempts = log.record(update, FailureTransient, fmt.Errorf("attempt %d", i))
+	}
+	assert.Len(t, attempts, maxAttemptHistory)
+	assert.Equal(t, 3, attempts[0].ReceiveCount, "oldest attempts should be dropped")
+	assert.Equal(t, "attempt 12", attempts[maxAttemptHistory-1].Error)
+
+	other := sqs.ConceptUpdate{UUID: "b", MessageID: "message-b"}
+	assert.Len(t, log.record(other, FailureTransient, errors.New("failed")), 1)
+
+	log.forget(update)
+	assert.Len(t, log.record(update, FailureTransient, errors.New("failed")), 1)
+
+	now = now.Add(attemptHistoryTTL + time.Minute)
+	log.record(update, FailureTransient, errors.New("failed"))
+	assert.NotContains(t, log.attempts, "message-b", "updates that stopped failing should be forgotten")
+}
+
+func TestAggregateService_ProcessConceptUpdate_DeadLetters(t *testing.T) {
+	const UUID = "28090964-9997-4bc2-9638-7a11135aaff9"
+	unavailable := errors.New("concordances unavailable")
+	testCases := map[string]struct {
+		missingCanonical bool
+		concordancesErr  error
+		maxReceiveCount  int
+		receiveCounts    []int
+		deadLetterErr    error
+		expectedErr      string
+		expectedClass    string
+		expectedAttempts int
+	}{
+		"Permanent failure dead-lettered straight away": {
+			missingCanonical: true,
+			receiveCounts:    []int{1},
+			expectedClass:    FailurePermanent,
+			expectedAttempts: 1,
+		},
+		"Transient failure retried": {
+			concordancesErr: unavailable,
+			maxReceiveCount: 3,
+			receiveCounts:   []int{1, 2},
+			expectedErr:     "concordances unavailable",
+		},
+		"Transient failure dead-lettered at max receive count": {
+			concordancesErr:  unavailable,
+			maxReceiveCount:  3,
+			receiveCounts:    []int{1, 2, 3},
+			expectedClass:    FailureTransient,
+			expectedAttempts: 3,
+		},
+		"Transient failure retried without max receive count": {
+			concordancesErr: unavailable,
+			receiveCounts:   []int{1, 2, 3, 4, 5},
+			expectedErr:     "concordances unavailable",
+		},
+		"Left on the queue when the dead-letter queue fails": {
+			missingCanonical: true,
+			receiveCounts:    []int{1},
+			deadLetterErr:    errors.New("queue unavailable"),
+			expectedErr:      "error sending message to dead-letter SQS queue: queue unavailable",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			svc, _, conceptsQueue, _, _, _, _ := setupTestService(200, payload)
+			deadLetters := &mockDeadLetterClient{err: tc.deadLetterErr}
+			WithDeadLetterQueue(deadLetters)(svc)
+			WithMaxReceiveCount(tc.maxReceiveCount)(svc)
+			concordancesMock := svc.concordances.(*mockConcordancesClient)
+			if tc.missingCanonical {
+				concordancesMock.concordances[UUID] = []concordances.ConcordanceRecord{{UUID: "missing", Authority: "Smartlogic"}}
+			}
+			if tc.concordancesErr != nil {
+				concordancesMock.uuidErrs = map[string]error{UUID: tc.concordancesErr}
+			}
+			receiptHandle := "dead-letter"
+			conceptsQueue.conceptsQueue[receiptHandle] = UUID
+
+			var err error
+			for _, count := range tc.receiveCounts {
+				err = svc.processConceptUpdate(context.Background(), sqs.ConceptUpdate{
+					UUID:          UUID,
+					Bookmark:      "bookmark",
+					ReceiptHandle: &receiptHandle,
+					MessageID:     "message",
+					ReceiveCount:  count,
+				})
+			}
+
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				assert.Contains(t, conceptsQueue.Queue(), receiptHandle)
+				assert.Empty(t, deadLetters.letters)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotContains(t, conceptsQueue.Queue(), receiptHandle)
+			if assert.Len(t, deadLetters.letters, 1) {
+				letter := deadLetters.letters[0]
+				assert.Equal(t, UUID, letter.UUID)
+				assert.Equal(t, "bookmark", letter.Bookmark)
+				assert.Equal(t, "message", letter.MessageID)
+				assert.Equal(t, tc.expectedClass, letter.ErrorClass)
+				assert.Equal(t, tc.receiveCounts[len(tc.receiveCounts)-1], letter.ReceiveCount)
+				assert.Len(t, letter.Attempts, tc.expectedAttempts)
+				assert.Equal(t, letter.Error, letter.Attempts[len(letter.Attempts)-1].Error)
+			}
+			assert.Empty(t, svc.attempts.attempts, "attempts of dead-lettered updates should be forgotten")
+		})
+	}
+}
+
+func TestAggregateService_Healthchecks_DeadLetterQueue(t *testing.T) {
+	svc, _, _, _, _, _, _ := setupTestService(200, payload)
+	WithDeadLetterQueue(&mockDeadLetterClient{})(svc)
+	assert.Equal(t, 8, len(svc.Healthchecks()))
+}
@@ -0,0 +1,272 @@
+package concept
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	logger "github.com/Financial-Times/go-logger"
+)
+
+const defaultBulkFlushInterval = 100 * time.Millisecond
+
+// bulkItem is a concept in a request to the bulk endpoint of the Elasticsearch writer.
+type bulkItem struct {
+	Type          string           `json:"type"`
+	UUID          string           `json:"uuid"`
+	TransactionID string           `json:"transactionID"`
+	Concept       ConcordedConcept `json:"concept"`
+}
+
+// bulkRequest is the body of a request to the bulk endpoint of the Elasticsearch writer. The endpoint is not
+// part of the documented API of the writer yet; see "Bulk writes to Elasticsearch" in the README for the contract
+// this service relies on.
+type bulkRequest struct {
+	Items []bulkItem `json:"items"`
+}
+
+// bulkItemResult is the outcome of writing a bulkItem, in the same position of the response as the item was in
+// the request.
+type bulkItemResult struct {
+	UUID   string `json:"uuid"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// bulkResponse is the reply of the bulk endpoint, with a result for each item of the bulkRequest.
+type bulkResponse struct {
+	Items []bulkItemResult `json:"items"`
+}
+
+type pendingBulkItem struct {
+	item   bulkItem
+	result chan error
+}
+
+// bulkWriter collects the concepts written by concurrent workers and sends them to the bulk endpoint of the
+// Elasticsearch writer in batches. A batch is sent once it holds maxItems concepts, or flushInterval after its
+// first concept was added. Each concept gets the result of its own item back. A concept has at most one item in
+// flight, and its later items wait for it, so that an older write cannot overwrite a newer one.
+type bulkWriter struct {
+	client        httpClient
+	url           string
+	maxItems      int
+	flushInterval time.Duration
+	timeout       time.Duration
+	pending       chan pendingBulkItem
+	sent          chan []pendingBulkItem
+	queue         []pendingBulkItem
+	inFlight      map[string]bool
+}
+
+func newBulkWriter(client httpClient, address string, maxItems int, flushInterval time.Duration, timeout time.Duration) *bulkWriter {
+	if flushInterval <= 0 {
+		flushInterval = defaultBulkFlushInterval
+	}
+	return &bulkWriter{
+		client:        client,
+		url:           bulkURL(address),
+		maxItems:      maxItems,
+		flushInterval: flushInterval,
+		timeout:       timeout,
+		pending:       make(chan pendingBulkItem, maxItems),
+		sent:          make(chan []pendingBulkItem),
+		inFlight:      map[string]bool{},
+	}
+}
+
+// bulkURL returns the bulk endpoint of the writer at address, which may already end with /bulk.
+func bulkURL(address string) string {
+	return strings.TrimSuffix(strings.TrimRight(address, "/"), "/bulk") + "/bulk"
+}
+
+// write adds item to the next batch and waits for its result. A concept whose context is done before the batch
+// is sent may still be written.
+func (b *bulkWriter) write(ctx context.Context, item bulkItem) error {
+	p := pendingBulkItem{item: item, result: make(chan error, 1)}
+	select {
+	case b.pending <- p:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-p.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run collects batches and sends each of them from its own goroutine, so that a slow request does not hold up
+// the batches after it, for the life of the service.
+func (b *bulkWriter) run() {
+	var flush <-chan time.Time
+	for {
+		select {
+		case p := <-b.pending:
+			b.queue = append(b.queue, p)
+		case batch := <-b.sent:
+			for _, p := range batch {
+				delete(b.inFlight, p.item.UUID)
+			}
+		case <-flush:
+			flush = nil
+			b.flush(1)
+		}
+		for b.flush(b.maxItems) {
+		}
+		if batch, _ := b.nextBatch(); flush == nil && len(batch) > 0 {
+			flush = time.After(b.flushInterval)
+		}
+	}
+}
+
+// flush sends the next batch when it holds at least min items, and reports whether it did.
+func (b *bulkWriter) flush(min int) bool {
+	batch, rest := b.nextBatch()
+	if len(batch) == 0 || len(batch) < min {
+		return false
+	}
+	b.queue = rest
+	for _, p := range batch {
+		b.inFlight[p.item.UUID] = true
+	}
+	go func() {
+		b.send(batch)
+		b.sent <- batch
+	}()
+	return true
+}
+
+// nextBatch takes up to maxItems items that can be sent now from the queue, leaving the items of concepts that
+// are in flight or already in the batch. The items left are returned in order.
+func (b *bulkWriter) nextBatch() (batch []pendingBulkItem, rest []pendingBulkItem) {
+	held := map[string]bool{}
+	for _, p := range b.queue {
+		UUID := p.item.UUID
+		if held[UUID] || b.inFlight[UUID] || len(batch) >= b.maxItems {
+			held[UUID] = true
+			rest = append(rest, p)
+			continue
+		}
+		held[UUID] = true
+		batch = append(batch, p)
+	}
+	return batch, rest
+}
+
+// send writes batch and hands each item its result. A failure of the whole request fails every item.
+func (b *bulkWriter) send(batch []pendingBulkItem) {
+	items := make([]bulkItem, len(batch))
+	for i, p := range batch {
+		items[i] = p.item
+	}
+	errs := b.post(items)
+	for i, p := range batch {
+		p.result <- errs[i]
+	}
+}
+
+func (b *bulkWriter) post(items []bulkItem) []error {
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	defer cancel()
+
+	fail := func(err error) []error {
+		logger.WithError(err).WithField("items", len(items)).Error("Bulk write to Elasticsearch failed")
+		errs := make([]error, len(items))
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	body, err := json.Marshal(bulkRequest{Items: items})
+	if err != nil {
+		return fail(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", b.url, bytes.NewReader(body))
+	if err != nil {
+		return fail(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-Id", bulkRequestID(items))
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fail(fmt.Errorf("bulk request to %s returned error: %w", b.url, err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fail(fmt.Errorf("bulk request to %s returned status: %d", b.url, resp.StatusCode))
+	}
+	var results bulkResponse
+	if err = json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return fail(fmt.Errorf("failed to decode response of bulk request to %s: %w", b.url, err))
+	}
+	if len(results.Items) != len(items) {
+		return fail(fmt.Errorf("bulk request to %s returned %d results for %d items", b.url, len(results.Items), len(items)))
+	}
+
+	errs := make([]error, len(items))
+	for i, result := range results.Items {
+		item := items[i]
+		switch result.Status {
+		case http.StatusOK, http.StatusNotModified:
+		case http.StatusNotFound:
+			logger.WithTransactionID(item.TransactionID).WithUUID(item.UUID).Debugf("Elastic search rw cannot handle concept: %s, because it has an unsupported type %s; skipping record", item.UUID, item.Concept.Type)
+		default:
+			errs[i] = fmt.Errorf("bulk write of %s to %s returned status: %d; %s", item.UUID, b.url, result.Status, result.Error)
+			logger.WithTransactionID(item.TransactionID).WithUUID(item.UUID).Errorf("Bulk write returned status: %d", result.Status)
+		}
+	}
+	logger.WithField("items", len(items)).Debug("Bulk write to Elasticsearch finished")
+	return errs
+}
+
+// bulkRequestID returns the distinct transaction IDs of items, in order and separated by commas, so that the
+// logs of the writer can be matched with the updates of the batch.
+func bulkRequestID(items []bulkItem) string {
+	var tids []string
+	seen := map[string]bool{}
+	for _, item := range items {
+		if item.TransactionID != "" && !seen[item.TransactionID] {
+			seen[item.TransactionID] = true
+			tids = append(tids, item.TransactionID)
+		}
+	}
+	return strings.Join(tids, ",")
+}
+
+// elasticsearchBulkSink writes the concepts the routing table sends to Elasticsearch through a bulkWriter. Only the
+// messages of the concepts that failed in a batch are retried.
+type elasticsearchBulkSink struct {
+	writer *bulkWriter
+	routes RoutingTable
+}
+
+func (e *elasticsearchBulkSink) Name() string {
+	return ElasticsearchSink
+}
+
+func (e *elasticsearchBulkSink) Send(ctx context.Context, w *SinkWrite) error {
+	c := w.Concept
+	if !e.routes.writesTo(ElasticsearchSink, c) {
+		return nil
+	}
+	logger.WithTransactionID(w.TransactionID).WithUUID(c.PrefUUID).Debug("Adding concept to elastic search bulk write")
+	return e.writer.write(ctx, bulkItem{Type: e.routes.path(c.Type), UUID: c.PrefUUID, TransactionID: w.TransactionID, Concept: c})
+}
+
+func (e *elasticsearchBulkSink) Plan(w *SinkWrite) SinkPlan {
+	c := w.Concept
+	if !e.routes.writesTo(ElasticsearchSink, c) {
+		return SinkPlan{Skipped: fmt.Sprintf("concepts of type %s are not written to Elasticsearch", c.Type)}
+	}
+	return SinkPlan{
+		Requests: []PlannedRequest{{Method: "POST", URL: e.writer.url, Body: &c}},
+		Note:     fmt.Sprintf("sent as an item of type %s in a batch of up to %d concepts", e.routes.path(c.Type), e.writer.maxItems),
+	}
+}
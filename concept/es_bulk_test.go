@@ -0,0 +1,295 @@
+package concept
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockBulkClient struct {
+	mu         sync.Mutex
+	statusCode int
+	err        error
+	statuses   map[string]int
+	extra      int
+	urls       []string
+	batches    [][]string
+	requestIDs []string
+	// hold delays the requests holding the item of a UUID until its channel is closed, after the UUID has been
+	// sent to held.
+	hold map[string]chan struct{}
+	held chan string
+}
+
+func (m *mockBulkClient) Do(req *http.Request) (*http.Response, error) {
+	var request bulkRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		return nil, err
+	}
+	for _, item := range request.Items {
+		if release, ok := m.hold[item.UUID]; ok {
+			m.held <- item.UUID
+			<-release
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var uuids []string
+	var response bulkResponse
+	for _, item := range request.Items {
+		uuids = append(uuids, item.UUID)
+		status, ok := m.statuses[item.UUID]
+		if !ok {
+			status = http.StatusOK
+		}
+		response.Items = append(response.Items, bulkItemResult{UUID: item.UUID, Status: status, Error: http.StatusText(status)})
+	}
+	for i := 0; i < m.extra; i++ {
+		response.Items = append(response.Items, bulkItemResult{UUID: "extra", Status: http.StatusOK})
+	}
+	m.urls = append(m.urls, req.URL.String())
+	m.requestIDs = append(m.requestIDs, req.Header.Get("X-Request-Id"))
+	m.batches = append(m.batches, uuids)
+	if m.err != nil {
+		return nil, m.err
+	}
+	statusCode := m.statusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	body, _ := json.Marshal(response)
+	return &http.Response{StatusCode: statusCode, Body: ioutil.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func (m *mockBulkClient) Batches() [][]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.batches
+}
+
+// writeAll writes a concept for each of uuids from its own goroutine, returning the result of each.
+func writeAll(writer *bulkWriter, uuids ...string) map[string]error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := map[string]error{}
+	for _, uuid := range uuids {
+		wg.Add(1)
+		go func(uuid string) {
+			defer wg.Done()
+			err := writer.write(context.Background(), bulkItem{Type: "people", UUID: uuid, Concept: ConcordedConcept{PrefUUID: uuid, Type: "Person"}})
+			mu.Lock()
+			results[uuid] = err
+			mu.Unlock()
+		}(uuid)
+	}
+	wg.Wait()
+	return results
+}
+
+func TestBulkWriter_SendsFullBatch(t *testing.T) {
+	client := &mockBulkClient{}
+	writer := newBulkWriter(client, "concept-rw-elasticsearch/bulk", 3, time.Hour, time.Second)
+	go writer.run()
+
+	results := writeAll(writer, "a", "b", "c")
+	assert.Equal(t, map[string]error{"a": nil, "b": nil, "c": nil}, results)
+	if assert.Len(t, client.Batches(), 1, "a full batch should be sent without waiting for the flush interval") {
+		assert.ElementsMatch(t, []string{"a", "b", "c"}, client.Batches()[0])
+	}
+	assert.Equal(t, []string{"concept-rw-elasticsearch/bulk"}, client.urls)
+}
+
+func TestBulkWriter_FlushesAfterInterval(t *testing.T) {
+	client := &mockBulkClient{}
+	writer := newBulkWriter(client, "concept-rw-elasticsearch/", 10, 10*time.Millisecond, time.Second)
+	go writer.run()
+
+	results := writeAll(writer, "a", "b")
+	assert.NoError(t, results["a"])
+	assert.NoError(t, results["b"])
+	for _, batch := range client.Batches() {
+		assert.True(t, len(batch) <= 2)
+	}
+	assert.Contains(t, client.urls, "concept-rw-elasticsearch/bulk")
+
+	results = writeAll(writer, "c")
+	assert.NoError(t, results["c"])
+	assert.Equal(t, []string{"c"}, client.Batches()[len(client.Batches())-1])
+}
+
+func TestBulkWriter_SendsBatchesConcurrently(t *testing.T) {
+	release := make(chan struct{})
+	client := &mockBulkClient{hold: map[string]chan struct{}{"a": release}, held: make(chan string, 1)}
+	writer := newBulkWriter(client, "concept-rw-elasticsearch/bulk", 1, time.Hour, time.Second)
+	go writer.run()
+
+	held := make(chan map[string]error, 1)
+	go func() { held <- writeAll(writer, "a") }()
+	assert.Equal(t, "a", <-client.held)
+
+	results := writeAll(writer, "b")
+	assert.NoError(t, results["b"], "a batch should be sent while an earlier one is still in flight")
+	close(release)
+	assert.NoError(t, (<-held)["a"])
+	assert.Equal(t, [][]string{{"b"}, {"a"}}, client.Batches())
+}
+
+func TestBulkWriter_NextBatchKeepsConceptOrder(t *testing.T) {
+	item := func(UUID string) pendingBulkItem { return pendingBulkItem{item: bulkItem{UUID: UUID}} }
+	a1, a2, b1, c1 := item("a"), item("a"), item("b"), item("c")
+	writer := newBulkWriter(&mockBulkClient{}, "concept-rw-elasticsearch/bulk", 10, time.Hour, time.Second)
+
+	writer.queue = []pendingBulkItem{a1, a2, b1}
+	batch, rest := writer.nextBatch()
+	assert.Equal(t, []pendingBulkItem{a1, b1}, batch, "a batch should hold one item of a concept")
+	assert.Equal(t, []pendingBulkItem{a2}, rest)
+
+	writer.inFlight["a"] = true
+	writer.queue = []pendingBulkItem{a2, b1}
+	batch, rest = writer.nextBatch()
+	assert.Equal(t, []pendingBulkItem{b1}, batch, "later items should wait for an item in flight")
+	assert.Equal(t, []pendingBulkItem{a2}, rest)
+
+	writer.inFlight = map[string]bool{}
+	writer.maxItems = 1
+	writer.queue = []pendingBulkItem{b1, c1}
+	batch, rest = writer.nextBatch()
+	assert.Equal(t, []pendingBulkItem{b1}, batch)
+	assert.Equal(t, []pendingBulkItem{c1}, rest)
+}
+
+func TestBulkWriter_SameConceptInOrder(t *testing.T) {
+	client := &mockBulkClient{}
+	writer := newBulkWriter(client, "concept-rw-elasticsearch/bulk", 10, 10*time.Millisecond, time.Second)
+	go writer.run()
+
+	results := writeAll(writer, "a", "a", "b")
+	assert.Equal(t, map[string]error{"a": nil, "b": nil}, results)
+	var written []string
+	for _, batch := range client.Batches() {
+		seen := map[string]bool{}
+		for _, UUID := range batch {
+			assert.False(t, seen[UUID], "a batch should hold one item of a concept")
+			seen[UUID] = true
+		}
+		written = append(written, batch...)
+	}
+	assert.ElementsMatch(t, []string{"a", "a", "b"}, written)
+}
+
+func TestBulkWriter_ItemResults(t *testing.T) {
+	testCases := map[string]struct {
+		statusCode int
+		err        error
+		statuses   map[string]int
+		extra      int
+		expected   map[string]string
+	}{
+		"Only failed items fail": {
+			statuses: map[string]int{"b": http.StatusServiceUnavailable, "c": http.StatusNotFound},
+			expected: map[string]string{
+				"b": "bulk write of b to concept-rw-elasticsearch/bulk returned status: 503; Service Unavailable",
+			},
+		},
+		"Request error fails every item": {
+			err: errors.New("connection refused"),
+			expected: map[string]string{
+				"a": "bulk request to concept-rw-elasticsearch/bulk returned error: connection refused",
+				"b": "bulk request to concept-rw-elasticsearch/bulk returned error: connection refused",
+				"c": "bulk request to concept-rw-elasticsearch/bulk returned error: connection refused",
+			},
+		},
+		"Request status fails every item": {
+			statusCode: http.StatusBadGateway,
+			expected: map[string]string{
+				"a": "bulk request to concept-rw-elasticsearch/bulk returned status: 502",
+				"b": "bulk request to concept-rw-elasticsearch/bulk returned status: 502",
+				"c": "bulk request to concept-rw-elasticsearch/bulk returned status: 502",
+			},
+		},
+		"Results that do not match the items fail every item": {
+			extra: 1,
+			expected: map[string]string{
+				"a": "bulk request to concept-rw-elasticsearch/bulk returned 4 results for 3 items",
+				"b": "bulk request to concept-rw-elasticsearch/bulk returned 4 results for 3 items",
+				"c": "bulk request to concept-rw-elasticsearch/bulk returned 4 results for 3 items",
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			client := &mockBulkClient{statusCode: tc.statusCode, err: tc.err, statuses: tc.statuses, extra: tc.extra}
+			writer := newBulkWriter(client, "concept-rw-elasticsearch/bulk", 3, time.Hour, time.Second)
+			go writer.run()
+
+			results := writeAll(writer, "a", "b", "c")
+			for _, uuid := range []string{"a", "b", "c"} {
+				if expected, ok := tc.expected[uuid]; ok {
+					assert.EqualError(t, results[uuid], expected, uuid)
+				} else {
+					assert.NoError(t, results[uuid], uuid)
+				}
+			}
+		})
+	}
+}
+
+func TestBulkWriter_ContextDone(t *testing.T) {
+	writer := newBulkWriter(&mockBulkClient{}, "concept-rw-elasticsearch/bulk", 10, time.Hour, time.Second)
+	go writer.run()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := writer.write(ctx, bulkItem{UUID: "a"})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestBulkURL(t *testing.T) {
+	for _, address := range []string{"http://localhost:8083", "http://localhost:8083/", "http://localhost:8083/bulk", "http://localhost:8083/bulk/"} {
+		assert.Equal(t, "http://localhost:8083/bulk", bulkURL(address), address)
+	}
+}
+
+func TestElasticsearchBulkSink(t *testing.T) {
+	client := &mockBulkClient{}
+	sink := &elasticsearchBulkSink{writer: newBulkWriter(client, "concept-rw-elasticsearch/bulk", 1, time.Hour, time.Second), routes: DefaultRoutingTable()}
+	go sink.writer.run()
+
+	for _, c := range []ConcordedConcept{
+		{PrefUUID: "a", Type: "Person"},
+		{PrefUUID: "b", Type: "FinancialInstrument"},
+		{PrefUUID: "c", Type: "Brand"},
+	} {
+		assert.NoError(t, sink.Send(context.Background(), &SinkWrite{Concept: c, TransactionID: fmt.Sprintf("tid_%s", c.PrefUUID)}))
+	}
+	assert.Equal(t, [][]string{{"a"}, {"c"}}, client.Batches(), "concepts Elasticsearch does not hold should not be written")
+	assert.Equal(t, []string{"tid_a", "tid_c"}, client.requestIDs)
+}
+
+func TestBulkRequestID(t *testing.T) {
+	items := []bulkItem{{UUID: "a", TransactionID: "tid_1"}, {UUID: "b", TransactionID: "tid_2"}, {UUID: "c", TransactionID: "tid_1"}, {UUID: "d"}}
+	assert.Equal(t, "tid_1,tid_2", bulkRequestID(items))
+}
+
+func TestNewService_ElasticsearchBulk(t *testing.T) {
+	svc, _, _, _, _, _, _ := setupTestService(200, payload)
+	svc = NewService(svc.s3, svc.conceptUpdatesSqs, svc.eventsSqs, svc.concordances, svc.kinesis, neo4jUrl, esUrl, varnishPurgerUrl, nil, svc.httpClient, nil, nil, time.Second,
+		WithElasticsearchBulk(50, time.Second))
+
+	sink, ok := svc.sinks[ElasticsearchSink].(*elasticsearchBulkSink)
+	if assert.True(t, ok, "bulk writes should replace the single writes to Elasticsearch") {
+		assert.Equal(t, "concept-rw-elasticsearch/bulk", sink.writer.url)
+		assert.Equal(t, 50, sink.writer.maxItems)
+		assert.Equal(t, time.Second, sink.writer.flushInterval)
+	}
+}
@@ -3,8 +3,10 @@ package concept
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gorilla/handlers"
@@ -35,37 +37,111 @@ func (h *AggregateConceptHandler) GetHandler(w http.ResponseWriter, r *http.Requ
 	vars := mux.Vars(r)
 	UUID := vars["uuid"]
 	w.Header().Set("Content-Type", "application/json")
+
+	var withProvenance bool
+	if p := r.URL.Query().Get("provenance"); p != "" {
+		var err error
+		if withProvenance, err = strconv.ParseBool(p); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "{\"message\":\"invalid provenance parameter %q\"}", p)
+			return
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
 	defer cancel()
 
-	concept, transactionID, err := h.getConcordedConcept(ctx, UUID)
+	result, err := h.aggregate(ctx, UUID)
 
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "{\"message\":\"%v\"}", err)
+		writeError(w, err)
 		return
 	}
 
-	w.Header().Set("X-Request-Id", transactionID)
+	w.Header().Set("X-Request-Id", result.TransactionID)
 	w.WriteHeader(http.StatusOK)
+	if withProvenance {
+		//nolint:errcheck
+		json.NewEncoder(w).Encode(conceptWithProvenance{ConcordedConcept: result.Concept, Provenance: result.Provenance})
+		return
+	}
 	//nolint:errcheck
-	json.NewEncoder(w).Encode(concept)
+	json.NewEncoder(w).Encode(result.Concept)
 }
 
-func (h *AggregateConceptHandler) getConcordedConcept(ctx context.Context, UUID string) (ConcordedConcept, string, error) {
+type conceptWithProvenance struct {
+	ConcordedConcept
+	Provenance Provenance `json:"provenance,omitempty"`
+}
 
-	type concordedTransaction struct {
-		Concept       ConcordedConcept
-		TransactionID string
-		Err           error
+func (h *AggregateConceptHandler) ConflictsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	UUID := vars["uuid"]
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
+	defer cancel()
+
+	result, err := h.aggregate(ctx, UUID)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	conflicts := result.Conflicts
+	if conflicts == nil {
+		conflicts = []Conflict{}
 	}
+	w.Header().Set("X-Request-Id", result.TransactionID)
+	w.WriteHeader(http.StatusOK)
+	//nolint:errcheck
+	json.NewEncoder(w).Encode(conceptConflicts{PrefUUID: result.Concept.PrefUUID, Conflicts: conflicts})
+}
+
+type conceptConflicts struct {
+	PrefUUID  string     `json:"prefUUID"`
+	Conflicts []Conflict `json:"conflicts"`
+}
 
-	transaction := make(chan concordedTransaction)
-	var data concordedTransaction
+func (h *AggregateConceptHandler) LineageHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	UUID := vars["uuid"]
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
+	defer cancel()
+
+	result, err := h.aggregate(ctx, UUID)
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	lineage := result.Lineage
+	if lineage.TerminalUUIDs == nil {
+		lineage.TerminalUUIDs = []string{}
+	}
+	w.Header().Set("X-Request-Id", result.TransactionID)
+	w.WriteHeader(http.StatusOK)
+	//nolint:errcheck
+	json.NewEncoder(w).Encode(lineage)
+}
+
+func (h *AggregateConceptHandler) aggregate(ctx context.Context, UUID string) (AggregationResult, error) {
+
+	type aggregatedTransaction struct {
+		Result AggregationResult
+		Err    error
+	}
+
+	transaction := make(chan aggregatedTransaction)
+	var data aggregatedTransaction
 
 	go func() {
-		concordedConcept, transactionID, err := h.svc.GetConcordedConcept(ctx, UUID, "")
-		transaction <- concordedTransaction{Concept: concordedConcept, TransactionID: transactionID, Err: err}
+		result, err := h.svc.Aggregate(ctx, UUID, "")
+		transaction <- aggregatedTransaction{Result: result, Err: err}
 	}()
 
 	select {
@@ -74,7 +150,7 @@ func (h *AggregateConceptHandler) getConcordedConcept(ctx context.Context, UUID
 		data.Err = ctx.Err()
 	}
 
-	return data.Concept, data.TransactionID, data.Err
+	return data.Result, data.Err
 }
 
 func (h *AggregateConceptHandler) SendHandler(w http.ResponseWriter, r *http.Request) {
@@ -82,30 +158,74 @@ func (h *AggregateConceptHandler) SendHandler(w http.ResponseWriter, r *http.Req
 	UUID := vars["uuid"]
 	w.Header().Set("Content-Type", "application/json")
 
+	var dryRun bool
+	if d := r.URL.Query().Get("dryRun"); d != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(d); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "{\"message\":\"invalid dryRun parameter %q\"}", d)
+			return
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
 	defer cancel()
 
-	ch := make(chan error)
+	type processResult struct {
+		Plan Plan
+		Err  error
+	}
+	ch := make(chan processResult)
 	go func() {
+		if dryRun {
+			plan, err := h.svc.PlanMessage(ctx, UUID, "")
+			ch <- processResult{Plan: plan, Err: err}
+			return
+		}
 		err := h.svc.ProcessMessage(ctx, UUID, "")
-		ch <- err
+		ch <- processResult{Err: err}
 	}()
-	var err error
+	var result processResult
 	select {
-	case err = <-ch:
+	case result = <-ch:
 	case <-ctx.Done():
-		err = ctx.Err()
+		result.Err = ctx.Err()
 	}
 
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "{\"message\":\"%v\"}", err)
+	if result.Err != nil {
+		writeError(w, result.Err)
+		return
+	}
+	if dryRun {
+		w.Header().Set("X-Request-Id", result.Plan.TransactionID)
+		w.WriteHeader(http.StatusOK)
+		//nolint:errcheck
+		json.NewEncoder(w).Encode(result.Plan)
 		return
 	}
 	//nolint:errcheck
 	w.Write([]byte(fmt.Sprintf("{\"message\":\"Concept %s updated successfully.\"}", UUID)))
 }
 
+// writeError maps err to a status code: a concept that fails validation is reported with its violations as 422,
+// anything else as 500.
+func writeError(w http.ResponseWriter, err error) {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		//nolint:errcheck
+		json.NewEncoder(w).Encode(validationErrorResponse{Message: err.Error(), Violations: validationErr.Violations})
+		return
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, "{\"message\":\"%v\"}", err)
+}
+
+type validationErrorResponse struct {
+	Message    string      `json:"message"`
+	Violations []Violation `json:"violations"`
+}
+
 func (h *AggregateConceptHandler) RegisterHandlers(healthService *HealthService, requestLoggingEnabled bool, fb chan bool) *http.ServeMux {
 	logger.Info("Registering handlers")
 
@@ -116,8 +236,16 @@ func (h *AggregateConceptHandler) RegisterHandlers(healthService *HealthService,
 	sh := handlers.MethodHandler{
 		"POST": http.HandlerFunc(h.SendHandler),
 	}
+	ch := handlers.MethodHandler{
+		"GET": http.HandlerFunc(h.ConflictsHandler),
+	}
+	lh := handlers.MethodHandler{
+		"GET": http.HandlerFunc(h.LineageHandler),
+	}
 	router.Handle("/concept/{uuid:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}", mh)
 	router.Handle("/concept/{uuid:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/send", sh)
+	router.Handle("/concept/{uuid:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/conflicts", ch)
+	router.Handle("/concept/{uuid:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}}/lineage", lh)
 
 	var monitoringRouter http.Handler = router
 	if requestLoggingEnabled {
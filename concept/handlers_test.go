@@ -26,6 +26,9 @@ func TestHandlers(t *testing.T) {
 		resultBody    string
 		err           error
 		concepts      map[string]ConcordedConcept
+		provenance    map[string]Provenance
+		conflicts     map[string][]Conflict
+		lineage       map[string]Lineage
 		notifications []sqs.ConceptUpdate
 		healthchecks  []fthealth.Check
 		cancelContext bool
@@ -42,6 +45,33 @@ func TestHandlers(t *testing.T) {
 				},
 			},
 		},
+		"Get Concept - With provenance": {
+			method:     "GET",
+			url:        "/concept/f7fd05ea-9999-47c0-9be9-c99dd84d0097?provenance=true",
+			resultCode: 200,
+			resultBody: "{\"prefUUID\":\"f7fd05ea-9999-47c0-9be9-c99dd84d0097\",\"prefLabel\":\"TestConcept\",\"leiCode\":\"213800KZEW5W6BZMNT62\"," +
+				"\"provenance\":{\"leiCode\":[{\"authority\":\"FACTSET\",\"sourceUUID\":\"c28fa0b4-4245-11e8-842f-0ed5f89f718b\",\"transactionID\":\"tid_631\"}]}}\n",
+			concepts: map[string]ConcordedConcept{
+				"f7fd05ea-9999-47c0-9be9-c99dd84d0097": {
+					PrefUUID:  "f7fd05ea-9999-47c0-9be9-c99dd84d0097",
+					PrefLabel: "TestConcept",
+					LeiCode:   "213800KZEW5W6BZMNT62",
+				},
+			},
+			provenance: map[string]Provenance{
+				"f7fd05ea-9999-47c0-9be9-c99dd84d0097": {
+					"leiCode": {
+						{Authority: "FACTSET", SourceUUID: "c28fa0b4-4245-11e8-842f-0ed5f89f718b", TransactionID: "tid_631"},
+					},
+				},
+			},
+		},
+		"Get Concept - Invalid provenance parameter": {
+			method:     "GET",
+			url:        "/concept/f7fd05ea-9999-47c0-9be9-c99dd84d0097?provenance=maybe",
+			resultCode: 400,
+			resultBody: "{\"message\":\"invalid provenance parameter \"maybe\"\"}",
+		},
 		"Get Concept - Not Found": {
 			method:     "GET",
 			url:        "/concept/f7fd05ea-9999-47c0-9be9-c99dd84d0097",
@@ -49,6 +79,104 @@ func TestHandlers(t *testing.T) {
 			resultBody: "{\"message\":\"Canonical concept not found in S3\"}",
 			err:        errors.New("Canonical concept not found in S3"),
 		},
+		"Get Conflicts - Success": {
+			method:     "GET",
+			url:        "/concept/f7fd05ea-9999-47c0-9be9-c99dd84d0097/conflicts",
+			resultCode: 200,
+			resultBody: "{\"prefUUID\":\"f7fd05ea-9999-47c0-9be9-c99dd84d0097\",\"conflicts\":[{\"field\":\"yearFounded\",\"values\":[" +
+				"{\"authority\":\"FACTSET\",\"sourceUUID\":\"c28fa0b4-4245-11e8-842f-0ed5f89f718b\",\"value\":1951}," +
+				"{\"authority\":\"TME\",\"sourceUUID\":\"34a571fb-d779-4610-a7ba-2e127676db4d\",\"value\":1952}]}]}\n",
+			concepts: map[string]ConcordedConcept{
+				"f7fd05ea-9999-47c0-9be9-c99dd84d0097": {
+					PrefUUID:  "f7fd05ea-9999-47c0-9be9-c99dd84d0097",
+					PrefLabel: "TestConcept",
+				},
+			},
+			conflicts: map[string][]Conflict{
+				"f7fd05ea-9999-47c0-9be9-c99dd84d0097": {
+					{
+						Field: "yearFounded",
+						Values: []ConflictValue{
+							{Authority: "FACTSET", SourceUUID: "c28fa0b4-4245-11e8-842f-0ed5f89f718b", Value: 1951},
+							{Authority: "TME", SourceUUID: "34a571fb-d779-4610-a7ba-2e127676db4d", Value: 1952},
+						},
+					},
+				},
+			},
+		},
+		"Get Conflicts - No conflicts": {
+			method:     "GET",
+			url:        "/concept/f7fd05ea-9999-47c0-9be9-c99dd84d0097/conflicts",
+			resultCode: 200,
+			resultBody: "{\"prefUUID\":\"f7fd05ea-9999-47c0-9be9-c99dd84d0097\",\"conflicts\":[]}\n",
+			concepts: map[string]ConcordedConcept{
+				"f7fd05ea-9999-47c0-9be9-c99dd84d0097": {
+					PrefUUID:  "f7fd05ea-9999-47c0-9be9-c99dd84d0097",
+					PrefLabel: "TestConcept",
+				},
+			},
+		},
+		"Get Conflicts - Failure": {
+			method:     "GET",
+			url:        "/concept/f7fd05ea-9999-47c0-9be9-c99dd84d0097/conflicts",
+			resultCode: 500,
+			resultBody: "{\"message\":\"Canonical concept not found in S3\"}",
+			err:        errors.New("Canonical concept not found in S3"),
+		},
+		"Get Lineage - Success": {
+			method:     "GET",
+			url:        "/concept/f7fd05ea-9999-47c0-9be9-c99dd84d0097/lineage",
+			resultCode: 200,
+			resultBody: "{\"prefUUID\":\"f7fd05ea-9999-47c0-9be9-c99dd84d0097\",\"concepts\":[" +
+				"{\"uuid\":\"f7fd05ea-9999-47c0-9be9-c99dd84d0097\",\"supersededByUUIDs\":[\"28090964-9997-4bc2-9638-7a11135aaff9\"]}," +
+				"{\"uuid\":\"28090964-9997-4bc2-9638-7a11135aaff9\"}],\"terminalUUIDs\":[\"28090964-9997-4bc2-9638-7a11135aaff9\"]}\n",
+			concepts: map[string]ConcordedConcept{
+				"f7fd05ea-9999-47c0-9be9-c99dd84d0097": {
+					PrefUUID:  "f7fd05ea-9999-47c0-9be9-c99dd84d0097",
+					PrefLabel: "TestConcept",
+				},
+			},
+			lineage: map[string]Lineage{
+				"f7fd05ea-9999-47c0-9be9-c99dd84d0097": {
+					PrefUUID: "f7fd05ea-9999-47c0-9be9-c99dd84d0097",
+					Concepts: []SupersessionStep{
+						{UUID: "f7fd05ea-9999-47c0-9be9-c99dd84d0097", SupersededByUUIDs: []string{"28090964-9997-4bc2-9638-7a11135aaff9"}},
+						{UUID: "28090964-9997-4bc2-9638-7a11135aaff9"},
+					},
+					TerminalUUIDs: []string{"28090964-9997-4bc2-9638-7a11135aaff9"},
+				},
+			},
+		},
+		"Get Lineage - Cycle": {
+			method:     "GET",
+			url:        "/concept/f7fd05ea-9999-47c0-9be9-c99dd84d0097/lineage",
+			resultCode: 200,
+			resultBody: "{\"prefUUID\":\"f7fd05ea-9999-47c0-9be9-c99dd84d0097\",\"concepts\":[" +
+				"{\"uuid\":\"f7fd05ea-9999-47c0-9be9-c99dd84d0097\",\"supersededByUUIDs\":[\"f7fd05ea-9999-47c0-9be9-c99dd84d0097\"]}]," +
+				"\"terminalUUIDs\":[],\"cycles\":[[\"f7fd05ea-9999-47c0-9be9-c99dd84d0097\",\"f7fd05ea-9999-47c0-9be9-c99dd84d0097\"]]}\n",
+			concepts: map[string]ConcordedConcept{
+				"f7fd05ea-9999-47c0-9be9-c99dd84d0097": {
+					PrefUUID:  "f7fd05ea-9999-47c0-9be9-c99dd84d0097",
+					PrefLabel: "TestConcept",
+				},
+			},
+			lineage: map[string]Lineage{
+				"f7fd05ea-9999-47c0-9be9-c99dd84d0097": {
+					PrefUUID: "f7fd05ea-9999-47c0-9be9-c99dd84d0097",
+					Concepts: []SupersessionStep{
+						{UUID: "f7fd05ea-9999-47c0-9be9-c99dd84d0097", SupersededByUUIDs: []string{"f7fd05ea-9999-47c0-9be9-c99dd84d0097"}},
+					},
+					Cycles: [][]string{{"f7fd05ea-9999-47c0-9be9-c99dd84d0097", "f7fd05ea-9999-47c0-9be9-c99dd84d0097"}},
+				},
+			},
+		},
+		"Get Lineage - Failure": {
+			method:     "GET",
+			url:        "/concept/f7fd05ea-9999-47c0-9be9-c99dd84d0097/lineage",
+			resultCode: 500,
+			resultBody: "{\"message\":\"Canonical concept not found in S3\"}",
+			err:        errors.New("Canonical concept not found in S3"),
+		},
 		"Send Concept - Success": {
 			method:     "POST",
 			url:        "/concept/f7fd05ea-9999-47c0-9be9-c99dd84d0097/send",
@@ -68,6 +196,49 @@ func TestHandlers(t *testing.T) {
 			resultBody: "{\"message\":\"Could not process the concept.\"}",
 			err:        errors.New("Could not process the concept."),
 		},
+		"Send Concept - Invalid concept": {
+			method:     "POST",
+			url:        "/concept/f7fd05ea-9999-47c0-9be9-c99dd84d0097/send",
+			resultCode: 422,
+			resultBody: "{\"message\":\"concept f7fd05ea-9999-47c0-9be9-c99dd84d0097 of type Membership is invalid: personUUID is required\"," +
+				"\"violations\":[{\"field\":\"personUUID\",\"message\":\"is required\",\"severity\":\"fatal\"}]}\n",
+			err: &ValidationError{
+				UUID:       "f7fd05ea-9999-47c0-9be9-c99dd84d0097",
+				Type:       "Membership",
+				Violations: []Violation{{Field: "personUUID", Message: "is required", Severity: SeverityFatal}},
+			},
+		},
+		"Send Concept - Dry run": {
+			method:     "POST",
+			url:        "/concept/f7fd05ea-9999-47c0-9be9-c99dd84d0097/send?dryRun=true",
+			resultCode: 200,
+			resultBody: "{\"prefUUID\":\"f7fd05ea-9999-47c0-9be9-c99dd84d0097\",\"transactionID\":\"tid\",\"unchanged\":false," +
+				"\"updatedIDs\":[\"f7fd05ea-9999-47c0-9be9-c99dd84d0097\"],\"sinks\":[]}\n",
+			concepts: map[string]ConcordedConcept{
+				"f7fd05ea-9999-47c0-9be9-c99dd84d0097": {
+					PrefUUID:  "f7fd05ea-9999-47c0-9be9-c99dd84d0097",
+					PrefLabel: "TestConcept",
+				},
+			},
+		},
+		"Send Concept - Dry run of invalid concept": {
+			method:     "POST",
+			url:        "/concept/f7fd05ea-9999-47c0-9be9-c99dd84d0097/send?dryRun=true",
+			resultCode: 422,
+			resultBody: "{\"message\":\"concept f7fd05ea-9999-47c0-9be9-c99dd84d0097 of type Membership is invalid: personUUID is required\"," +
+				"\"violations\":[{\"field\":\"personUUID\",\"message\":\"is required\",\"severity\":\"fatal\"}]}\n",
+			err: &ValidationError{
+				UUID:       "f7fd05ea-9999-47c0-9be9-c99dd84d0097",
+				Type:       "Membership",
+				Violations: []Violation{{Field: "personUUID", Message: "is required", Severity: SeverityFatal}},
+			},
+		},
+		"Send Concept - Invalid dryRun parameter": {
+			method:     "POST",
+			url:        "/concept/f7fd05ea-9999-47c0-9be9-c99dd84d0097/send?dryRun=maybe",
+			resultCode: 400,
+			resultBody: "{\"message\":\"invalid dryRun parameter \"maybe\"\"}",
+		},
 		"GTG - Success": {
 			method:     "GET",
 			url:        "/__gtg",
@@ -106,7 +277,8 @@ func TestHandlers(t *testing.T) {
 	for testName, d := range testCases {
 		t.Run(testName, func(t *testing.T) {
 			fb := make(chan bool)
-			mockService := NewMockService(d.concepts, d.notifications, d.healthchecks, d.err)
+			mockService := NewMockService(d.concepts, d.provenance, d.conflicts, d.notifications, d.healthchecks, d.err)
+			mockService.(*MockService).lineage = d.lineage
 			handler := NewHandler(mockService, time.Second*1)
 			sm := handler.RegisterHandlers(NewHealthService(mockService, "system-code", "app-name", 8080, "description"), true, fb)
 
@@ -136,14 +308,19 @@ func TestHandlers(t *testing.T) {
 type MockService struct {
 	notifications []sqs.ConceptUpdate
 	concepts      map[string]ConcordedConcept
+	provenance    map[string]Provenance
+	conflicts     map[string][]Conflict
+	lineage       map[string]Lineage
 	m             sync.RWMutex
 	healthchecks  []fthealth.Check
 	err           error
 }
 
-func NewMockService(concepts map[string]ConcordedConcept, notifications []sqs.ConceptUpdate, healthchecks []fthealth.Check, err error) Service {
+func NewMockService(concepts map[string]ConcordedConcept, provenance map[string]Provenance, conflicts map[string][]Conflict, notifications []sqs.ConceptUpdate, healthchecks []fthealth.Check, err error) Service {
 	return &MockService{
 		concepts:      concepts,
+		provenance:    provenance,
+		conflicts:     conflicts,
 		notifications: notifications,
 		healthchecks:  healthchecks,
 		err:           err,
@@ -164,6 +341,14 @@ func (s *MockService) ProcessMessage(ctx context.Context, UUID string, bookmark
 	return nil
 }
 
+func (s *MockService) PlanMessage(ctx context.Context, UUID string, bookmark string) (Plan, error) {
+	c, tid, err := s.GetConcordedConcept(ctx, UUID, bookmark)
+	if err != nil {
+		return Plan{}, err
+	}
+	return Plan{PrefUUID: c.PrefUUID, TransactionID: tid, UpdatedIDs: []string{c.PrefUUID}, Sinks: []SinkPlan{}}, nil
+}
+
 func (s *MockService) GetConcordedConcept(ctx context.Context, UUID string, bookmark string) (ConcordedConcept, string, error) {
 	if s.err != nil {
 		return ConcordedConcept{}, "", s.err
@@ -174,6 +359,14 @@ func (s *MockService) GetConcordedConcept(ctx context.Context, UUID string, book
 	return ConcordedConcept{}, "", s.err
 }
 
+func (s *MockService) Aggregate(ctx context.Context, UUID string, bookmark string) (AggregationResult, error) {
+	c, tid, err := s.GetConcordedConcept(ctx, UUID, bookmark)
+	if err != nil {
+		return AggregationResult{}, err
+	}
+	return AggregationResult{Concept: c, TransactionID: tid, Provenance: s.provenance[UUID], Conflicts: s.conflicts[UUID], Lineage: s.lineage[UUID]}, nil
+}
+
 func (s *MockService) Healthchecks() []fthealth.Check {
 	if s.healthchecks != nil {
 		return s.healthchecks
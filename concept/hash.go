@@ -0,0 +1,50 @@
+package concept
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/s3"
+)
+
+// conceptHash returns a hash of c that does not depend on the order of its relationship, name and
+// source representation lists, so that the same sources merged in a different order hash the same.
+func conceptHash(c ConcordedConcept) (string, error) {
+	c.Aliases = sortedCopy(c.Aliases)
+	c.ParentUUIDs = sortedCopy(c.ParentUUIDs)
+	c.BroaderUUIDs = sortedCopy(c.BroaderUUIDs)
+	c.RelatedUUIDs = sortedCopy(c.RelatedUUIDs)
+	c.SupersededByUUIDs = sortedCopy(c.SupersededByUUIDs)
+	c.TerminalSupersededByUUIDs = sortedCopy(c.TerminalSupersededByUUIDs)
+	c.ImpliedByUUIDs = sortedCopy(c.ImpliedByUUIDs)
+	c.HasFocusUUIDs = sortedCopy(c.HasFocusUUIDs)
+	c.FormerNames = sortedCopy(c.FormerNames)
+	c.TradeNames = sortedCopy(c.TradeNames)
+
+	c.MembershipRoles = append([]MembershipRole(nil), c.MembershipRoles...)
+	sort.SliceStable(c.MembershipRoles, func(i, j int) bool {
+		return c.MembershipRoles[i].RoleUUID < c.MembershipRoles[j].RoleUUID
+	})
+	c.SourceRepresentations = append([]s3.Concept(nil), c.SourceRepresentations...)
+	sort.SliceStable(c.SourceRepresentations, func(i, j int) bool {
+		return c.SourceRepresentations[i].UUID < c.SourceRepresentations[j].UUID
+	})
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func sortedCopy(slice []string) []string {
+	if slice == nil {
+		return nil
+	}
+	sorted := append([]string{}, slice...)
+	sort.Strings(sorted)
+	return sorted
+}
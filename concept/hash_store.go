@@ -0,0 +1,155 @@
+package concept
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	HashStoreNone   = "none"
+	HashStoreMemory = "memory"
+	HashStoreFile   = "file"
+)
+
+// HashStore records, by prefUUID, the hash of the last concorded concept that was written to every sink.
+type HashStore interface {
+	Get(prefUUID string) (hash string, found bool, err error)
+	Put(prefUUID string, hash string) error
+}
+
+// OpenHashStore returns the hash store of the given kind. The none kind returns a nil store, which disables
+// skipping unchanged concepts; path is only used by the file kind.
+func OpenHashStore(kind string, path string) (HashStore, error) {
+	switch kind {
+	case "", HashStoreNone:
+		return nil, nil
+	case HashStoreMemory:
+		return NewMemoryHashStore(), nil
+	case HashStoreFile:
+		store, err := NewFileHashStore(path)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+	return nil, fmt.Errorf("unknown hash store %q", kind)
+}
+
+// MemoryHashStore keeps the hashes in memory, so they are lost when the service restarts.
+type MemoryHashStore struct {
+	mu     sync.RWMutex
+	hashes map[string]string
+}
+
+func NewMemoryHashStore() *MemoryHashStore {
+	return &MemoryHashStore{hashes: map[string]string{}}
+}
+
+func (m *MemoryHashStore) Get(prefUUID string) (string, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	hash, found := m.hashes[prefUUID]
+	return hash, found, nil
+}
+
+func (m *MemoryHashStore) Put(prefUUID string, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hashes[prefUUID] = hash
+	return nil
+}
+
+// FileHashStore keeps the hashes in memory and appends every change to a file, from which they are read back
+// when the store is opened. The file is compacted on opening once most of its lines are out of date.
+type FileHashStore struct {
+	MemoryHashStore
+	file *os.File
+}
+
+func NewFileHashStore(path string) (*FileHashStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no file given for the hash store")
+	}
+	store := &FileHashStore{MemoryHashStore: MemoryHashStore{hashes: map[string]string{}}}
+	lines, err := store.load(path)
+	if err != nil {
+		return nil, err
+	}
+	if lines > 2*len(store.hashes) {
+		if err = store.compact(path); err != nil {
+			return nil, err
+		}
+	}
+	store.file, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open hash store: %w", err)
+	}
+	return store, nil
+}
+
+func (f *FileHashStore) Put(prefUUID string, hash string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := fmt.Fprintf(f.file, "%s %s\n", prefUUID, hash); err != nil {
+		return fmt.Errorf("failed to write hash store: %w", err)
+	}
+	f.hashes[prefUUID] = hash
+	return nil
+}
+
+// Close closes the file of the store.
+func (f *FileHashStore) Close() error {
+	return f.file.Close()
+}
+
+func (f *FileHashStore) load(path string) (int, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to read hash store: %w", err)
+	}
+	defer file.Close()
+
+	var lines int
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			// A line cut short by a crash; the concept will be written again
+			continue
+		}
+		f.hashes[fields[0]] = fields[1]
+		lines++
+	}
+	if err = scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read hash store: %w", err)
+	}
+	return lines, nil
+}
+
+func (f *FileHashStore) compact(path string) error {
+	tmp, err := os.Create(filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp"))
+	if err != nil {
+		return fmt.Errorf("failed to compact hash store: %w", err)
+	}
+	w := bufio.NewWriter(tmp)
+	for prefUUID, hash := range f.hashes {
+		fmt.Fprintf(w, "%s %s\n", prefUUID, hash)
+	}
+	if err = w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to compact hash store: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("failed to compact hash store: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to compact hash store: %w", err)
+	}
+	return nil
+}
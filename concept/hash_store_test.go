@@ -0,0 +1,86 @@
+package concept
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryHashStore(t *testing.T) {
+	store := NewMemoryHashStore()
+	_, found, err := store.Get("a")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	assert.NoError(t, store.Put("a", "hash-1"))
+	assert.NoError(t, store.Put("a", "hash-2"))
+	hash, found, err := store.Get("a")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "hash-2", hash)
+}
+
+func TestFileHashStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hash-store")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "hashes")
+
+	store, err := NewFileHashStore(path)
+	assert.NoError(t, err)
+	assert.NoError(t, store.Put("a", "hash-1"))
+	assert.NoError(t, store.Put("b", "hash-1"))
+	assert.NoError(t, store.Put("a", "hash-2"))
+	assert.NoError(t, store.Close())
+
+	store, err = NewFileHashStore(path)
+	assert.NoError(t, err)
+	hash, found, err := store.Get("a")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "hash-2", hash)
+	hash, _, _ = store.Get("b")
+	assert.Equal(t, "hash-1", hash)
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, store.Put("a", "hash-3"))
+	}
+	assert.NoError(t, store.Close())
+
+	// Reopening compacts the file as most of its lines are out of date, and skips a line cut short
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	assert.NoError(t, err)
+	_, err = f.WriteString("c")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	store, err = NewFileHashStore(path)
+	assert.NoError(t, err)
+	defer store.Close()
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(data)), "\n"), 2)
+	hash, _, _ = store.Get("a")
+	assert.Equal(t, "hash-3", hash)
+	_, found, _ = store.Get("c")
+	assert.False(t, found)
+}
+
+func TestOpenHashStore(t *testing.T) {
+	store, err := OpenHashStore(HashStoreNone, "")
+	assert.NoError(t, err)
+	assert.Nil(t, store)
+
+	store, err = OpenHashStore(HashStoreMemory, "")
+	assert.NoError(t, err)
+	assert.IsType(t, &MemoryHashStore{}, store)
+
+	_, err = OpenHashStore(HashStoreFile, "")
+	assert.EqualError(t, err, "no file given for the hash store")
+
+	_, err = OpenHashStore("redis", "")
+	assert.EqualError(t, err, `unknown hash store "redis"`)
+}
@@ -0,0 +1,71 @@
+package concept
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConceptHash(t *testing.T) {
+	c := ConcordedConcept{
+		PrefUUID:        "28090964-9997-4bc2-9638-7a11135aaff9",
+		PrefLabel:       "Root Concept",
+		Type:            "Person",
+		Aliases:         []string{"Root Concept", "TME Concept"},
+		BroaderUUIDs:    []string{"a", "b"},
+		MembershipRoles: []MembershipRole{{RoleUUID: "x"}, {RoleUUID: "y"}},
+		SourceRepresentations: []s3.Concept{
+			{UUID: "34a571fb-d779-4610-a7ba-2e127676db4d", Authority: "TME"},
+			{UUID: "28090964-9997-4bc2-9638-7a11135aaff9", Authority: "Smartlogic"},
+		},
+	}
+	reordered := c
+	reordered.Aliases = []string{"TME Concept", "Root Concept"}
+	reordered.BroaderUUIDs = []string{"b", "a"}
+	reordered.MembershipRoles = []MembershipRole{{RoleUUID: "y"}, {RoleUUID: "x"}}
+	reordered.SourceRepresentations = []s3.Concept{c.SourceRepresentations[1], c.SourceRepresentations[0]}
+	changed := c
+	changed.PrefLabel = "Renamed Concept"
+
+	hash, err := conceptHash(c)
+	assert.NoError(t, err)
+	reorderedHash, err := conceptHash(reordered)
+	assert.NoError(t, err)
+	changedHash, err := conceptHash(changed)
+	assert.NoError(t, err)
+
+	assert.Equal(t, hash, reorderedHash)
+	assert.NotEqual(t, hash, changedHash)
+	assert.Equal(t, []string{"TME Concept", "Root Concept"}, reordered.Aliases, "hashing should not reorder the concept")
+}
+
+func TestAggregateService_ProcessMessage_SkipsUnchangedConcept(t *testing.T) {
+	svc, _, _, eventQueue, kinesis, _, _ := setupTestService(200, payload)
+	svc.hashStore = NewMemoryHashStore()
+	mockWriter := svc.httpClient.(*mockHTTPClient)
+
+	assert.NoError(t, svc.ProcessMessage(context.Background(), "28090964-9997-4bc2-9638-7a11135aaff9", ""))
+	assert.Len(t, mockWriter.called, 3)
+	assert.Len(t, eventQueue.eventList, 3)
+	_, found, _ := svc.hashStore.Get("28090964-9997-4bc2-9638-7a11135aaff9")
+	assert.True(t, found)
+
+	mockWriter.called = nil
+	kinesis.err = errors.New("should not be called")
+	assert.NoError(t, svc.ProcessMessage(context.Background(), "28090964-9997-4bc2-9638-7a11135aaff9", ""))
+	assert.Empty(t, mockWriter.called)
+	assert.Len(t, eventQueue.eventList, 3)
+}
+
+func TestAggregateService_ProcessMessage_HashNotRecordedOnFailure(t *testing.T) {
+	svc, _, _, _, kinesis, _, _ := setupTestService(200, payload)
+	svc.hashStore = NewMemoryHashStore()
+	kinesis.err = errors.New("failed to add record to stream")
+
+	assert.Error(t, svc.ProcessMessage(context.Background(), "28090964-9997-4bc2-9638-7a11135aaff9", ""))
+	_, found, _ := svc.hashStore.Get("28090964-9997-4bc2-9638-7a11135aaff9")
+	assert.False(t, found)
+}
@@ -0,0 +1,65 @@
+package concept
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// checkHierarchy walks the broader and parent relationships up from c, whose relationships must already be
+// resolved to canonical concepts, for at most hierarchyDepth steps. A *ValidationError with the path of the
+// cycle is returned when the walk leads back to c. Concepts that cannot be aggregated, because they are not in S3
+// yet, have no single canonical concept or are invalid, end the walk.
+func (s *AggregateService) checkHierarchy(ctx context.Context, c ConcordedConcept, bookmark string) error {
+	if s.hierarchyDepth <= 0 {
+		return nil
+	}
+
+	visited := map[string]bool{c.PrefUUID: true}
+	var walk func(path []string, next []string) ([]string, error)
+	walk = func(path []string, next []string) ([]string, error) {
+		for _, UUID := range next {
+			if UUID == c.PrefUUID {
+				return append(append([]string{}, path...), UUID), nil
+			}
+			if visited[UUID] || len(path) >= s.hierarchyDepth {
+				continue
+			}
+			visited[UUID] = true
+
+			broader, err := s.relatedCanonicalUUIDs(ctx, UUID, bookmark, broaderAndParentUUIDs)
+			if isUnresolvable(err) {
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to walk hierarchy to %s: %w", UUID, err)
+			}
+			cycle, err := walk(append(path, UUID), broader)
+			if err != nil || cycle != nil {
+				return cycle, err
+			}
+		}
+		return nil, nil
+	}
+
+	cycle, err := walk([]string{c.PrefUUID}, broaderAndParentUUIDs(c))
+	if err != nil || cycle == nil {
+		return err
+	}
+	field := "parentUUIDs"
+	if contains(cycle[1], c.BroaderUUIDs) {
+		field = "broaderUUIDs"
+	}
+	return &ValidationError{
+		UUID: c.PrefUUID,
+		Type: c.Type,
+		Violations: []Violation{{
+			Field:    field,
+			Message:  "create a cycle: " + strings.Join(cycle, " -> "),
+			Severity: SeverityFatal,
+		}},
+	}
+}
+
+func broaderAndParentUUIDs(c ConcordedConcept) []string {
+	return append(append([]string{}, c.BroaderUUIDs...), c.ParentUUIDs...)
+}
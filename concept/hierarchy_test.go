@@ -0,0 +1,148 @@
+package concept
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/concordances"
+	"github.com/Financial-Times/aggregate-concept-transformer/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func addHierarchyConcept(s3mock *mockS3Client, UUID string, broader []string, parents []string) {
+	s3mock.concepts[UUID] = struct {
+		transactionID string
+		concept       s3.Concept
+	}{
+		transactionID: "tid_" + UUID,
+		concept: s3.Concept{
+			UUID:         UUID,
+			PrefLabel:    "Topic " + UUID,
+			Authority:    "Smartlogic",
+			AuthValue:    UUID,
+			Type:         "Topic",
+			BroaderUUIDs: broader,
+			ParentUUIDs:  parents,
+		},
+	}
+}
+
+func TestAggregateService_ProcessMessage_HierarchyCycleGuard(t *testing.T) {
+	testCases := map[string]struct {
+		depth    int
+		broader  map[string][]string
+		parents  map[string][]string
+		expected string
+	}{
+		"No cycle": {
+			depth:   5,
+			broader: map[string][]string{"a": {"b"}, "b": {"c"}, "c": nil},
+		},
+		"Broader cycle": {
+			depth:    5,
+			broader:  map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}},
+			expected: "concept a of type Topic is invalid: broaderUUIDs create a cycle: a -> b -> c -> a",
+		},
+		"Cycle through broader and parent": {
+			depth:    5,
+			broader:  map[string][]string{"b": {"a"}},
+			parents:  map[string][]string{"a": {"b"}},
+			expected: "concept a of type Topic is invalid: parentUUIDs create a cycle: a -> b -> a",
+		},
+		"Broader than itself": {
+			depth:    5,
+			broader:  map[string][]string{"a": {"a"}},
+			expected: "concept a of type Topic is invalid: broaderUUIDs create a cycle: a -> a",
+		},
+		"Cycle elsewhere ignored": {
+			depth:   5,
+			broader: map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"b"}},
+		},
+		"Cycle longer than the depth": {
+			depth:   2,
+			broader: map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"d"}, "d": {"a"}},
+		},
+		"Guard disabled": {
+			broader: map[string][]string{"a": {"b"}, "b": {"a"}},
+		},
+		"Missing broader concept ends the walk": {
+			depth:   5,
+			broader: map[string][]string{"a": {"missing"}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			svc, s3mock, _, _, _, _, _ := setupTestService(200, payload)
+			svc.hierarchyDepth = tc.depth
+			for _, UUID := range []string{"a", "b", "c", "d"} {
+				if _, ok := tc.broader[UUID]; ok {
+					addHierarchyConcept(s3mock, UUID, tc.broader[UUID], tc.parents[UUID])
+				} else if _, ok := tc.parents[UUID]; ok {
+					addHierarchyConcept(s3mock, UUID, nil, tc.parents[UUID])
+				}
+			}
+
+			err := svc.ProcessMessage(context.Background(), "a", "")
+			if tc.expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var validationErr *ValidationError
+			assert.True(t, errors.As(err, &validationErr))
+			assert.EqualError(t, err, tc.expected)
+			assert.Empty(t, svc.httpClient.(*mockHTTPClient).called)
+		})
+	}
+}
+
+func TestAggregateService_ProcessMessage_HierarchyWithoutCanonicalConcept(t *testing.T) {
+	svc, s3mock, _, _, _, _, _ := setupTestService(200, payload)
+	svc.hierarchyDepth = 5
+	addHierarchyConcept(s3mock, "a", []string{"b"}, nil)
+	addHierarchyConcept(s3mock, "b", []string{"a"}, nil)
+	svc.concordances.(*mockConcordancesClient).concordances["b"] = []concordances.ConcordanceRecord{
+		{UUID: "b", Authority: "Smartlogic"},
+		{UUID: "b2", Authority: "Smartlogic"},
+	}
+
+	err := svc.ProcessMessage(context.Background(), "a", "")
+	assert.NoError(t, err, "a broader concept without a single canonical concept should end the walk")
+}
+
+func TestAggregateService_ProcessMessage_HierarchyWithInvalidConcept(t *testing.T) {
+	svc, s3mock, _, _, _, _, _ := setupTestService(200, payload)
+	svc.hierarchyDepth = 5
+	addHierarchyConcept(s3mock, "a", []string{"b"}, nil)
+	addHierarchyConcept(s3mock, "b", nil, nil)
+	addHierarchyConcept(s3mock, "tme-b", nil, nil)
+	b := s3mock.concepts["b"]
+	b.concept.Type = "Organisation"
+	s3mock.concepts["b"] = b
+	tmeB := s3mock.concepts["tme-b"]
+	tmeB.concept.Authority = "TME"
+	tmeB.concept.Type = "Person"
+	s3mock.concepts["tme-b"] = tmeB
+	svc.concordances.(*mockConcordancesClient).concordances["b"] = []concordances.ConcordanceRecord{
+		{UUID: "b", Authority: "Smartlogic"},
+		{UUID: "tme-b", Authority: "TME"},
+	}
+
+	err := svc.ProcessMessage(context.Background(), "a", "")
+	assert.NoError(t, err, "an invalid broader concept should end the walk rather than fail the concept")
+}
+
+func TestAggregateService_ProcessMessage_HierarchyCycleThroughSourceConcept(t *testing.T) {
+	svc, s3mock, _, _, _, _, _ := setupTestService(200, payload)
+	svc.hierarchyDepth = 5
+	addHierarchyConcept(s3mock, "a", []string{"b"}, nil)
+	addHierarchyConcept(s3mock, "b", []string{"tme-a"}, nil)
+	svc.concordances.(*mockConcordancesClient).concordances["tme-a"] = []concordances.ConcordanceRecord{
+		{UUID: "tme-a", Authority: "TME"},
+		{UUID: "a", Authority: "Smartlogic"},
+	}
+
+	err := svc.ProcessMessage(context.Background(), "a", "")
+	assert.EqualError(t, err, "concept a of type Topic is invalid: broaderUUIDs create a cycle: a -> b -> a")
+}
@@ -7,16 +7,22 @@ import (
 )
 
 type mockKinesisStreamClient struct {
-	err error
+	err     error
+	records []string
 }
 
-func (k *mockKinesisStreamClient) AddRecordToStream(ctx context.Context, concept []byte, conceptType string) error {
+func (k *mockKinesisStreamClient) AddRecordToStream(ctx context.Context, concept []byte, conceptType string, prefUUID string) error {
 	if k.err != nil {
 		return k.err
 	}
+	k.records = append(k.records, string(concept))
 	return nil
 }
 
+func (k *mockKinesisStreamClient) PartitionKey(conceptType string, prefUUID string) string {
+	return conceptType
+}
+
 func (k *mockKinesisStreamClient) Healthcheck() fthealth.Check {
 	return fthealth.Check{
 		Checker: func() (string, error) {
@@ -0,0 +1,99 @@
+package concept
+
+import (
+	"context"
+	"fmt"
+)
+
+// maxSupersessionDepth bounds how far supersession chains are followed from the concept being aggregated.
+const maxSupersessionDepth = 10
+
+// Lineage describes the concepts reached by following the supersededByUUIDs of a concept.
+type Lineage struct {
+	PrefUUID string `json:"prefUUID"`
+	// Concepts lists every concept reached, in the order it was visited, starting with the concept itself.
+	Concepts []SupersessionStep `json:"concepts"`
+	// TerminalUUIDs are the concepts reached that are not superseded themselves.
+	TerminalUUIDs []string `json:"terminalUUIDs"`
+	// Cycles lists each loop found, starting and ending with the same concept.
+	Cycles [][]string `json:"cycles,omitempty"`
+	// Truncated is set when a chain was longer than maxSupersessionDepth and was not followed to its end.
+	Truncated bool `json:"truncated,omitempty"`
+	// Unresolved lists the concepts a chain could not be followed through, because they are not in S3, have
+	// no single canonical concept or are invalid. The chain stops at them and they are never terminal.
+	Unresolved []UnresolvedReference `json:"unresolved,omitempty"`
+}
+
+// SupersessionStep is a concept in a supersession chain with the canonical concepts it is superseded by.
+type SupersessionStep struct {
+	UUID              string   `json:"uuid"`
+	SupersededByUUIDs []string `json:"supersededByUUIDs,omitempty"`
+}
+
+// followSupersession walks the supersession chains of c, whose supersededByUUIDs must already be resolved
+// to canonical concepts. Cycles and concepts that cannot be aggregated are recorded rather than treated as
+// errors, and are never terminal. An error is only returned when S3 or the concordances cannot be read.
+func (s *AggregateService) followSupersession(ctx context.Context, c ConcordedConcept, bookmark string) (Lineage, error) {
+	lineage := Lineage{PrefUUID: c.PrefUUID}
+	visited := map[string]bool{}
+	var path []string
+
+	var visit func(UUID string, supersededBy []string) error
+	visit = func(UUID string, supersededBy []string) error {
+		visited[UUID] = true
+		lineage.Concepts = append(lineage.Concepts, SupersessionStep{UUID: UUID, SupersededByUUIDs: supersededBy})
+		if len(supersededBy) == 0 {
+			if len(path) > 0 {
+				lineage.TerminalUUIDs = append(lineage.TerminalUUIDs, UUID)
+			}
+			return nil
+		}
+
+		path = append(path, UUID)
+		defer func() { path = path[:len(path)-1] }()
+		for _, next := range supersededBy {
+			if i := indexOf(path, next); i >= 0 {
+				cycle := append(append([]string{}, path[i:]...), next)
+				lineage.Cycles = append(lineage.Cycles, cycle)
+				continue
+			}
+			if visited[next] {
+				continue
+			}
+			if len(path) >= maxSupersessionDepth {
+				lineage.Truncated = true
+				continue
+			}
+			nextSupersededBy, err := s.relatedCanonicalUUIDs(ctx, next, bookmark, supersededByUUIDs)
+			if isUnresolvable(err) {
+				visited[next] = true
+				lineage.Unresolved = append(lineage.Unresolved, UnresolvedReference{Field: "supersededByUUIDs", UUID: next, Reason: err.Error()})
+				continue
+			} else if err != nil {
+				return fmt.Errorf("failed to follow supersession to %s: %w", next, err)
+			}
+			if err = visit(next, nextSupersededBy); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := visit(c.PrefUUID, c.SupersededByUUIDs); err != nil {
+		return Lineage{}, err
+	}
+	return lineage, nil
+}
+
+func supersededByUUIDs(c ConcordedConcept) []string {
+	return c.SupersededByUUIDs
+}
+
+func indexOf(slice []string, element string) int {
+	for i, v := range slice {
+		if v == element {
+			return i
+		}
+	}
+	return -1
+}
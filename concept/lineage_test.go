@@ -0,0 +1,133 @@
+package concept
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/concordances"
+	"github.com/Financial-Times/aggregate-concept-transformer/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func addSupersededConcept(s3mock *mockS3Client, UUID string, supersededBy ...string) {
+	s3mock.concepts[UUID] = struct {
+		transactionID string
+		concept       s3.Concept
+	}{
+		transactionID: "tid_" + UUID,
+		concept: s3.Concept{
+			UUID:              UUID,
+			PrefLabel:         "Concept " + UUID,
+			Authority:         "Smartlogic",
+			AuthValue:         UUID,
+			Type:              "Topic",
+			SupersededByUUIDs: supersededBy,
+		},
+	}
+}
+
+func TestAggregateService_Aggregate_FollowsSupersessionChains(t *testing.T) {
+	testCases := map[string]struct {
+		concepts map[string][]string
+		terminal []string
+		visited  []SupersessionStep
+		cycles   [][]string
+	}{
+		"Not superseded": {
+			concepts: map[string][]string{"a": nil},
+			visited:  []SupersessionStep{{UUID: "a"}},
+		},
+		"Chain": {
+			concepts: map[string][]string{"a": {"b"}, "b": {"c"}, "c": nil},
+			terminal: []string{"c"},
+			visited:  []SupersessionStep{{UUID: "a", SupersededByUUIDs: []string{"b"}}, {UUID: "b", SupersededByUUIDs: []string{"c"}}, {UUID: "c"}},
+		},
+		"Split and rejoined": {
+			concepts: map[string][]string{"a": {"b", "c"}, "b": {"d"}, "c": {"d", "e"}, "d": nil, "e": nil},
+			terminal: []string{"d", "e"},
+			visited: []SupersessionStep{
+				{UUID: "a", SupersededByUUIDs: []string{"b", "c"}},
+				{UUID: "b", SupersededByUUIDs: []string{"d"}},
+				{UUID: "d"},
+				{UUID: "c", SupersededByUUIDs: []string{"d", "e"}},
+				{UUID: "e"},
+			},
+		},
+		"Cycle": {
+			concepts: map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"b"}},
+			visited:  []SupersessionStep{{UUID: "a", SupersededByUUIDs: []string{"b"}}, {UUID: "b", SupersededByUUIDs: []string{"c"}}, {UUID: "c", SupersededByUUIDs: []string{"b"}}},
+			cycles:   [][]string{{"b", "c", "b"}},
+		},
+		"Superseded by itself": {
+			concepts: map[string][]string{"a": {"a"}},
+			visited:  []SupersessionStep{{UUID: "a", SupersededByUUIDs: []string{"a"}}},
+			cycles:   [][]string{{"a", "a"}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			svc, s3mock, _, _, _, _, _ := setupTestService(200, payload)
+			for UUID, supersededBy := range tc.concepts {
+				addSupersededConcept(s3mock, UUID, supersededBy...)
+			}
+
+			result, err := svc.Aggregate(context.Background(), "a", "")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.terminal, result.Concept.TerminalSupersededByUUIDs)
+			assert.Equal(t, Lineage{PrefUUID: "a", Concepts: tc.visited, TerminalUUIDs: tc.terminal, Cycles: tc.cycles}, result.Lineage)
+		})
+	}
+}
+
+func TestAggregateService_Aggregate_SupersessionResolvedToCanonicalConcepts(t *testing.T) {
+	svc, s3mock, _, _, _, _, _ := setupTestService(200, payload)
+	addSupersededConcept(s3mock, "a", "tme-b")
+	addSupersededConcept(s3mock, "b", "tme-c")
+	addSupersededConcept(s3mock, "c")
+	concordClient := svc.concordances.(*mockConcordancesClient)
+	concordClient.concordances["tme-b"] = []concordances.ConcordanceRecord{{UUID: "tme-b", Authority: "TME"}, {UUID: "b", Authority: "Smartlogic"}}
+	concordClient.concordances["tme-c"] = []concordances.ConcordanceRecord{{UUID: "tme-c", Authority: "TME"}, {UUID: "c", Authority: "Smartlogic"}}
+
+	result, err := svc.Aggregate(context.Background(), "a", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b"}, result.Concept.SupersededByUUIDs)
+	assert.Equal(t, []string{"c"}, result.Concept.TerminalSupersededByUUIDs)
+	assert.Equal(t, []SupersessionStep{{UUID: "a", SupersededByUUIDs: []string{"b"}}, {UUID: "b", SupersededByUUIDs: []string{"c"}}, {UUID: "c"}}, result.Lineage.Concepts)
+}
+
+func TestAggregateService_Aggregate_SupersessionChainTruncated(t *testing.T) {
+	svc, s3mock, _, _, _, _, _ := setupTestService(200, payload)
+	for i := 0; i < maxSupersessionDepth+5; i++ {
+		addSupersededConcept(s3mock, fmt.Sprintf("c%d", i), fmt.Sprintf("c%d", i+1))
+	}
+
+	result, err := svc.Aggregate(context.Background(), "c0", "")
+	assert.NoError(t, err)
+	assert.True(t, result.Lineage.Truncated)
+	assert.Empty(t, result.Concept.TerminalSupersededByUUIDs)
+	assert.Len(t, result.Lineage.Concepts, maxSupersessionDepth)
+}
+
+func TestAggregateService_Aggregate_SupersessionFailure(t *testing.T) {
+	svc, s3mock, _, _, _, _, _ := setupTestService(200, payload)
+	addSupersededConcept(s3mock, "a", "b", "c")
+	addSupersededConcept(s3mock, "c")
+
+	result, err := svc.Aggregate(context.Background(), "a", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, result.Concept.SupersededByUUIDs, "the supersededByUUIDs of the sources should be kept")
+	assert.Equal(t, []string{"c"}, result.Concept.TerminalSupersededByUUIDs)
+	assert.Equal(t, []SupersessionStep{{UUID: "a", SupersededByUUIDs: []string{"b", "c"}}, {UUID: "c"}}, result.Lineage.Concepts)
+	assert.Equal(t, []UnresolvedReference{{Field: "supersededByUUIDs", UUID: "b", Reason: "canonical concept b not found in S3"}}, result.Lineage.Unresolved)
+}
+
+func TestAggregateService_ProcessMessage_SupersessionToUnresolvableConcept(t *testing.T) {
+	svc, s3mock, _, _, _, _, _ := setupTestService(200, payload)
+	addSupersededConcept(s3mock, "a", "b")
+
+	err := svc.ProcessMessage(context.Background(), "a", "")
+	assert.NoError(t, err)
+	assert.Contains(t, svc.httpClient.(*mockHTTPClient).called, "concepts-rw-neo4j/topics/a")
+}
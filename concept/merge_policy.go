@@ -0,0 +1,443 @@
+package concept
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/s3"
+)
+
+type MergeStrategy string
+
+const (
+	// LastWinsStrategy takes the last non-empty value in merge order, so the primary source wins if it has one.
+	LastWinsStrategy MergeStrategy = "lastWins"
+	// FirstWinsStrategy takes the first non-empty value in merge order.
+	FirstWinsStrategy MergeStrategy = "firstWins"
+	// PriorityStrategy takes the value from the highest ranked authority in the field's authority list.
+	// Authorities that are not listed rank below listed ones and fall back to LastWinsStrategy between themselves.
+	PriorityStrategy MergeStrategy = "priority"
+	// PrimaryOnlyStrategy takes the value of the canonical source even when it is empty.
+	PrimaryOnlyStrategy MergeStrategy = "primaryOnly"
+	// UnionStrategy combines the values of every source, dropping exact duplicates. List fields only.
+	UnionStrategy MergeStrategy = "union"
+	// LongestStrategy takes the longest value. String and list fields only.
+	LongestStrategy MergeStrategy = "longest"
+	// NewestStrategy takes the greatest value, i.e. the latest ISO 8601 date or the highest year. String and number fields only.
+	NewestStrategy MergeStrategy = "newest"
+)
+
+type FieldPolicy struct {
+	Strategy    MergeStrategy `json:"strategy"`
+	Authorities []string      `json:"authorities,omitempty"`
+}
+
+// MergePolicy declares, by JSON field name of ConcordedConcept, how the values of the source concepts are combined.
+// PrefUUID, Type, Aliases, ScopeNote and SourceRepresentations have dedicated handling and are not part of the policy.
+type MergePolicy struct {
+	Fields map[string]FieldPolicy `json:"fields"`
+}
+
+type fieldKind int
+
+const (
+	stringField fieldKind = iota
+	intField
+	boolField
+	listField
+)
+
+type mergeField struct {
+	name  string
+	kind  fieldKind
+	value func(s s3.Concept) interface{}
+	set   func(c *ConcordedConcept, v interface{})
+}
+
+func stringMergeField(name string, value func(s s3.Concept) string, set func(c *ConcordedConcept, v string)) mergeField {
+	return mergeField{
+		name:  name,
+		kind:  stringField,
+		value: func(s s3.Concept) interface{} { return value(s) },
+		set:   func(c *ConcordedConcept, v interface{}) { set(c, v.(string)) },
+	}
+}
+
+func intMergeField(name string, value func(s s3.Concept) int, set func(c *ConcordedConcept, v int)) mergeField {
+	return mergeField{
+		name:  name,
+		kind:  intField,
+		value: func(s s3.Concept) interface{} { return value(s) },
+		set:   func(c *ConcordedConcept, v interface{}) { set(c, v.(int)) },
+	}
+}
+
+func stringsMergeField(name string, value func(s s3.Concept) []string, set func(c *ConcordedConcept, v []string)) mergeField {
+	return mergeField{
+		name:  name,
+		kind:  listField,
+		value: func(s s3.Concept) interface{} { return value(s) },
+		set:   func(c *ConcordedConcept, v interface{}) { set(c, v.([]string)) },
+	}
+}
+
+var mergeFields = []mergeField{
+	stringMergeField("prefLabel", func(s s3.Concept) string { return s.PrefLabel }, func(c *ConcordedConcept, v string) { c.PrefLabel = v }),
+	stringsMergeField("parentUUIDs", func(s s3.Concept) []string { return s.ParentUUIDs }, func(c *ConcordedConcept, v []string) { c.ParentUUIDs = v }),
+	stringsMergeField("broaderUUIDs", func(s s3.Concept) []string { return s.BroaderUUIDs }, func(c *ConcordedConcept, v []string) { c.BroaderUUIDs = v }),
+	stringsMergeField("relatedUUIDs", func(s s3.Concept) []string { return s.RelatedUUIDs }, func(c *ConcordedConcept, v []string) { c.RelatedUUIDs = v }),
+	stringsMergeField("supersededByUUIDs", func(s s3.Concept) []string { return s.SupersededByUUIDs }, func(c *ConcordedConcept, v []string) { c.SupersededByUUIDs = v }),
+	stringsMergeField("impliedByUUIDs", func(s s3.Concept) []string { return s.ImpliedByUUIDs }, func(c *ConcordedConcept, v []string) { c.ImpliedByUUIDs = v }),
+	stringsMergeField("hasFocusUUIDs", func(s s3.Concept) []string { return s.HasFocusUUIDs }, func(c *ConcordedConcept, v []string) { c.HasFocusUUIDs = v }),
+	stringMergeField("descriptionXML", func(s s3.Concept) string { return s.DescriptionXML }, func(c *ConcordedConcept, v string) { c.DescriptionXML = v }),
+	stringMergeField("_imageUrl", func(s s3.Concept) string { return s.ImageURL }, func(c *ConcordedConcept, v string) { c.ImageURL = v }),
+	stringMergeField("emailAddress", func(s s3.Concept) string { return s.EmailAddress }, func(c *ConcordedConcept, v string) { c.EmailAddress = v }),
+	stringMergeField("facebookPage", func(s s3.Concept) string { return s.FacebookPage }, func(c *ConcordedConcept, v string) { c.FacebookPage = v }),
+	stringMergeField("twitterHandle", func(s s3.Concept) string { return s.TwitterHandle }, func(c *ConcordedConcept, v string) { c.TwitterHandle = v }),
+	stringMergeField("shortLabel", func(s s3.Concept) string { return s.ShortLabel }, func(c *ConcordedConcept, v string) { c.ShortLabel = v }),
+	stringMergeField("strapline", func(s s3.Concept) string { return s.Strapline }, func(c *ConcordedConcept, v string) { c.Strapline = v }),
+	stringMergeField("salutation", func(s s3.Concept) string { return s.Salutation }, func(c *ConcordedConcept, v string) { c.Salutation = v }),
+	intMergeField("birthYear", func(s s3.Concept) int { return s.BirthYear }, func(c *ConcordedConcept, v int) { c.BirthYear = v }),
+	stringMergeField("figiCode", func(s s3.Concept) string { return s.FigiCode }, func(c *ConcordedConcept, v string) { c.FigiCode = v }),
+	stringMergeField("issuedBy", func(s s3.Concept) string { return s.IssuedBy }, func(c *ConcordedConcept, v string) { c.IssuedBy = v }),
+	stringMergeField("inceptionDate", func(s s3.Concept) string { return s.InceptionDate }, func(c *ConcordedConcept, v string) { c.InceptionDate = v }),
+	{
+		name: "membershipRoles",
+		kind: listField,
+		value: func(s s3.Concept) interface{} {
+			var roles []MembershipRole
+			for _, mr := range s.MembershipRoles {
+				roles = append(roles, MembershipRole{
+					RoleUUID:        mr.RoleUUID,
+					InceptionDate:   mr.InceptionDate,
+					TerminationDate: mr.TerminationDate,
+				})
+			}
+			return roles
+		},
+		set: func(c *ConcordedConcept, v interface{}) { c.MembershipRoles = v.([]MembershipRole) },
+	},
+	stringMergeField("organisationUUID", func(s s3.Concept) string { return s.OrganisationUUID }, func(c *ConcordedConcept, v string) { c.OrganisationUUID = v }),
+	stringMergeField("personUUID", func(s s3.Concept) string { return s.PersonUUID }, func(c *ConcordedConcept, v string) { c.PersonUUID = v }),
+	stringMergeField("terminationDate", func(s s3.Concept) string { return s.TerminationDate }, func(c *ConcordedConcept, v string) { c.TerminationDate = v }),
+	stringMergeField("countryCode", func(s s3.Concept) string { return s.CountryCode }, func(c *ConcordedConcept, v string) { c.CountryCode = v }),
+	stringMergeField("countryOfRisk", func(s s3.Concept) string { return s.CountryOfRisk }, func(c *ConcordedConcept, v string) { c.CountryOfRisk = v }),
+	stringMergeField("countryOfIncorporation", func(s s3.Concept) string { return s.CountryOfIncorporation }, func(c *ConcordedConcept, v string) { c.CountryOfIncorporation = v }),
+	stringMergeField("countryOfOperations", func(s s3.Concept) string { return s.CountryOfOperations }, func(c *ConcordedConcept, v string) { c.CountryOfOperations = v }),
+	stringMergeField("countryOfRiskUUID", func(s s3.Concept) string { return s.CountryOfRiskUUID }, func(c *ConcordedConcept, v string) { c.CountryOfRiskUUID = v }),
+	stringMergeField("countryOfIncorporationUUID", func(s s3.Concept) string { return s.CountryOfIncorporationUUID }, func(c *ConcordedConcept, v string) { c.CountryOfIncorporationUUID = v }),
+	stringMergeField("countryOfOperationsUUID", func(s s3.Concept) string { return s.CountryOfOperationsUUID }, func(c *ConcordedConcept, v string) { c.CountryOfOperationsUUID = v }),
+	stringsMergeField("formerNames", func(s s3.Concept) []string { return s.FormerNames }, func(c *ConcordedConcept, v []string) { c.FormerNames = v }),
+	stringsMergeField("tradeNames", func(s s3.Concept) []string { return s.TradeNames }, func(c *ConcordedConcept, v []string) { c.TradeNames = v }),
+	stringMergeField("leiCode", func(s s3.Concept) string { return s.LeiCode }, func(c *ConcordedConcept, v string) { c.LeiCode = v }),
+	stringMergeField("parentOrganisation", func(s s3.Concept) string { return s.ParentOrganisation }, func(c *ConcordedConcept, v string) { c.ParentOrganisation = v }),
+	stringMergeField("postalCode", func(s s3.Concept) string { return s.PostalCode }, func(c *ConcordedConcept, v string) { c.PostalCode = v }),
+	stringMergeField("properName", func(s s3.Concept) string { return s.ProperName }, func(c *ConcordedConcept, v string) { c.ProperName = v }),
+	stringMergeField("shortName", func(s s3.Concept) string { return s.ShortName }, func(c *ConcordedConcept, v string) { c.ShortName = v }),
+	intMergeField("yearFounded", func(s s3.Concept) int { return s.YearFounded }, func(c *ConcordedConcept, v int) { c.YearFounded = v }),
+	{
+		name:  "isDeprecated",
+		kind:  boolField,
+		value: func(s s3.Concept) interface{} { return s.IsDeprecated },
+		set:   func(c *ConcordedConcept, v interface{}) { c.IsDeprecated = v.(bool) },
+	},
+	{
+		name: "naicsIndustryClassifications",
+		kind: listField,
+		value: func(s s3.Concept) interface{} {
+			var ics []NAICSIndustryClassification
+			for _, ic := range s.NAICSIndustryClassifications {
+				ics = append(ics, NAICSIndustryClassification{
+					UUID: ic.UUID,
+					Rank: ic.Rank,
+				})
+			}
+			return ics
+		},
+		set: func(c *ConcordedConcept, v interface{}) {
+			c.NAICSIndustryClassifications = v.([]NAICSIndustryClassification)
+		},
+	},
+	stringMergeField("iso31661", func(s s3.Concept) string { return s.ISO31661 }, func(c *ConcordedConcept, v string) { c.ISO31661 = v }),
+	stringMergeField("industryIdentifier", func(s s3.Concept) string { return s.IndustryIdentifier }, func(c *ConcordedConcept, v string) { c.IndustryIdentifier = v }),
+}
+
+// DefaultMergePolicy reproduces the historic behaviour: the last non-empty value wins, the primary source always
+// provides the preferred label and deprecation flag, and membership roles and NAICS classifications are combined.
+func DefaultMergePolicy() MergePolicy {
+	fields := map[string]FieldPolicy{}
+	for _, f := range mergeFields {
+		fields[f.name] = FieldPolicy{Strategy: LastWinsStrategy}
+	}
+	fields["prefLabel"] = FieldPolicy{Strategy: PrimaryOnlyStrategy}
+	fields["isDeprecated"] = FieldPolicy{Strategy: PrimaryOnlyStrategy}
+	fields["membershipRoles"] = FieldPolicy{Strategy: UnionStrategy}
+	fields["naicsIndustryClassifications"] = FieldPolicy{Strategy: UnionStrategy}
+	return MergePolicy{Fields: fields}
+}
+
+// LoadMergePolicy reads a JSON merge policy from path and lays it over the default policy,
+// so the file only needs to list the fields whose strategy differs. An empty path returns the default policy.
+func LoadMergePolicy(path string) (MergePolicy, error) {
+	policy := DefaultMergePolicy()
+	if path == "" {
+		return policy, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return MergePolicy{}, fmt.Errorf("failed to read merge policy %s: %w", path, err)
+	}
+	var overrides MergePolicy
+	if err = json.Unmarshal(data, &overrides); err != nil {
+		return MergePolicy{}, fmt.Errorf("failed to parse merge policy %s: %w", path, err)
+	}
+	for name, fp := range overrides.Fields {
+		policy.Fields[name] = fp
+	}
+	if err = policy.Validate(); err != nil {
+		return MergePolicy{}, err
+	}
+	return policy, nil
+}
+
+func (p MergePolicy) Validate() error {
+	known := map[string]fieldKind{}
+	for _, f := range mergeFields {
+		known[f.name] = f.kind
+	}
+	names := make([]string, 0, len(p.Fields))
+	for name := range p.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		kind, ok := known[name]
+		if !ok {
+			return fmt.Errorf("merge policy: unknown field %q", name)
+		}
+		fp := p.Fields[name]
+		switch fp.Strategy {
+		case LastWinsStrategy, FirstWinsStrategy, PrimaryOnlyStrategy:
+		case PriorityStrategy:
+			if len(fp.Authorities) == 0 {
+				return fmt.Errorf("merge policy: field %q uses %s without any authorities", name, fp.Strategy)
+			}
+		case UnionStrategy:
+			if kind != listField {
+				return fmt.Errorf("merge policy: %s is only supported on list fields, not %q", fp.Strategy, name)
+			}
+		case LongestStrategy:
+			if kind != stringField && kind != listField {
+				return fmt.Errorf("merge policy: %s is only supported on string and list fields, not %q", fp.Strategy, name)
+			}
+		case NewestStrategy:
+			if kind != stringField && kind != intField {
+				return fmt.Errorf("merge policy: %s is only supported on string and number fields, not %q", fp.Strategy, name)
+			}
+		default:
+			return fmt.Errorf("merge policy: unknown strategy %q for field %q", fp.Strategy, name)
+		}
+	}
+	return nil
+}
+
+// apply sets every policy field of c from sources, which must be in merge order with the canonical source last.
+// It returns the indexes of the sources that supplied each populated field.
+func (p MergePolicy) apply(c *ConcordedConcept, sources []s3.Concept) map[string][]int {
+	suppliers := map[string][]int{}
+	if len(sources) == 0 {
+		return suppliers
+	}
+	for _, f := range mergeFields {
+		fp, ok := p.Fields[f.name]
+		if !ok {
+			fp = FieldPolicy{Strategy: LastWinsStrategy}
+		}
+		values := make([]interface{}, len(sources))
+		for i, s := range sources {
+			values[i] = f.value(s)
+		}
+		v, from := fp.choose(values, sources)
+		if v == nil {
+			continue
+		}
+		f.set(c, v)
+		if len(from) > 0 {
+			suppliers[f.name] = from
+		}
+	}
+	return suppliers
+}
+
+// choose returns the merged value and the indexes of the sources it came from, or nil if no source supplies one.
+func (fp FieldPolicy) choose(values []interface{}, sources []s3.Concept) (interface{}, []int) {
+	switch fp.Strategy {
+	case PrimaryOnlyStrategy:
+		last := len(values) - 1
+		if isEmptyValue(values[last]) {
+			return values[last], nil
+		}
+		return values[last], []int{last}
+	case FirstWinsStrategy:
+		for i, v := range values {
+			if !isEmptyValue(v) {
+				return v, []int{i}
+			}
+		}
+		return nil, nil
+	case PriorityStrategy:
+		best, bestRank := -1, len(fp.Authorities)
+		for i, v := range values {
+			if isEmptyValue(v) {
+				continue
+			}
+			rank := authorityRank(fp.Authorities, sources[i].Authority)
+			if rank <= bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			return nil, nil
+		}
+		return values[best], []int{best}
+	case UnionStrategy:
+		var from []int
+		for i, v := range values {
+			if !isEmptyValue(v) {
+				from = append(from, i)
+			}
+		}
+		return unionValues(values), from
+	case LongestStrategy:
+		best := -1
+		for i, v := range values {
+			if isEmptyValue(v) {
+				continue
+			}
+			if best < 0 || valueLength(v) >= valueLength(values[best]) {
+				best = i
+			}
+		}
+		if best < 0 {
+			return nil, nil
+		}
+		return values[best], []int{best}
+	case NewestStrategy:
+		best := -1
+		for i, v := range values {
+			if isEmptyValue(v) {
+				continue
+			}
+			if best < 0 || !isNewer(values[best], v) {
+				best = i
+			}
+		}
+		if best < 0 {
+			return nil, nil
+		}
+		return values[best], []int{best}
+	default:
+		for i := len(values) - 1; i >= 0; i-- {
+			if !isEmptyValue(values[i]) {
+				return values[i], []int{i}
+			}
+		}
+		return nil, nil
+	}
+}
+
+func authorityRank(authorities []string, authority string) int {
+	for i, a := range authorities {
+		if a == authority {
+			return i
+		}
+	}
+	return len(authorities)
+}
+
+func isEmptyValue(v interface{}) bool {
+	switch t := v.(type) {
+	case string:
+		return t == ""
+	case int:
+		return t == 0
+	case bool:
+		return !t
+	case []string:
+		return len(t) == 0
+	case []MembershipRole:
+		return len(t) == 0
+	case []NAICSIndustryClassification:
+		return len(t) == 0
+	}
+	return v == nil
+}
+
+func valueLength(v interface{}) int {
+	switch t := v.(type) {
+	case string:
+		return len([]rune(t))
+	case []string:
+		return len(t)
+	case []MembershipRole:
+		return len(t)
+	case []NAICSIndustryClassification:
+		return len(t)
+	}
+	return 0
+}
+
+// isNewer reports whether a is strictly newer than b.
+func isNewer(a, b interface{}) bool {
+	switch t := a.(type) {
+	case string:
+		return t > b.(string)
+	case int:
+		return t > b.(int)
+	}
+	return false
+}
+
+func unionValues(values []interface{}) interface{} {
+	switch values[0].(type) {
+	case []string:
+		var out []string
+		seen := map[string]bool{}
+		for _, v := range values {
+			for _, s := range v.([]string) {
+				if !seen[s] {
+					seen[s] = true
+					out = append(out, s)
+				}
+			}
+		}
+		return out
+	case []MembershipRole:
+		var out []MembershipRole
+		seen := map[MembershipRole]bool{}
+		for _, v := range values {
+			for _, mr := range v.([]MembershipRole) {
+				if !seen[mr] {
+					seen[mr] = true
+					out = append(out, mr)
+				}
+			}
+		}
+		return out
+	case []NAICSIndustryClassification:
+		var out []NAICSIndustryClassification
+		seen := map[NAICSIndustryClassification]bool{}
+		for _, v := range values {
+			for _, ic := range v.([]NAICSIndustryClassification) {
+				if !seen[ic] {
+					seen[ic] = true
+					out = append(out, ic)
+				}
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package concept
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePolicy_Strategies(t *testing.T) {
+	sources := []s3.Concept{
+		{UUID: "factset", Authority: "FACTSET", CountryOfIncorporation: "IM", InceptionDate: "2001-01-01", ShortName: "Strix Group", TradeNames: []string{"Strixy"}},
+		{UUID: "tme", Authority: "TME", CountryOfIncorporation: "GB", InceptionDate: "2005-01-01", ShortName: "Strix", TradeNames: []string{"Strixy", "Strix Kettles"}},
+		{UUID: "smartlogic", Authority: "Smartlogic", ShortName: ""},
+	}
+	testCases := map[string]struct {
+		field    string
+		policy   FieldPolicy
+		expected interface{}
+	}{
+		"Last wins skips empty values": {
+			field:    "countryOfIncorporation",
+			policy:   FieldPolicy{Strategy: LastWinsStrategy},
+			expected: "GB",
+		},
+		"First wins": {
+			field:    "countryOfIncorporation",
+			policy:   FieldPolicy{Strategy: FirstWinsStrategy},
+			expected: "IM",
+		},
+		"Priority prefers listed authority": {
+			field:    "countryOfIncorporation",
+			policy:   FieldPolicy{Strategy: PriorityStrategy, Authorities: []string{"FACTSET", "TME"}},
+			expected: "IM",
+		},
+		"Priority falls back to unlisted authorities": {
+			field:    "countryOfIncorporation",
+			policy:   FieldPolicy{Strategy: PriorityStrategy, Authorities: []string{"Wikidata"}},
+			expected: "GB",
+		},
+		"Primary only keeps empty value": {
+			field:    "shortName",
+			policy:   FieldPolicy{Strategy: PrimaryOnlyStrategy},
+			expected: "",
+		},
+		"Longest": {
+			field:    "shortName",
+			policy:   FieldPolicy{Strategy: LongestStrategy},
+			expected: "Strix Group",
+		},
+		"Newest": {
+			field:    "inceptionDate",
+			policy:   FieldPolicy{Strategy: NewestStrategy},
+			expected: "2005-01-01",
+		},
+		"Union": {
+			field:    "tradeNames",
+			policy:   FieldPolicy{Strategy: UnionStrategy},
+			expected: []string{"Strixy", "Strix Kettles"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			policy := DefaultMergePolicy()
+			policy.Fields[tc.field] = tc.policy
+			assert.NoError(t, policy.Validate())
+
+			c := ConcordedConcept{}
+			policy.apply(&c, sources)
+
+			switch tc.field {
+			case "countryOfIncorporation":
+				assert.Equal(t, tc.expected, c.CountryOfIncorporation)
+			case "shortName":
+				assert.Equal(t, tc.expected, c.ShortName)
+			case "inceptionDate":
+				assert.Equal(t, tc.expected, c.InceptionDate)
+			case "tradeNames":
+				assert.Equal(t, tc.expected, c.TradeNames)
+			}
+		})
+	}
+}
+
+func TestMergePolicy_Validate(t *testing.T) {
+	testCases := map[string]struct {
+		fields map[string]FieldPolicy
+		err    string
+	}{
+		"Unknown field": {
+			fields: map[string]FieldPolicy{"colour": {Strategy: LastWinsStrategy}},
+			err:    `merge policy: unknown field "colour"`,
+		},
+		"Unknown strategy": {
+			fields: map[string]FieldPolicy{"leiCode": {Strategy: "random"}},
+			err:    `merge policy: unknown strategy "random" for field "leiCode"`,
+		},
+		"Priority without authorities": {
+			fields: map[string]FieldPolicy{"leiCode": {Strategy: PriorityStrategy}},
+			err:    `merge policy: field "leiCode" uses priority without any authorities`,
+		},
+		"Union on scalar": {
+			fields: map[string]FieldPolicy{"leiCode": {Strategy: UnionStrategy}},
+			err:    `merge policy: union is only supported on list fields, not "leiCode"`,
+		},
+		"Newest on list": {
+			fields: map[string]FieldPolicy{"formerNames": {Strategy: NewestStrategy}},
+			err:    `merge policy: newest is only supported on string and number fields, not "formerNames"`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.EqualError(t, MergePolicy{Fields: tc.fields}.Validate(), tc.err)
+		})
+	}
+	assert.NoError(t, DefaultMergePolicy().Validate())
+}
+
+func TestLoadMergePolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "merge-policy")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	policy, err := LoadMergePolicy("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultMergePolicy(), policy)
+
+	path := filepath.Join(dir, "policy.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"fields": {"countryOfIncorporation": {"strategy": "priority", "authorities": ["FACTSET"]}}}`), 0600))
+	policy, err = LoadMergePolicy(path)
+	assert.NoError(t, err)
+	assert.Equal(t, FieldPolicy{Strategy: PriorityStrategy, Authorities: []string{"FACTSET"}}, policy.Fields["countryOfIncorporation"])
+	assert.Equal(t, FieldPolicy{Strategy: LastWinsStrategy}, policy.Fields["leiCode"])
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"fields": {"leiCode": {"strategy": "union"}}}`), 0600))
+	_, err = LoadMergePolicy(path)
+	assert.Error(t, err)
+
+	_, err = LoadMergePolicy(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestAggregateService_GetConcordedConcept_MergePolicyOverride(t *testing.T) {
+	svc, s3mock, _, _, _, _, _ := setupTestService(200, payload)
+	s3mock.concepts["a141f50f-31d7-4f89-8143-eec971e54ba8"] = struct {
+		transactionID string
+		concept       s3.Concept
+	}{
+		transactionID: "tid_636",
+		concept: s3.Concept{
+			UUID:                   "a141f50f-31d7-4f89-8143-eec971e54ba8",
+			PrefLabel:              "Test FT Concorded Organisation",
+			Authority:              "Smartlogic",
+			AuthValue:              "a141f50f-31d7-4f89-8143-eec971e54ba8",
+			Type:                   "Organisation",
+			CountryOfIncorporation: "GB",
+		},
+	}
+
+	c, _, err := svc.GetConcordedConcept(context.Background(), "a141f50f-31d7-4f89-8143-eec971e54ba8", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "GB", c.CountryOfIncorporation)
+
+	svc.mergePolicy.Fields["countryOfIncorporation"] = FieldPolicy{Strategy: PriorityStrategy, Authorities: []string{"FACTSET"}}
+	c, _, err = svc.GetConcordedConcept(context.Background(), "a141f50f-31d7-4f89-8143-eec971e54ba8", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "IM", c.CountryOfIncorporation)
+}
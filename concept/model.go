@@ -26,6 +26,8 @@ type ConcordedConcept struct {
 	BroaderUUIDs      []string `json:"broaderUUIDs,omitempty"`
 	RelatedUUIDs      []string `json:"relatedUUIDs,omitempty"`
 	SupersededByUUIDs []string `json:"supersededByUUIDs,omitempty"`
+	ImpliedByUUIDs    []string `json:"impliedByUUIDs,omitempty"`
+	HasFocusUUIDs     []string `json:"hasFocusUUIDs,omitempty"`
 	DescriptionXML    string   `json:"descriptionXML,omitempty"`
 	ImageURL          string   `json:"_imageUrl,omitempty"`
 	EmailAddress      string   `json:"emailAddress,omitempty"`
@@ -52,9 +54,13 @@ type ConcordedConcept struct {
 	CountryOfRisk                string                        `json:"countryOfRisk,omitempty"`
 	CountryOfIncorporation       string                        `json:"countryOfIncorporation,omitempty"`
 	CountryOfOperations          string                        `json:"countryOfOperations,omitempty"`
+	CountryOfRiskUUID            string                        `json:"countryOfRiskUUID,omitempty"`
+	CountryOfIncorporationUUID   string                        `json:"countryOfIncorporationUUID,omitempty"`
+	CountryOfOperationsUUID      string                        `json:"countryOfOperationsUUID,omitempty"`
 	FormerNames                  []string                      `json:"formerNames,omitempty"`
 	TradeNames                   []string                      `json:"tradeNames,omitempty"`
 	LeiCode                      string                        `json:"leiCode,omitempty"`
+	ParentOrganisation           string                        `json:"parentOrganisation,omitempty"`
 	PostalCode                   string                        `json:"postalCode,omitempty"`
 	ProperName                   string                        `json:"properName,omitempty"`
 	ShortName                    string                        `json:"shortName,omitempty"`
@@ -65,6 +71,28 @@ type ConcordedConcept struct {
 	ISO31661 string `json:"iso31661,omitempty"`
 	// IndustryClassification
 	IndustryIdentifier string `json:"industryIdentifier,omitempty"`
+	// Supersession, the concepts at the ends of the chains starting from SupersededByUUIDs
+	TerminalSupersededByUUIDs []string `json:"terminalSupersededByUUIDs,omitempty"`
 	// Source representations
 	SourceRepresentations []s3.Concept `json:"sourceRepresentations,omitempty"`
 }
+
+// FieldProvenance identifies the source concept a field of the concorded concept was taken from.
+type FieldProvenance struct {
+	Authority     string `json:"authority"`
+	SourceUUID    string `json:"sourceUUID"`
+	TransactionID string `json:"transactionID,omitempty"`
+}
+
+// Provenance lists, by JSON field name, the sources that supplied each populated field of a concorded concept.
+type Provenance map[string][]FieldProvenance
+
+// AggregationResult is the concorded concept together with the details of how it was aggregated.
+type AggregationResult struct {
+	Concept       ConcordedConcept
+	TransactionID string
+	Provenance    Provenance
+	Conflicts     []Conflict
+	Unresolved    []UnresolvedReference
+	Lineage       Lineage
+}
@@ -0,0 +1,82 @@
+package concept
+
+import (
+	"context"
+)
+
+// Plan describes what sending a concept through the sink pipeline would do, without calling any sink.
+type Plan struct {
+	PrefUUID      string      `json:"prefUUID"`
+	TransactionID string      `json:"transactionID"`
+	Warnings      []Violation `json:"warnings,omitempty"`
+	// Unchanged is set when the concept is unchanged since it was last written, in which case no sink is called.
+	Unchanged bool `json:"unchanged"`
+	// UpdatedIDs are the concepts Neo4j is expected to report as updated. They are predicted from the source
+	// concepts, unless they were recorded by an earlier attempt that failed.
+	UpdatedIDs []string   `json:"updatedIDs"`
+	Sinks      []SinkPlan `json:"sinks"`
+}
+
+// SinkPlan describes what a sink in the pipeline would send.
+type SinkPlan struct {
+	Sink       string `json:"sink"`
+	BestEffort bool   `json:"bestEffort,omitempty"`
+	// Skipped is the reason the sink would not be called.
+	Skipped  string           `json:"skipped,omitempty"`
+	Requests []PlannedRequest `json:"requests,omitempty"`
+	// Payload is the message that would be sent to a queue or stream.
+	Payload interface{} `json:"payload,omitempty"`
+	Note    string      `json:"note,omitempty"`
+}
+
+// PlannedRequest is an HTTP request a sink would make.
+type PlannedRequest struct {
+	Method       string            `json:"method"`
+	URL          string            `json:"url"`
+	Body         *ConcordedConcept `json:"body,omitempty"`
+	PurgeTargets []string          `json:"purgeTargets,omitempty"`
+}
+
+// PlanMessage aggregates and checks the concept of UUID as ProcessMessage does, and returns what each sink in the
+// pipeline would send for it instead of sending it.
+func (s *AggregateService) PlanMessage(ctx context.Context, UUID string, bookmark string) (Plan, error) {
+	concordedConcept, transactionID, warnings, err := s.prepare(ctx, UUID, bookmark)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	plan := Plan{
+		PrefUUID:      concordedConcept.PrefUUID,
+		TransactionID: transactionID,
+		Warnings:      warnings,
+		Sinks:         []SinkPlan{},
+	}
+	if _, plan.Unchanged = s.isUnchanged(concordedConcept); plan.Unchanged {
+		plan.UpdatedIDs = []string{}
+		return plan, nil
+	}
+
+	write := &SinkWrite{Concept: concordedConcept, TransactionID: transactionID}
+	s.checkpoints.restore(write)
+	if len(write.Changes.UpdatedIds) == 0 {
+		write.Changes.UpdatedIds = predictedUpdatedIDs(concordedConcept)
+	}
+	plan.UpdatedIDs = write.Changes.UpdatedIds
+
+	if plan.Sinks, err = s.sinkPipeline.plan(s.sinks, write); err != nil {
+		return Plan{}, err
+	}
+	return plan, nil
+}
+
+// predictedUpdatedIDs returns the concepts Neo4j reports as updated when c is written: the canonical concept and
+// each of its source concepts.
+func predictedUpdatedIDs(c ConcordedConcept) []string {
+	ids := []string{c.PrefUUID}
+	for _, src := range c.SourceRepresentations {
+		if !contains(src.UUID, ids) {
+			ids = append(ids, src.UUID)
+		}
+	}
+	return ids
+}
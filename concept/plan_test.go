@@ -0,0 +1,169 @@
+package concept
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/s3"
+	"github.com/Financial-Times/aggregate-concept-transformer/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateService_PlanMessage(t *testing.T) {
+	const UUID = "6562674e-dbfa-4cb0-85b2-41b0948b7cc2"
+	const issuer = "4e484678-cf47-4168-b844-6adb47f8eb58"
+	svc, _, _, eventsQueue, kinesis, _, _ := setupTestService(200, payload)
+	c, _, err := svc.GetConcordedConcept(context.Background(), UUID, "")
+	assert.NoError(t, err)
+
+	plan, err := svc.PlanMessage(context.Background(), UUID, "")
+	assert.NoError(t, err)
+
+	assert.Empty(t, svc.httpClient.(*mockHTTPClient).called, "no writer or purger should be called")
+	assert.Empty(t, eventsQueue.eventList, "no events should be sent")
+	assert.Empty(t, kinesis.records, "no records should be added to the stream")
+
+	assert.Equal(t, UUID, plan.PrefUUID)
+	assert.Equal(t, "tid_630", plan.TransactionID)
+	assert.False(t, plan.Unchanged)
+	assert.Equal(t, []string{UUID}, plan.UpdatedIDs)
+	assert.Equal(t, []SinkPlan{
+		{
+			Sink:     Neo4jSink,
+			Requests: []PlannedRequest{{Method: "PUT", URL: "concepts-rw-neo4j/financial-instruments/" + UUID, Body: &c}},
+			Note:     "the sinks after it are skipped if Neo4j reports no changes",
+		},
+		{
+			Sink:       VarnishSink,
+			BestEffort: true,
+			Requests: []PlannedRequest{
+				{
+					Method:       "POST",
+					URL:          "varnish-purger/purge?target=%2Fthings%2F" + UUID + "&target=%2Fconcepts%2F" + UUID,
+					PurgeTargets: []string{"/things/" + UUID, "/concepts/" + UUID},
+				},
+				{
+					Method: "POST",
+					URL: "varnish-purger/purge?target=%2Fthings%2F" + issuer + "&target=%2Fconcepts%2F" + issuer +
+						"&target=%2Forganisations%2F" + issuer,
+					PurgeTargets: []string{"/things/" + issuer, "/concepts/" + issuer, "/organisations/" + issuer},
+				},
+			},
+		},
+		{
+			Sink:    ElasticsearchSink,
+			Skipped: "concepts of type FinancialInstrument are not written to Elasticsearch",
+		},
+		{
+			Sink: EventsSink,
+			Payload: []sqs.Event{{
+				ConceptType:   "FinancialInstrument",
+				ConceptUUID:   UUID,
+				TransactionID: "tid_630",
+				EventDetails:  sqs.ConceptEvent{Type: "Concept Updated"},
+			}},
+			Note: "the events sent are those Neo4j reports as changed",
+		},
+		{
+			Sink:    KinesisSink,
+			Payload: []string{UUID},
+			Note:    "sent to the stream with partition key FinancialInstrument",
+		},
+	}, plan.Sinks)
+}
+
+func TestAggregateService_PlanMessage_PredictsUpdatedIDsFromSources(t *testing.T) {
+	svc, _, _, _, _, _, _ := setupTestService(200, payload)
+
+	plan, err := svc.PlanMessage(context.Background(), "28090964-9997-4bc2-9638-7a11135aaff9", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"28090964-9997-4bc2-9638-7a11135aaff9", "34a571fb-d779-4610-a7ba-2e127676db4d"}, plan.UpdatedIDs)
+	assert.Equal(t, []string{
+		"/things/28090964-9997-4bc2-9638-7a11135aaff9",
+		"/concepts/28090964-9997-4bc2-9638-7a11135aaff9",
+		"/things/34a571fb-d779-4610-a7ba-2e127676db4d",
+		"/concepts/34a571fb-d779-4610-a7ba-2e127676db4d",
+		"/people/28090964-9997-4bc2-9638-7a11135aaff9",
+		"/people/34a571fb-d779-4610-a7ba-2e127676db4d",
+	}, plan.Sinks[1].Requests[0].PurgeTargets)
+	assert.Equal(t, "concept-rw-elasticsearch/people/28090964-9997-4bc2-9638-7a11135aaff9", plan.Sinks[2].Requests[0].URL)
+	assert.Empty(t, svc.httpClient.(*mockHTTPClient).called)
+}
+
+func TestAggregateService_PlanMessage_Unchanged(t *testing.T) {
+	const UUID = "6562674e-dbfa-4cb0-85b2-41b0948b7cc2"
+	svc, _, _, _, _, _, _ := setupTestService(200, payload)
+	WithHashStore(NewMemoryHashStore())(svc)
+	assert.NoError(t, svc.ProcessMessage(context.Background(), UUID, ""))
+	svc.httpClient.(*mockHTTPClient).called = []string{}
+
+	plan, err := svc.PlanMessage(context.Background(), UUID, "")
+	assert.NoError(t, err)
+	assert.True(t, plan.Unchanged)
+	assert.Empty(t, plan.Sinks)
+	assert.Empty(t, svc.httpClient.(*mockHTTPClient).called)
+}
+
+func TestAggregateService_PlanMessage_Checkpoint(t *testing.T) {
+	const UUID = "6562674e-dbfa-4cb0-85b2-41b0948b7cc2"
+	svc, _, _, _, _, _, _ := setupTestService(200, payload)
+	events := []sqs.Event{{ConceptType: "FinancialInstrument", ConceptUUID: UUID, AggregateHash: "hash", TransactionID: "tid_630"}}
+	svc.checkpoints.save(&SinkWrite{
+		Concept:       ConcordedConcept{PrefUUID: UUID},
+		TransactionID: "tid_630",
+		Changes:       sqs.ConceptChanges{ChangedRecords: events, UpdatedIds: []string{UUID, "recorded"}},
+		Completed:     []string{Neo4jSink, VarnishSink},
+	})
+
+	plan, err := svc.PlanMessage(context.Background(), UUID, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{UUID, "recorded"}, plan.UpdatedIDs)
+	assert.Equal(t, "sink completed before an earlier attempt failed", plan.Sinks[0].Skipped)
+	assert.Equal(t, "sink completed before an earlier attempt failed", plan.Sinks[1].Skipped)
+	assert.Equal(t, events, plan.Sinks[3].Payload)
+	assert.Equal(t, []string{UUID, "recorded"}, plan.Sinks[4].Payload)
+}
+
+func TestAggregateService_PlanMessage_CustomPipeline(t *testing.T) {
+	disabled := false
+	svc, _, _, _, _, _, _ := setupTestService(200, payload)
+	var sent []string
+	WithSink(&recordingSink{name: "audit", sent: &sent})(svc)
+	WithSinkPipeline(SinkPipeline{Sinks: []SinkConfig{
+		{Name: Neo4jSink, Enabled: &disabled},
+		{Name: ElasticsearchSink, ExcludeTypes: []string{"FinancialInstrument"}},
+		{Name: "audit"},
+	}})(svc)
+
+	plan, err := svc.PlanMessage(context.Background(), "6562674e-dbfa-4cb0-85b2-41b0948b7cc2", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []SinkPlan{
+		{Sink: Neo4jSink, Skipped: "sink is disabled"},
+		{Sink: ElasticsearchSink, Skipped: "sink does not take concepts of type FinancialInstrument"},
+		{Sink: "audit", Note: "sink cannot describe what it would send"},
+	}, plan.Sinks)
+	assert.Empty(t, sent)
+}
+
+func TestAggregateService_PlanMessage_InvalidConcept(t *testing.T) {
+	svc, s3mock, _, _, _, _, _ := setupTestService(200, payload)
+	s3mock.concepts["e9a4f0c2-3d5b-4a5e-8b0a-6a7f3f4d2c11"] = struct {
+		transactionID string
+		concept       s3.Concept
+	}{
+		transactionID: "tid_422",
+		concept: s3.Concept{
+			UUID:             "e9a4f0c2-3d5b-4a5e-8b0a-6a7f3f4d2c11",
+			PrefLabel:        "Membership without a person",
+			Authority:        "Smartlogic",
+			AuthValue:        "e9a4f0c2-3d5b-4a5e-8b0a-6a7f3f4d2c11",
+			Type:             "Membership",
+			OrganisationUUID: "a4528fc9-0615-4bfa-bc99-596ea1ddec28",
+		},
+	}
+
+	_, err := svc.PlanMessage(context.Background(), "e9a4f0c2-3d5b-4a5e-8b0a-6a7f3f4d2c11", "")
+	var validationErr *ValidationError
+	assert.True(t, errors.As(err, &validationErr))
+}
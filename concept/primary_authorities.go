@@ -0,0 +1,76 @@
+package concept
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/concordances"
+	logger "github.com/Financial-Times/go-logger"
+)
+
+// PrimaryAuthorities are the curated authorities whose concept becomes the canonical concept of a concordance,
+// highest priority first. A concordance may hold at most one record of each primary authority.
+type PrimaryAuthorities []string
+
+// DefaultPrimaryAuthorities prefer Smartlogic over ManagedLocation.
+func DefaultPrimaryAuthorities() PrimaryAuthorities {
+	return PrimaryAuthorities{smartlogicAuthority, managedLocationAuthority}
+}
+
+// Validate checks that the authorities are not blank and are listed only once.
+func (p PrimaryAuthorities) Validate() error {
+	seen := map[string]bool{}
+	for i, authority := range p {
+		if strings.TrimSpace(authority) == "" {
+			return fmt.Errorf("primary authorities: authority %d is blank", i)
+		}
+		if seen[authority] {
+			return fmt.Errorf("primary authorities: %q is listed more than once", authority)
+		}
+		seen[authority] = true
+	}
+	return nil
+}
+
+// bucketConcordances groups the concordance records by authority and returns the highest priority primary
+// authority present, or an empty string when there is none.
+func bucketConcordances(concordanceRecords []concordances.ConcordanceRecord, primaryAuthorities PrimaryAuthorities) (map[string][]concordances.ConcordanceRecord, string, error) {
+	if concordanceRecords == nil || len(concordanceRecords) == 0 {
+		err := fmt.Errorf("no concordances provided")
+		logger.WithError(err).Error("Error grouping concordance records")
+		return nil, "", err
+	}
+
+	bucketedConcordances := map[string][]concordances.ConcordanceRecord{}
+	for _, v := range concordanceRecords {
+		bucketedConcordances[v.Authority] = append(bucketedConcordances[v.Authority], v)
+	}
+
+	var primaryAuthority string
+	var err error
+	var found []string
+	for _, authority := range primaryAuthorities {
+		records, ok := bucketedConcordances[authority]
+		if !ok {
+			continue
+		}
+		found = append(found, fmt.Sprintf("%s=%v", authority, records))
+		if len(records) > 1 {
+			if err == nil {
+				err = fmt.Errorf("more than 1 %s primary authority", authority)
+			}
+			continue
+		}
+		if primaryAuthority == "" {
+			primaryAuthority = authority
+		}
+	}
+	if err != nil {
+		logger.WithError(err).
+			WithField("alert_tag", "AggregateConceptTransformerMultiplePrimaryAuthorities").
+			WithField("primary_authorities", strings.Join(found, ", ")).
+			Error("Error grouping concordance records")
+		return nil, "", err
+	}
+	return bucketedConcordances, primaryAuthority, nil
+}
@@ -0,0 +1,113 @@
+package concept
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/concordances"
+	"github.com/Financial-Times/aggregate-concept-transformer/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketConcordances(t *testing.T) {
+	testCases := map[string]struct {
+		authorities PrimaryAuthorities
+		records     []concordances.ConcordanceRecord
+		primary     string
+		err         string
+	}{
+		"No primary authority": {
+			authorities: DefaultPrimaryAuthorities(),
+			records:     []concordances.ConcordanceRecord{{UUID: "1", Authority: "TME"}, {UUID: "2", Authority: "FACTSET"}},
+		},
+		"Smartlogic preferred over ManagedLocation": {
+			authorities: DefaultPrimaryAuthorities(),
+			records:     []concordances.ConcordanceRecord{{UUID: "1", Authority: "ManagedLocation"}, {UUID: "2", Authority: "Smartlogic"}},
+			primary:     "Smartlogic",
+		},
+		"Configured priority": {
+			authorities: PrimaryAuthorities{"ManagedLocation", "Smartlogic"},
+			records:     []concordances.ConcordanceRecord{{UUID: "1", Authority: "ManagedLocation"}, {UUID: "2", Authority: "Smartlogic"}},
+			primary:     "ManagedLocation",
+		},
+		"New curated vocabulary": {
+			authorities: PrimaryAuthorities{"Smartlogic", "FTCurated"},
+			records:     []concordances.ConcordanceRecord{{UUID: "1", Authority: "TME"}, {UUID: "2", Authority: "FTCurated"}},
+			primary:     "FTCurated",
+		},
+		"Unlisted authority is not primary": {
+			authorities: PrimaryAuthorities{"Smartlogic"},
+			records:     []concordances.ConcordanceRecord{{UUID: "1", Authority: "ManagedLocation"}},
+		},
+		"More than one record of a primary authority": {
+			authorities: DefaultPrimaryAuthorities(),
+			records:     []concordances.ConcordanceRecord{{UUID: "1", Authority: "Smartlogic"}, {UUID: "2", Authority: "ManagedLocation"}, {UUID: "3", Authority: "ManagedLocation"}},
+			err:         "more than 1 ManagedLocation primary authority",
+		},
+		"No records": {
+			authorities: DefaultPrimaryAuthorities(),
+			err:         "no concordances provided",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			bucketed, primary, err := bucketConcordances(tc.records, tc.authorities)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.primary, primary)
+			assert.Len(t, bucketed, len(tc.records))
+		})
+	}
+}
+
+func TestPrimaryAuthorities_Validate(t *testing.T) {
+	assert.NoError(t, DefaultPrimaryAuthorities().Validate())
+	assert.EqualError(t, PrimaryAuthorities{"Smartlogic", " "}.Validate(), "primary authorities: authority 1 is blank")
+	assert.EqualError(t, PrimaryAuthorities{"Smartlogic", "Smartlogic"}.Validate(), `primary authorities: "Smartlogic" is listed more than once`)
+}
+
+func TestAggregateService_GetConcordedConcept_ConfiguredPrimaryAuthority(t *testing.T) {
+	svc, s3mock, _, _, _, _, _ := setupTestService(200, payload)
+	svc.primaryAuthorities = PrimaryAuthorities{"Smartlogic", "FTCurated"}
+	svc.concordances.(*mockConcordancesClient).concordances["7c4b3a2e-1f0d-4c9b-8a7e-6d5c4b3a2f10"] = []concordances.ConcordanceRecord{
+		{UUID: "7c4b3a2e-1f0d-4c9b-8a7e-6d5c4b3a2f10", Authority: "FTCurated"},
+		{UUID: "e1d2c3b4-a5f6-3e7d-8c9b-0a1f2e3d4c5b", Authority: "TME"},
+	}
+	s3mock.concepts["7c4b3a2e-1f0d-4c9b-8a7e-6d5c4b3a2f10"] = struct {
+		transactionID string
+		concept       s3.Concept
+	}{
+		transactionID: "tid_1001",
+		concept: s3.Concept{
+			UUID:      "7c4b3a2e-1f0d-4c9b-8a7e-6d5c4b3a2f10",
+			PrefLabel: "Climate change",
+			Authority: "FTCurated",
+			AuthValue: "7c4b3a2e-1f0d-4c9b-8a7e-6d5c4b3a2f10",
+			Type:      "Topic",
+		},
+	}
+	s3mock.concepts["e1d2c3b4-a5f6-3e7d-8c9b-0a1f2e3d4c5b"] = struct {
+		transactionID string
+		concept       s3.Concept
+	}{
+		transactionID: "tid_1002",
+		concept: s3.Concept{
+			UUID:      "e1d2c3b4-a5f6-3e7d-8c9b-0a1f2e3d4c5b",
+			PrefLabel: "Global warming",
+			Authority: "TME",
+			AuthValue: "R2xvYmFsIHdhcm1pbmc=-VG9waWNz",
+			Type:      "Topic",
+		},
+	}
+
+	c, transactionID, err := svc.GetConcordedConcept(context.Background(), "7c4b3a2e-1f0d-4c9b-8a7e-6d5c4b3a2f10", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "7c4b3a2e-1f0d-4c9b-8a7e-6d5c4b3a2f10", c.PrefUUID)
+	assert.Equal(t, "Climate change", c.PrefLabel)
+	assert.Equal(t, "tid_1001", transactionID)
+	assert.Equal(t, []string{"TME", "FTCurated"}, []string{c.SourceRepresentations[0].Authority, c.SourceRepresentations[1].Authority})
+}
@@ -0,0 +1,129 @@
+package concept
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	logger "github.com/Financial-Times/go-logger"
+)
+
+const defaultPurgeBatchSize = 50
+
+type purgeRequest struct {
+	targets []string
+	result  chan map[string]error
+}
+
+// purgeCoalescer collects the targets purged by concurrent workers over a window, and purges each target once per
+// window in requests of up to maxTargets targets. Targets asked for while a window is being purged go into the
+// next window, so that they are purged after the change that asked for them. Each window is purged from its own
+// goroutine, so windows may overlap and are not guaranteed to finish in the order they were collected.
+type purgeCoalescer struct {
+	client     httpClient
+	address    string
+	window     time.Duration
+	maxTargets int
+	timeout    time.Duration
+	requests   chan purgeRequest
+}
+
+func newPurgeCoalescer(client httpClient, address string, window time.Duration, maxTargets int, timeout time.Duration) *purgeCoalescer {
+	if maxTargets <= 0 {
+		maxTargets = defaultPurgeBatchSize
+	}
+	return &purgeCoalescer{
+		client:     client,
+		address:    address,
+		window:     window,
+		maxTargets: maxTargets,
+		timeout:    timeout,
+		requests:   make(chan purgeRequest),
+	}
+}
+
+// purge waits for targets to be purged and returns the error of each target that failed. An error is returned
+// when ctx is done first, in which case the targets may still be purged.
+func (c *purgeCoalescer) purge(ctx context.Context, targets []string) (map[string]error, error) {
+	r := purgeRequest{targets: targets, result: make(chan map[string]error, 1)}
+	select {
+	case c.requests <- r:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case failures := <-r.result:
+		return failures, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// run collects the requests of each window and purges them, for the life of the service. A window starts with
+// the first request after the previous window.
+func (c *purgeCoalescer) run() {
+	for {
+		batch := []purgeRequest{<-c.requests}
+		timer := time.NewTimer(c.window)
+	collect:
+		for {
+			select {
+			case r := <-c.requests:
+				batch = append(batch, r)
+			case <-timer.C:
+				break collect
+			}
+		}
+		go c.send(batch)
+	}
+}
+
+// send purges the distinct targets of batch and hands each request the failures of its own targets.
+func (c *purgeCoalescer) send(batch []purgeRequest) {
+	var targets []string
+	seen := map[string]bool{}
+	for _, r := range batch {
+		for _, target := range r.targets {
+			if !seen[target] {
+				seen[target] = true
+				targets = append(targets, target)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	failures := map[string]error{}
+	for start := 0; start < len(targets); start += c.maxTargets {
+		end := start + c.maxTargets
+		if end > len(targets) {
+			end = len(targets)
+		}
+		wg.Add(1)
+		go func(chunk []string) {
+			defer wg.Done()
+			if err := postPurge(ctx, c.client, c.address, chunk); err != nil {
+				logger.WithError(err).WithField("targets", chunk).Error("Targets couldn't be purged from Varnish cache")
+				mu.Lock()
+				for _, target := range chunk {
+					failures[target] = err
+				}
+				mu.Unlock()
+			}
+		}(targets[start:end])
+	}
+	wg.Wait()
+	logger.WithField("requests", len(batch)).WithField("targets", len(targets)).Debug("Coalesced purge of varnish cache finished")
+
+	for _, r := range batch {
+		failed := map[string]error{}
+		for _, target := range r.targets {
+			if err, ok := failures[target]; ok {
+				failed[target] = err
+			}
+		}
+		r.result <- failed
+	}
+}
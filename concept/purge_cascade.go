@@ -0,0 +1,122 @@
+package concept
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+// CascadeRule names a field of the merged concept, by its JSON name, holding the UUIDs of related concepts of
+// Type. Type decides whether the public endpoint of the related concepts is purged too.
+type CascadeRule struct {
+	Field string `json:"field"`
+	Type  string `json:"type"`
+}
+
+// PurgeCascade declares, by concept type, the related concepts whose cached URLs are purged along with a concept.
+type PurgeCascade struct {
+	Rules map[string][]CascadeRule `json:"rules"`
+}
+
+// cascadeFields are the fields of ConcordedConcept holding the UUIDs of related concepts.
+var cascadeFields = map[string]func(c ConcordedConcept) []string{
+	"issuedBy":                   func(c ConcordedConcept) []string { return []string{c.IssuedBy} },
+	"personUUID":                 func(c ConcordedConcept) []string { return []string{c.PersonUUID} },
+	"organisationUUID":           func(c ConcordedConcept) []string { return []string{c.OrganisationUUID} },
+	"parentOrganisation":         func(c ConcordedConcept) []string { return []string{c.ParentOrganisation} },
+	"countryOfRiskUUID":          func(c ConcordedConcept) []string { return []string{c.CountryOfRiskUUID} },
+	"countryOfIncorporationUUID": func(c ConcordedConcept) []string { return []string{c.CountryOfIncorporationUUID} },
+	"countryOfOperationsUUID":    func(c ConcordedConcept) []string { return []string{c.CountryOfOperationsUUID} },
+	"parentUUIDs":                func(c ConcordedConcept) []string { return c.ParentUUIDs },
+	"broaderUUIDs":               func(c ConcordedConcept) []string { return c.BroaderUUIDs },
+	"relatedUUIDs":               func(c ConcordedConcept) []string { return c.RelatedUUIDs },
+	"impliedByUUIDs":             func(c ConcordedConcept) []string { return c.ImpliedByUUIDs },
+	"hasFocusUUIDs":              func(c ConcordedConcept) []string { return c.HasFocusUUIDs },
+	"supersededByUUIDs":          func(c ConcordedConcept) []string { return c.SupersededByUUIDs },
+	"membershipRoles": func(c ConcordedConcept) []string {
+		var uuids []string
+		for _, mr := range c.MembershipRoles {
+			uuids = append(uuids, mr.RoleUUID)
+		}
+		return uuids
+	},
+}
+
+// DefaultPurgeCascade purges the issuer of a financial instrument, and the person and organisation of a membership.
+func DefaultPurgeCascade() PurgeCascade {
+	return PurgeCascade{
+		Rules: map[string][]CascadeRule{
+			"FinancialInstrument": {{Field: "issuedBy", Type: "Organisation"}},
+			"Membership": {
+				{Field: "personUUID", Type: "Person"},
+				{Field: "organisationUUID", Type: "Organisation"},
+			},
+		},
+	}
+}
+
+// LoadPurgeCascade reads the JSON file at path and lays its rules over the default cascade, replacing the rules of
+// any type it declares. A type declared with no rules purges no related concepts. An empty path returns
+// DefaultPurgeCascade.
+func LoadPurgeCascade(path string) (PurgeCascade, error) {
+	cascade := DefaultPurgeCascade()
+	if path == "" {
+		return cascade, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return PurgeCascade{}, fmt.Errorf("failed to read purge cascade %s: %w", path, err)
+	}
+	var overrides PurgeCascade
+	if err = json.Unmarshal(data, &overrides); err != nil {
+		return PurgeCascade{}, fmt.Errorf("failed to parse purge cascade %s: %w", path, err)
+	}
+	for conceptType, rules := range overrides.Rules {
+		cascade.Rules[conceptType] = rules
+	}
+	return cascade, cascade.Validate()
+}
+
+// Validate checks that every rule names a known field and the type of the related concepts.
+func (p PurgeCascade) Validate() error {
+	types := make([]string, 0, len(p.Rules))
+	for conceptType := range p.Rules {
+		types = append(types, conceptType)
+	}
+	sort.Strings(types)
+	for _, conceptType := range types {
+		for _, rule := range p.Rules[conceptType] {
+			if _, ok := cascadeFields[rule.Field]; !ok {
+				return fmt.Errorf("purge cascade: unknown field %q for type %q", rule.Field, conceptType)
+			}
+			if rule.Type == "" {
+				return fmt.Errorf("purge cascade: field %q of type %q has no related concept type", rule.Field, conceptType)
+			}
+		}
+	}
+	return nil
+}
+
+// related returns a purge group for each rule of the type of c whose field holds any UUIDs. Empty UUIDs are left
+// out.
+func (p PurgeCascade) related(c ConcordedConcept) []purgeGroup {
+	var groups []purgeGroup
+	for _, rule := range p.Rules[c.Type] {
+		var uuids []string
+		for _, uuid := range cascadeFields[rule.Field](c) {
+			if uuid != "" {
+				uuids = append(uuids, uuid)
+			}
+		}
+		if len(uuids) == 0 {
+			continue
+		}
+		label := uuids[0]
+		if len(uuids) > 1 {
+			label = rule.Field
+		}
+		groups = append(groups, purgeGroup{label: label, uuids: uuids, conceptType: rule.Type})
+	}
+	return groups
+}
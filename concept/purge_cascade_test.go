@@ -0,0 +1,100 @@
+package concept
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurgeCascade_FinancialInstrumentIssuer(t *testing.T) {
+	cascade := DefaultPurgeCascade()
+
+	groups := cascade.related(ConcordedConcept{PrefUUID: "instrument", Type: "FinancialInstrument", IssuedBy: "issuer"})
+	assert.Equal(t, []purgeGroup{{label: "issuer", uuids: []string{"issuer"}, conceptType: "Organisation"}}, groups)
+
+	groups = cascade.related(ConcordedConcept{PrefUUID: "instrument", Type: "FinancialInstrument"})
+	assert.Empty(t, groups, "an instrument without an issuer should purge no related concepts")
+}
+
+func TestPurgeCascade_MembershipPersonAndOrganisation(t *testing.T) {
+	cascade := DefaultPurgeCascade()
+
+	groups := cascade.related(ConcordedConcept{PrefUUID: "membership", Type: "Membership", PersonUUID: "person", OrganisationUUID: "organisation"})
+	assert.Equal(t, []purgeGroup{
+		{label: "person", uuids: []string{"person"}, conceptType: "Person"},
+		{label: "organisation", uuids: []string{"organisation"}, conceptType: "Organisation"},
+	}, groups)
+
+	groups = cascade.related(ConcordedConcept{PrefUUID: "membership", Type: "Membership", PersonUUID: "person"})
+	assert.Equal(t, []purgeGroup{{label: "person", uuids: []string{"person"}, conceptType: "Person"}}, groups)
+}
+
+func TestPurgeCascade_TypesWithoutRules(t *testing.T) {
+	groups := DefaultPurgeCascade().related(ConcordedConcept{PrefUUID: "person", Type: "Person", OrganisationUUID: "organisation"})
+	assert.Empty(t, groups)
+}
+
+func TestPurgeCascade_ListField(t *testing.T) {
+	cascade := PurgeCascade{Rules: map[string][]CascadeRule{"Topic": {{Field: "broaderUUIDs", Type: "Topic"}}}}
+
+	groups := cascade.related(ConcordedConcept{PrefUUID: "topic", Type: "Topic", BroaderUUIDs: []string{"a", "", "b"}})
+	assert.Equal(t, []purgeGroup{{label: "broaderUUIDs", uuids: []string{"a", "b"}, conceptType: "Topic"}}, groups)
+}
+
+func TestLoadPurgeCascade(t *testing.T) {
+	dir, err := ioutil.TempDir("", "purge-cascade")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := LoadPurgeCascade("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultPurgeCascade(), c)
+	assert.NoError(t, c.Validate())
+
+	path := filepath.Join(dir, "cascade.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"rules": {"Membership": [{"field": "personUUID", "type": "Person"}], "FinancialInstrument": []}}`), 0600))
+	c, err = LoadPurgeCascade(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []CascadeRule{{Field: "personUUID", Type: "Person"}}, c.Rules["Membership"])
+	assert.Empty(t, c.related(ConcordedConcept{Type: "FinancialInstrument", IssuedBy: "issuer"}))
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"rules": {"Membership": [{"field": "prefLabel", "type": "Person"}]}}`), 0600))
+	_, err = LoadPurgeCascade(path)
+	assert.EqualError(t, err, `purge cascade: unknown field "prefLabel" for type "Membership"`)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"rules": {"Membership": [{"field": "personUUID"}]}}`), 0600))
+	_, err = LoadPurgeCascade(path)
+	assert.EqualError(t, err, `purge cascade: field "personUUID" of type "Membership" has no related concept type`)
+}
+
+func TestVarnishPurgerSink_Cascade(t *testing.T) {
+	client := &mockHTTPClient{statusCode: 200}
+	cascade := PurgeCascade{Rules: map[string][]CascadeRule{"PublicCompany": {{Field: "parentOrganisation", Type: "Organisation"}}}}
+	sink := &varnishPurgerSink{client: client, address: "varnish-purger", typesToPurgeFromPublicEndpoints: []string{"Organisation"}, routes: DefaultRoutingTable(), cascade: cascade}
+
+	err := sink.Send(context.Background(), &SinkWrite{
+		Concept: ConcordedConcept{PrefUUID: "company", Type: "PublicCompany", ParentOrganisation: "parent"},
+		Changes: sqs.ConceptChanges{UpdatedIds: []string{"company"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"varnish-purger/purge?target=%2Fthings%2Fcompany&target=%2Fconcepts%2Fcompany",
+		"varnish-purger/purge?target=%2Fthings%2Fparent&target=%2Fconcepts%2Fparent&target=%2Forganisations%2Fparent",
+	}, client.called)
+}
+
+func TestNewService_PurgeCascade(t *testing.T) {
+	svc, _, _, _, _, _, _ := setupTestService(200, payload)
+	assert.Equal(t, DefaultPurgeCascade(), svc.sinks[VarnishSink].(*varnishPurgerSink).cascade)
+
+	cascade := PurgeCascade{Rules: map[string][]CascadeRule{}}
+	svc = NewService(svc.s3, svc.conceptUpdatesSqs, svc.eventsSqs, svc.concordances, svc.kinesis, neo4jUrl, esUrl, varnishPurgerUrl, nil, svc.httpClient, nil, nil, time.Second,
+		WithPurgeCascade(cascade))
+	assert.Equal(t, cascade, svc.sinks[VarnishSink].(*varnishPurgerSink).cascade)
+}
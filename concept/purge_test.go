@@ -0,0 +1,136 @@
+package concept
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockPurgeClient struct {
+	mu       sync.Mutex
+	failing  map[string]bool
+	requests [][]string
+}
+
+func (m *mockPurgeClient) Do(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	targets := req.URL.Query()["target"]
+	m.requests = append(m.requests, targets)
+	status := http.StatusOK
+	for _, target := range targets {
+		if m.failing[target] {
+			status = http.StatusInternalServerError
+		}
+	}
+	return &http.Response{StatusCode: status, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+}
+
+func (m *mockPurgeClient) Requests() [][]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests
+}
+
+func TestPurgeCoalescer_DeduplicatesTargets(t *testing.T) {
+	client := &mockPurgeClient{}
+	coalescer := newPurgeCoalescer(client, "varnish-purger", 50*time.Millisecond, 10, time.Second)
+	go coalescer.run()
+
+	var wg sync.WaitGroup
+	for _, targets := range [][]string{
+		{"/things/a", "/concepts/a"},
+		{"/things/a", "/concepts/a", "/things/b"},
+		{"/things/b"},
+	} {
+		wg.Add(1)
+		go func(targets []string) {
+			defer wg.Done()
+			failures, err := coalescer.purge(context.Background(), targets)
+			assert.NoError(t, err)
+			assert.Empty(t, failures)
+		}(targets)
+	}
+	wg.Wait()
+
+	var purged []string
+	for _, request := range client.Requests() {
+		purged = append(purged, request...)
+	}
+	assert.ElementsMatch(t, []string{"/things/a", "/concepts/a", "/things/b"}, purged, "each target should be purged once")
+}
+
+func TestPurgeCoalescer_SplitsIntoBatches(t *testing.T) {
+	client := &mockPurgeClient{}
+	coalescer := newPurgeCoalescer(client, "varnish-purger", time.Millisecond, 2, time.Second)
+	go coalescer.run()
+
+	failures, err := coalescer.purge(context.Background(), []string{"/things/a", "/things/b", "/things/c", "/things/d", "/things/e"})
+	assert.NoError(t, err)
+	assert.Empty(t, failures)
+	assert.ElementsMatch(t, [][]string{{"/things/a", "/things/b"}, {"/things/c", "/things/d"}, {"/things/e"}}, client.Requests())
+}
+
+func TestPurgeCoalescer_ReportsTargetFailures(t *testing.T) {
+	client := &mockPurgeClient{failing: map[string]bool{"/things/c": true}}
+	coalescer := newPurgeCoalescer(client, "varnish-purger", time.Millisecond, 2, time.Second)
+	go coalescer.run()
+
+	failures, err := coalescer.purge(context.Background(), []string{"/things/a", "/things/b", "/things/c", "/things/d"})
+	assert.NoError(t, err)
+	if assert.Len(t, failures, 2, "the targets of the failed request should fail") {
+		assert.EqualError(t, failures["/things/c"], "request was not successful, status code: 500")
+		assert.EqualError(t, failures["/things/d"], "request was not successful, status code: 500")
+	}
+}
+
+func TestPurgeCoalescer_ContextDone(t *testing.T) {
+	coalescer := newPurgeCoalescer(&mockPurgeClient{}, "varnish-purger", time.Hour, 2, time.Second)
+	go coalescer.run()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := coalescer.purge(ctx, []string{"/things/a"})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestVarnishPurgerSink_Coalesced(t *testing.T) {
+	client := &mockPurgeClient{failing: map[string]bool{"/organisations/issuer": true}}
+	coalescer := newPurgeCoalescer(client, "varnish-purger", time.Millisecond, 2, time.Second)
+	go coalescer.run()
+	sink := &varnishPurgerSink{client: client, address: "varnish-purger", typesToPurgeFromPublicEndpoints: []string{"Organisation"}, routes: DefaultRoutingTable(), cascade: DefaultPurgeCascade(), coalescer: coalescer}
+
+	err := sink.Send(context.Background(), &SinkWrite{Concept: ConcordedConcept{
+		PrefUUID:              "instrument",
+		Type:                  "FinancialInstrument",
+		IssuedBy:              "issuer",
+		SourceRepresentations: []s3.Concept{{UUID: "instrument", IssuedBy: "issuer"}},
+	}})
+	assert.EqualError(t, err, "targets couldn't be purged from Varnish cache: "+
+		"/organisations/issuer: request was not successful, status code: 500")
+	assert.ElementsMatch(t, [][]string{
+		{"/things/instrument", "/concepts/instrument"},
+		{"/things/issuer", "/concepts/issuer"},
+		{"/organisations/issuer"},
+	}, client.Requests())
+}
+
+func TestNewService_PurgeCoalescing(t *testing.T) {
+	svc, _, _, _, _, _, _ := setupTestService(200, payload)
+	assert.Nil(t, svc.sinks[VarnishSink].(*varnishPurgerSink).coalescer, "targets should be purged straight away by default")
+
+	svc = NewService(svc.s3, svc.conceptUpdatesSqs, svc.eventsSqs, svc.concordances, svc.kinesis, neo4jUrl, esUrl, varnishPurgerUrl, nil, svc.httpClient, nil, nil, time.Second,
+		WithPurgeCoalescing(50*time.Millisecond, 20))
+	coalescer := svc.sinks[VarnishSink].(*varnishPurgerSink).coalescer
+	if assert.NotNil(t, coalescer) {
+		assert.Equal(t, 50*time.Millisecond, coalescer.window)
+		assert.Equal(t, 20, coalescer.maxTargets)
+	}
+}
@@ -0,0 +1,93 @@
+package concept
+
+import "sort"
+
+// reconcileMembershipRoles merges the roles that share a RoleUUID, keeping them in the order they first appear.
+// A merged role runs from the earliest known inception date to the latest known termination date. A role that
+// has an inception date but no termination date is still held, so the merged role is left open-ended; a role
+// without any dates tells us nothing about the range. Roles without a RoleUUID are kept as they are.
+func reconcileMembershipRoles(roles []MembershipRole) []MembershipRole {
+	if len(roles) == 0 {
+		return roles
+	}
+	var out []MembershipRole
+	index := map[string]int{}
+	ongoing := map[string]bool{}
+	for _, mr := range roles {
+		if mr.RoleUUID == "" {
+			out = append(out, mr)
+			continue
+		}
+		if mr.InceptionDate != "" && mr.TerminationDate == "" {
+			ongoing[mr.RoleUUID] = true
+		}
+		i, seen := index[mr.RoleUUID]
+		if !seen {
+			index[mr.RoleUUID] = len(out)
+			out = append(out, mr)
+			continue
+		}
+		if mr.InceptionDate != "" && (out[i].InceptionDate == "" || isEarlierDate(mr.InceptionDate, out[i].InceptionDate)) {
+			out[i].InceptionDate = mr.InceptionDate
+		}
+		if mr.TerminationDate != "" && (out[i].TerminationDate == "" || isEarlierDate(out[i].TerminationDate, mr.TerminationDate)) {
+			out[i].TerminationDate = mr.TerminationDate
+		}
+	}
+	for i := range out {
+		if ongoing[out[i].RoleUUID] {
+			out[i].TerminationDate = ""
+		}
+	}
+	return out
+}
+
+// reconcileNAICSClassifications merges the classifications that share a UUID and ranks them again from 1, so
+// that no two classifications have the same rank. A classification keeps the rank of the last source in merge
+// order that ranks it, which is the source closest to the canonical concept. Classifications are ordered by that
+// rank and then by UUID; the ones no source ranks stay unranked at the end.
+func reconcileNAICSClassifications(classifications []NAICSIndustryClassification) []NAICSIndustryClassification {
+	if len(classifications) == 0 {
+		return classifications
+	}
+	var out []NAICSIndustryClassification
+	index := map[string]int{}
+	for _, ic := range classifications {
+		i, seen := index[ic.UUID]
+		if !seen {
+			index[ic.UUID] = len(out)
+			out = append(out, ic)
+			continue
+		}
+		if ic.Rank != 0 {
+			out[i].Rank = ic.Rank
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Rank != out[j].Rank {
+			if out[i].Rank == 0 || out[j].Rank == 0 {
+				return out[j].Rank == 0
+			}
+			return out[i].Rank < out[j].Rank
+		}
+		return out[i].UUID < out[j].UUID
+	})
+	for i := range out {
+		if out[i].Rank != 0 {
+			out[i].Rank = i + 1
+		}
+	}
+	return out
+}
+
+// isEarlierDate reports whether date a is before date b. Dates that cannot be parsed are compared as strings,
+// which orders ISO 8601 dates correctly.
+func isEarlierDate(a, b string) bool {
+	ta, errA := parseDate(a)
+	tb, errB := parseDate(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return ta.Before(tb)
+}
@@ -0,0 +1,168 @@
+package concept
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/concordances"
+	"github.com/Financial-Times/aggregate-concept-transformer/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcileMembershipRoles(t *testing.T) {
+	testCases := map[string]struct {
+		roles    []MembershipRole
+		expected []MembershipRole
+	}{
+		"Distinct roles kept in order": {
+			roles: []MembershipRole{
+				{RoleUUID: "chair", InceptionDate: "2011-07-26"},
+				{RoleUUID: "director", InceptionDate: "2002-06-01", TerminationDate: "2011-11-29"},
+			},
+			expected: []MembershipRole{
+				{RoleUUID: "chair", InceptionDate: "2011-07-26"},
+				{RoleUUID: "director", InceptionDate: "2002-06-01", TerminationDate: "2011-11-29"},
+			},
+		},
+		"Date ranges combined": {
+			roles: []MembershipRole{
+				{RoleUUID: "director", InceptionDate: "2004-01-01", TerminationDate: "2011-11-29"},
+				{RoleUUID: "director", InceptionDate: "2002-06-01", TerminationDate: "2009-12-31"},
+			},
+			expected: []MembershipRole{
+				{RoleUUID: "director", InceptionDate: "2002-06-01", TerminationDate: "2011-11-29"},
+			},
+		},
+		"Missing dates filled in": {
+			roles: []MembershipRole{
+				{RoleUUID: "director"},
+				{RoleUUID: "director", InceptionDate: "2002-06-01T00:00:00Z", TerminationDate: "2011-11-29"},
+			},
+			expected: []MembershipRole{
+				{RoleUUID: "director", InceptionDate: "2002-06-01T00:00:00Z", TerminationDate: "2011-11-29"},
+			},
+		},
+		"Role still held by one source stays open-ended": {
+			roles: []MembershipRole{
+				{RoleUUID: "director", InceptionDate: "2002-06-01", TerminationDate: "2011-11-29"},
+				{RoleUUID: "director", InceptionDate: "2012-01-01"},
+			},
+			expected: []MembershipRole{
+				{RoleUUID: "director", InceptionDate: "2002-06-01"},
+			},
+		},
+		"Roles without UUID kept": {
+			roles: []MembershipRole{
+				{InceptionDate: "2002-06-01"},
+				{InceptionDate: "2002-06-01"},
+			},
+			expected: []MembershipRole{
+				{InceptionDate: "2002-06-01"},
+				{InceptionDate: "2002-06-01"},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, reconcileMembershipRoles(tc.roles))
+		})
+	}
+}
+
+func TestReconcileNAICSClassifications(t *testing.T) {
+	testCases := map[string]struct {
+		classifications []NAICSIndustryClassification
+		expected        []NAICSIndustryClassification
+	}{
+		"Duplicates merged": {
+			classifications: []NAICSIndustryClassification{
+				{UUID: "publishers", Rank: 1},
+				{UUID: "printing", Rank: 2},
+				{UUID: "publishers", Rank: 1},
+			},
+			expected: []NAICSIndustryClassification{
+				{UUID: "publishers", Rank: 1},
+				{UUID: "printing", Rank: 2},
+			},
+		},
+		"Last ranking source wins": {
+			classifications: []NAICSIndustryClassification{
+				{UUID: "publishers", Rank: 1},
+				{UUID: "printing", Rank: 2},
+				{UUID: "printing", Rank: 1},
+				{UUID: "publishers", Rank: 2},
+			},
+			expected: []NAICSIndustryClassification{
+				{UUID: "printing", Rank: 1},
+				{UUID: "publishers", Rank: 2},
+			},
+		},
+		"Clashing ranks resolved by UUID": {
+			classifications: []NAICSIndustryClassification{
+				{UUID: "publishers", Rank: 1},
+				{UUID: "broadcasting", Rank: 3},
+				{UUID: "printing", Rank: 1},
+			},
+			expected: []NAICSIndustryClassification{
+				{UUID: "printing", Rank: 1},
+				{UUID: "publishers", Rank: 2},
+				{UUID: "broadcasting", Rank: 3},
+			},
+		},
+		"Unranked kept last": {
+			classifications: []NAICSIndustryClassification{
+				{UUID: "publishers"},
+				{UUID: "printing", Rank: 4},
+				{UUID: "broadcasting"},
+			},
+			expected: []NAICSIndustryClassification{
+				{UUID: "printing", Rank: 1},
+				{UUID: "broadcasting"},
+				{UUID: "publishers"},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, reconcileNAICSClassifications(tc.classifications))
+		})
+	}
+}
+
+func TestAggregateService_GetConcordedConcept_MultiSourceMembership(t *testing.T) {
+	svc, s3mock, _, _, _, _, _ := setupTestService(200, payload)
+	svc.concordances.(*mockConcordancesClient).concordances["5b9a1a3c-6c1f-4d55-9d7e-2a1f4c8b0e01"] = []concordances.ConcordanceRecord{
+		{UUID: "5b9a1a3c-6c1f-4d55-9d7e-2a1f4c8b0e01", Authority: "Smartlogic"},
+		{UUID: "87cda39a-e354-3dfb-b28a-b9a04887577b", Authority: "FACTSET"},
+	}
+	s3mock.concepts["5b9a1a3c-6c1f-4d55-9d7e-2a1f4c8b0e01"] = struct {
+		transactionID string
+		concept       s3.Concept
+	}{
+		transactionID: "tid_701",
+		concept: s3.Concept{
+			UUID:             "5b9a1a3c-6c1f-4d55-9d7e-2a1f4c8b0e01",
+			PrefLabel:        "Director",
+			Authority:        "Smartlogic",
+			AuthValue:        "5b9a1a3c-6c1f-4d55-9d7e-2a1f4c8b0e01",
+			Type:             "Membership",
+			PersonUUID:       "d4050b35-45ac-3933-9fad-7720a0dce8df",
+			OrganisationUUID: "064ce159-8835-3426-b456-c86d48de8511",
+			MembershipRoles: []s3.MembershipRole{
+				{RoleUUID: "344fdb1d-0585-31f7-814f-b478e54dbe1f", InceptionDate: "2001-03-01", TerminationDate: "2011-11-29"},
+				{RoleUUID: "abacb0e1-3f7e-334a-96b9-ed5da35f3251"},
+				{RoleUUID: "0c3e7b4e-2b7f-4d4c-9d3a-53c8a6a7f9b2", InceptionDate: "2012-01-01"},
+			},
+		},
+	}
+
+	c, _, err := svc.GetConcordedConcept(context.Background(), "5b9a1a3c-6c1f-4d55-9d7e-2a1f4c8b0e01", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []MembershipRole{
+		{RoleUUID: "344fdb1d-0585-31f7-814f-b478e54dbe1f", InceptionDate: "2001-03-01", TerminationDate: "2011-11-29"},
+		{RoleUUID: "abacb0e1-3f7e-334a-96b9-ed5da35f3251", InceptionDate: "2011-07-26", TerminationDate: "2011-11-29"},
+		{RoleUUID: "0c3e7b4e-2b7f-4d4c-9d3a-53c8a6a7f9b2", InceptionDate: "2012-01-01"},
+	}, c.MembershipRoles)
+}
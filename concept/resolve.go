@@ -0,0 +1,156 @@
+package concept
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/concordances"
+)
+
+// noCanonicalConceptError is returned for concordances that do not resolve to a single canonical concept.
+type noCanonicalConceptError struct {
+	cause error
+}
+
+func (e *noCanonicalConceptError) Error() string {
+	return "no single canonical concept: " + e.cause.Error()
+}
+
+// UnresolvedReference is a relationship that could not be pointed at a canonical concept. It is left as
+// supplied by the sources. Field is the JSON name of the relationship.
+type UnresolvedReference struct {
+	Field  string `json:"field"`
+	UUID   string `json:"uuid"`
+	Reason string `json:"reason"`
+}
+
+// canonicalUUID returns the prefUUID a concordance resolves to: the record of the primary authority,
+// or the last record in merge order when there is no primary authority.
+func canonicalUUID(bucketedConcordances map[string][]concordances.ConcordanceRecord, primaryAuthority string) string {
+	if primaryAuthority != "" {
+		return bucketedConcordances[primaryAuthority][0].UUID
+	}
+	authorities := sortedAuthorities(bucketedConcordances)
+	records := bucketedConcordances[authorities[len(authorities)-1]]
+	return records[len(records)-1].UUID
+}
+
+// resolveCanonicalUUID returns the prefUUID of the concept UUID is concorded to. Lookups made with a bookmark
+// skip the cache, as they must see the concordances written up to that bookmark.
+func (s *AggregateService) resolveCanonicalUUID(ctx context.Context, UUID string, bookmark string) (string, error) {
+	if bookmark == "" {
+		if canonical, ok := s.canonicalCache.get(UUID); ok {
+			return canonical, nil
+		}
+	}
+	records, err := s.concordances.GetConcordance(ctx, UUID, bookmark)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve canonical concept for %s: %w", UUID, err)
+	}
+	bucketedConcordances, primaryAuthority, err := bucketConcordances(records, s.primaryAuthorities)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve canonical concept for %s: %w", UUID, &noCanonicalConceptError{cause: err})
+	}
+	canonical := canonicalUUID(bucketedConcordances, primaryAuthority)
+	s.canonicalCache.put(UUID, canonical)
+	return canonical, nil
+}
+
+// resolveRelationships points the relationships of the concept at the canonical concepts they are concorded to.
+// References whose concordance has no single canonical concept are left unchanged and returned; an error is only
+// returned when the concordances cannot be read.
+func (s *AggregateService) resolveRelationships(ctx context.Context, c *ConcordedConcept, bookmark string) ([]UnresolvedReference, error) {
+	var unresolved []UnresolvedReference
+	var err error
+	resolveOne := func(field string, UUID *string) {
+		if err != nil || *UUID == "" {
+			return
+		}
+		canonical, resolveErr := s.resolveCanonicalUUID(ctx, *UUID, bookmark)
+		var noCanonical *noCanonicalConceptError
+		switch {
+		case errors.As(resolveErr, &noCanonical):
+			unresolved = append(unresolved, UnresolvedReference{Field: field, UUID: *UUID, Reason: noCanonical.Error()})
+		case resolveErr != nil:
+			err = resolveErr
+		default:
+			*UUID = canonical
+		}
+	}
+	resolveAll := func(field string, UUIDs []string) []string {
+		if err != nil || len(UUIDs) == 0 {
+			return UUIDs
+		}
+		var resolved []string
+		seen := map[string]bool{}
+		for _, UUID := range UUIDs {
+			resolveOne(field, &UUID)
+			if err != nil {
+				return UUIDs
+			}
+			if !seen[UUID] {
+				seen[UUID] = true
+				resolved = append(resolved, UUID)
+			}
+		}
+		return resolved
+	}
+
+	c.ParentUUIDs = resolveAll("parentUUIDs", c.ParentUUIDs)
+	c.BroaderUUIDs = resolveAll("broaderUUIDs", c.BroaderUUIDs)
+	c.RelatedUUIDs = resolveAll("relatedUUIDs", c.RelatedUUIDs)
+	c.SupersededByUUIDs = resolveAll("supersededByUUIDs", c.SupersededByUUIDs)
+	c.ImpliedByUUIDs = resolveAll("impliedByUUIDs", c.ImpliedByUUIDs)
+	c.HasFocusUUIDs = resolveAll("hasFocusUUIDs", c.HasFocusUUIDs)
+	resolveOne("organisationUUID", &c.OrganisationUUID)
+	resolveOne("personUUID", &c.PersonUUID)
+	resolveOne("issuedBy", &c.IssuedBy)
+	resolveOne("countryOfRiskUUID", &c.CountryOfRiskUUID)
+	resolveOne("countryOfIncorporationUUID", &c.CountryOfIncorporationUUID)
+	resolveOne("countryOfOperationsUUID", &c.CountryOfOperationsUUID)
+	resolveOne("parentOrganisation", &c.ParentOrganisation)
+	if err != nil {
+		return nil, err
+	}
+	return unresolved, nil
+}
+
+// relatedCanonicalUUIDs aggregates the concept UUID just far enough to return the canonical concepts of the
+// relationships picked out by related. References without a single canonical concept are returned as they are.
+func (s *AggregateService) relatedCanonicalUUIDs(ctx context.Context, UUID string, bookmark string, related func(c ConcordedConcept) []string) ([]string, error) {
+	sources, _, _, err := s.loadSources(ctx, UUID, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	c, _, err := s.mergeCanonicalInformation(sources)
+	if err != nil {
+		return nil, err
+	}
+
+	var resolved []string
+	seen := map[string]bool{}
+	for _, relatedUUID := range related(c) {
+		canonical, err := s.resolveCanonicalUUID(ctx, relatedUUID, bookmark)
+		var noCanonical *noCanonicalConceptError
+		if errors.As(err, &noCanonical) {
+			canonical = relatedUUID
+		} else if err != nil {
+			return nil, err
+		}
+		if !seen[canonical] {
+			seen[canonical] = true
+			resolved = append(resolved, canonical)
+		}
+	}
+	return resolved, nil
+}
+
+// isUnresolvable reports whether err is caused by the data of a related concept rather than by a failure to
+// read it, so that retrying cannot help.
+func isUnresolvable(err error) bool {
+	var validationErr *ValidationError
+	var notFound *canonicalNotFoundError
+	var noCanonical *noCanonicalConceptError
+	return errors.As(err, &validationErr) || errors.As(err, &notFound) || errors.As(err, &noCanonical)
+}
@@ -0,0 +1,139 @@
+package concept
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// writerSinks are the sinks that write concepts to a store, and that a route can send a type to.
+var writerSinks = []string{Neo4jSink, ElasticsearchSink}
+
+// WriterRoute sends the concepts of a type to a writer. When Authorities is set only concepts with a source from
+// one of these authorities are sent.
+type WriterRoute struct {
+	Authorities []string `json:"authorities,omitempty"`
+}
+
+// TypeRoute declares how the concepts of a type are written and purged.
+type TypeRoute struct {
+	// Path is the URL path segment of the type in the writers and the public endpoints. It defaults to the
+	// kebab-case plural of the type, e.g. special-reports for SpecialReport.
+	Path string `json:"path,omitempty"`
+	// Writers are the writers the concepts of the type are sent to, by sink name. Every writer is used when nil.
+	Writers map[string]WriterRoute `json:"writers,omitempty"`
+	// PurgePublicEndpoint purges the public endpoint of the type, e.g. /people/{uuid}, along with /things and
+	// /concepts.
+	PurgePublicEndpoint bool `json:"purgePublicEndpoint,omitempty"`
+}
+
+// RoutingTable declares, by concept type, how concepts are routed to the writers and purged. Types that are not
+// declared use the default path, go to every writer and have no public endpoint purged.
+type RoutingTable struct {
+	Types map[string]TypeRoute `json:"types"`
+}
+
+// DefaultRoutingTable returns the irregular paths of the concept types known to UPP, and keeps the types that are
+// not searched for out of Elasticsearch. Memberships are only searchable when curated in Smartlogic, as they are
+// used to discover authors.
+func DefaultRoutingTable() RoutingTable {
+	neo4jOnly := map[string]WriterRoute{Neo4jSink: {}}
+	return RoutingTable{
+		Types: map[string]TypeRoute{
+			"AlphavilleSeries":            {Path: "alphaville-series"},
+			"Dummy":                       {Path: "dummies"},
+			"Person":                      {Path: "people"},
+			"PublicCompany":               {Path: "organisations"},
+			"FinancialInstrument":         {Writers: neo4jOnly},
+			"MembershipRole":              {Writers: neo4jOnly},
+			"BoardRole":                   {Path: "membership-roles", Writers: neo4jOnly},
+			"IndustryClassification":      {Writers: neo4jOnly},
+			"NAICSIndustryClassification": {Path: "industry-classifications", Writers: neo4jOnly},
+			"Membership": {Writers: map[string]WriterRoute{
+				Neo4jSink:         {},
+				ElasticsearchSink: {Authorities: []string{"Smartlogic"}},
+			}},
+		},
+	}
+}
+
+// LoadRoutingTable reads the JSON file at path and lays its routes over the default routing table, replacing the
+// route of any type it declares. An empty path returns DefaultRoutingTable.
+func LoadRoutingTable(path string) (RoutingTable, error) {
+	table := DefaultRoutingTable()
+	if path == "" {
+		return table, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return RoutingTable{}, fmt.Errorf("failed to read routing table %s: %w", path, err)
+	}
+	var overrides RoutingTable
+	if err = json.Unmarshal(data, &overrides); err != nil {
+		return RoutingTable{}, fmt.Errorf("failed to parse routing table %s: %w", path, err)
+	}
+	for conceptType, route := range overrides.Types {
+		table.Types[conceptType] = route
+	}
+	return table, table.Validate()
+}
+
+// Validate checks that every path is a single URL path segment and that every writer is known.
+func (r RoutingTable) Validate() error {
+	types := make([]string, 0, len(r.Types))
+	for conceptType := range r.Types {
+		types = append(types, conceptType)
+	}
+	sort.Strings(types)
+	for _, conceptType := range types {
+		route := r.Types[conceptType]
+		if strings.ContainsAny(route.Path, "/?#% ") {
+			return fmt.Errorf("routing table: path %q of type %q is not a URL path segment", route.Path, conceptType)
+		}
+		for writer, wr := range route.Writers {
+			if !contains(writer, writerSinks) {
+				return fmt.Errorf("routing table: unknown writer %q for type %q", writer, conceptType)
+			}
+			if wr.Authorities != nil && len(wr.Authorities) == 0 {
+				return fmt.Errorf("routing table: writer %q of type %q has an empty list of authorities", writer, conceptType)
+			}
+		}
+	}
+	return nil
+}
+
+// path returns the URL path segment of conceptType.
+func (r RoutingTable) path(conceptType string) string {
+	if route, ok := r.Types[conceptType]; ok && route.Path != "" {
+		return route.Path
+	}
+	return toSnakeCase(conceptType) + "s"
+}
+
+// writesTo reports whether c is sent to writer.
+func (r RoutingTable) writesTo(writer string, c ConcordedConcept) bool {
+	route, ok := r.Types[c.Type]
+	if !ok || route.Writers == nil {
+		return true
+	}
+	wr, ok := route.Writers[writer]
+	if !ok {
+		return false
+	}
+	if wr.Authorities == nil {
+		return true
+	}
+	for _, sr := range c.SourceRepresentations {
+		if contains(sr.Authority, wr.Authorities) {
+			return true
+		}
+	}
+	return false
+}
+
+// purgesPublicEndpoint reports whether the public endpoint of conceptType is purged.
+func (r RoutingTable) purgesPublicEndpoint(conceptType string) bool {
+	return r.Types[conceptType].PurgePublicEndpoint
+}
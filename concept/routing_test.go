@@ -0,0 +1,96 @@
+package concept
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutingTable_WritesTo(t *testing.T) {
+	routes := DefaultRoutingTable()
+
+	assert.True(t, routes.writesTo(ElasticsearchSink, ConcordedConcept{Type: "Person"}), "undeclared types should go to every writer")
+	assert.True(t, routes.writesTo(Neo4jSink, ConcordedConcept{Type: "Person"}))
+	for _, conceptType := range []string{"FinancialInstrument", "MembershipRole", "BoardRole", "IndustryClassification", "NAICSIndustryClassification"} {
+		assert.True(t, routes.writesTo(Neo4jSink, ConcordedConcept{Type: conceptType}), conceptType)
+		assert.False(t, routes.writesTo(ElasticsearchSink, ConcordedConcept{Type: conceptType}), conceptType)
+	}
+
+	factset := ConcordedConcept{Type: "Membership", SourceRepresentations: []s3.Concept{{Authority: "FACTSET"}}}
+	assert.True(t, routes.writesTo(Neo4jSink, factset))
+	assert.False(t, routes.writesTo(ElasticsearchSink, factset))
+	curated := ConcordedConcept{Type: "Membership", SourceRepresentations: []s3.Concept{{Authority: "FACTSET"}, {Authority: "Smartlogic"}}}
+	assert.True(t, routes.writesTo(ElasticsearchSink, curated))
+}
+
+func TestRoutingTable_PurgesPublicEndpoint(t *testing.T) {
+	routes := RoutingTable{Types: map[string]TypeRoute{"FinancialInstrument": {PurgePublicEndpoint: true}}}
+
+	assert.Equal(t, []string{"/things/a", "/concepts/a", "/financial-instruments/a"}, purgeTargets([]string{"a"}, "FinancialInstrument", routes, nil))
+	assert.Equal(t, []string{"/things/a", "/concepts/a"}, purgeTargets([]string{"a"}, "Brand", routes, nil))
+	assert.Equal(t, []string{"/things/a", "/concepts/a", "/brands/a"}, purgeTargets([]string{"a"}, "Brand", routes, []string{"Brand"}),
+		"the types listed by the service should still have their public endpoint purged")
+}
+
+func TestLoadRoutingTable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "routing-table")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	r, err := LoadRoutingTable("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultRoutingTable(), r)
+	assert.NoError(t, r.Validate())
+
+	path := filepath.Join(dir, "routing.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"types": {"FinancialInstrument": {"path": "instruments", "purgePublicEndpoint": true}}}`), 0600))
+	r, err = LoadRoutingTable(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "instruments", r.path("FinancialInstrument"))
+	assert.True(t, r.writesTo(ElasticsearchSink, ConcordedConcept{Type: "FinancialInstrument"}))
+	assert.Equal(t, "people", r.path("Person"), "the routes of other types should be kept")
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"types": {"Person": {"path": "people/v2"}}}`), 0600))
+	_, err = LoadRoutingTable(path)
+	assert.EqualError(t, err, `routing table: path "people/v2" of type "Person" is not a URL path segment`)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"types": {"Person": {"writers": {"solr": {}}}}}`), 0600))
+	_, err = LoadRoutingTable(path)
+	assert.EqualError(t, err, `routing table: unknown writer "solr" for type "Person"`)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"types": {"Person": {"writers": {"elasticsearch": {"authorities": []}}}}}`), 0600))
+	_, err = LoadRoutingTable(path)
+	assert.EqualError(t, err, `routing table: writer "elasticsearch" of type "Person" has an empty list of authorities`)
+}
+
+func TestNeo4jWriterSink_SkipsTypesNotRouted(t *testing.T) {
+	client := &mockHTTPClient{statusCode: 200}
+	routes := RoutingTable{Types: map[string]TypeRoute{"Topic": {Writers: map[string]WriterRoute{ElasticsearchSink: {}}}}}
+	sink := &neo4jWriterSink{client: client, address: "concepts-rw-neo4j", routes: routes}
+	w := &SinkWrite{Concept: ConcordedConcept{PrefUUID: "topic", Type: "Topic"}}
+
+	assert.NoError(t, sink.Send(context.Background(), w))
+	assert.Empty(t, client.called)
+	assert.False(t, w.Unchanged, "the sinks after Neo4j should still run")
+	assert.Equal(t, "concepts of type Topic are not written to Neo4j", sink.Plan(w).Skipped)
+}
+
+func TestAggregateService_ProcessMessage_RoutingTable(t *testing.T) {
+	svc, _, _, _, _, _, _ := setupTestService(200, payload)
+	routes := DefaultRoutingTable()
+	routes.Types["FinancialInstrument"] = TypeRoute{Path: "instruments"}
+	svc = NewService(svc.s3, svc.conceptUpdatesSqs, svc.eventsSqs, svc.concordances, svc.kinesis, neo4jUrl, esUrl, varnishPurgerUrl, nil, svc.httpClient, nil, nil, time.Second,
+		WithRoutingTable(routes))
+
+	err := svc.ProcessMessage(context.Background(), "6562674e-dbfa-4cb0-85b2-41b0948b7cc2", "")
+	assert.NoError(t, err)
+	called := svc.httpClient.(*mockHTTPClient).called
+	assert.Contains(t, called, "concepts-rw-neo4j/instruments/6562674e-dbfa-4cb0-85b2-41b0948b7cc2")
+	assert.Contains(t, called, "concept-rw-elasticsearch/instruments/6562674e-dbfa-4cb0-85b2-41b0948b7cc2")
+}
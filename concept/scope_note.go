@@ -0,0 +1,125 @@
+package concept
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/s3"
+)
+
+// ScopeNoteRule reads candidate scope notes from the sources of one authority.
+type ScopeNoteRule struct {
+	Authority string `json:"authority"`
+	// Field is the JSON name of the string field of the source to read, scopeNote by default.
+	Field string `json:"field,omitempty"`
+	// Types limits the rule to concepts of these types. An empty list applies the rule to every type.
+	Types []string `json:"types,omitempty"`
+}
+
+// ScopeNoteRules are tried in order and the first rule whose authority supplies a scope note is used.
+// The scope notes of all the sources of that authority are joined, leaving out those equal to the prefLabel.
+type ScopeNoteRules struct {
+	Rules []ScopeNoteRule `json:"rules"`
+}
+
+// DefaultScopeNoteRules prefer the Smartlogic scope note, then the Wikidata one, and for locations fall back
+// to the TME prefLabel.
+func DefaultScopeNoteRules() ScopeNoteRules {
+	return ScopeNoteRules{
+		Rules: []ScopeNoteRule{
+			{Authority: smartlogicAuthority},
+			{Authority: "Wikidata"},
+			{Authority: "TME", Field: "prefLabel", Types: []string{"Location"}},
+		},
+	}
+}
+
+// LoadScopeNoteRules reads scope note rules from the JSON file at path, replacing the default rules.
+// An empty path returns DefaultScopeNoteRules.
+func LoadScopeNoteRules(path string) (ScopeNoteRules, error) {
+	if path == "" {
+		return DefaultScopeNoteRules(), nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ScopeNoteRules{}, fmt.Errorf("failed to read scope note rules: %w", err)
+	}
+	var rules ScopeNoteRules
+	if err = json.Unmarshal(data, &rules); err != nil {
+		return ScopeNoteRules{}, fmt.Errorf("failed to parse scope note rules: %w", err)
+	}
+	return rules, rules.Validate()
+}
+
+// Validate checks that every rule names an authority and a string field of the source concepts.
+func (r ScopeNoteRules) Validate() error {
+	for i, rule := range r.Rules {
+		if rule.Authority == "" {
+			return fmt.Errorf("scope note rules: rule %d has no authority", i)
+		}
+		if _, ok := sourceStringField(rule.field()); !ok {
+			return fmt.Errorf("scope note rules: rule %d reads unknown string field %q", i, rule.Field)
+		}
+	}
+	return nil
+}
+
+// choose returns the scope note for c and the indexes of the sources it was taken from.
+func (r ScopeNoteRules) choose(c ConcordedConcept, sources []s3.Concept) (string, []int) {
+	for _, rule := range r.Rules {
+		if !rule.appliesTo(c.Type) {
+			continue
+		}
+		value, _ := sourceStringField(rule.field())
+		var notes []string
+		var from []int
+		for i, src := range sources {
+			if src.Authority != rule.Authority {
+				continue
+			}
+			if note := value(src); note != "" {
+				notes = append(notes, note)
+				from = append(from, i)
+			}
+		}
+		if len(notes) > 0 {
+			return strings.Join(removeMatchingEntries(notes, c.PrefLabel), " | "), from
+		}
+	}
+	return "", nil
+}
+
+func (r ScopeNoteRule) field() string {
+	if r.Field == "" {
+		return "scopeNote"
+	}
+	return r.Field
+}
+
+func (r ScopeNoteRule) appliesTo(conceptType string) bool {
+	if len(r.Types) == 0 {
+		return true
+	}
+	for _, t := range r.Types {
+		if t == conceptType {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceStringField returns a reader for the string field of a source concept with the given JSON name.
+func sourceStringField(name string) (func(s s3.Concept) string, bool) {
+	if name == "scopeNote" {
+		return func(s s3.Concept) string { return s.ScopeNote }, true
+	}
+	for _, f := range mergeFields {
+		if f.name == name && f.kind == stringField {
+			value := f.value
+			return func(s s3.Concept) string { return value(s).(string) }, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,103 @@
+package concept
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScopeNoteRules_Choose(t *testing.T) {
+	smartlogic := s3.Concept{Authority: "Smartlogic", PrefLabel: "Paris", ScopeNote: "Capital of France"}
+	wikidata := s3.Concept{Authority: "Wikidata", PrefLabel: "Paris", ScopeNote: "City in France"}
+	tme := s3.Concept{Authority: "TME", PrefLabel: "Paris, Ile-de-France"}
+	geonames := s3.Concept{Authority: "Geonames", PrefLabel: "Paris", DescriptionXML: "<p>Paris</p>"}
+
+	testCases := map[string]struct {
+		rules       ScopeNoteRules
+		conceptType string
+		sources     []s3.Concept
+		expected    string
+		from        []int
+	}{
+		"Smartlogic preferred": {
+			rules:       DefaultScopeNoteRules(),
+			conceptType: "Location",
+			sources:     []s3.Concept{tme, wikidata, smartlogic},
+			expected:    "Capital of France",
+			from:        []int{2},
+		},
+		"Wikidata when Smartlogic has none": {
+			rules:       DefaultScopeNoteRules(),
+			conceptType: "Location",
+			sources:     []s3.Concept{tme, wikidata, {Authority: "Smartlogic", PrefLabel: "Paris"}},
+			expected:    "City in France",
+			from:        []int{1},
+		},
+		"TME prefLabel for locations": {
+			rules:       DefaultScopeNoteRules(),
+			conceptType: "Location",
+			sources:     []s3.Concept{tme, tme, {Authority: "Smartlogic", PrefLabel: "Paris"}},
+			expected:    "Paris, Ile-de-France | Paris, Ile-de-France",
+			from:        []int{0, 1},
+		},
+		"TME prefLabel not used for other types": {
+			rules:       DefaultScopeNoteRules(),
+			conceptType: "Person",
+			sources:     []s3.Concept{tme, {Authority: "Smartlogic", PrefLabel: "Paris"}},
+		},
+		"Scope notes equal to the prefLabel left out": {
+			rules:       DefaultScopeNoteRules(),
+			conceptType: "Location",
+			sources:     []s3.Concept{{Authority: "Smartlogic", PrefLabel: "Paris", ScopeNote: "Paris"}},
+			from:        []int{0},
+		},
+		"Configured authority and field": {
+			rules: ScopeNoteRules{Rules: []ScopeNoteRule{
+				{Authority: "Geonames", Field: "descriptionXML", Types: []string{"Location"}},
+				{Authority: "Smartlogic"},
+			}},
+			conceptType: "Location",
+			sources:     []s3.Concept{geonames, smartlogic},
+			expected:    "<p>Paris</p>",
+			from:        []int{0},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c := ConcordedConcept{Type: tc.conceptType, PrefLabel: "Paris"}
+			note, from := tc.rules.choose(c, tc.sources)
+			assert.Equal(t, tc.expected, note)
+			assert.Equal(t, tc.from, from)
+		})
+	}
+}
+
+func TestLoadScopeNoteRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scope-note-rules")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rules, err := LoadScopeNoteRules("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultScopeNoteRules(), rules)
+	assert.NoError(t, rules.Validate())
+
+	path := filepath.Join(dir, "rules.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"rules": [{"authority": "Wikidata"}, {"authority": "Smartlogic", "field": "shortLabel"}]}`), 0600))
+	rules, err = LoadScopeNoteRules(path)
+	assert.NoError(t, err)
+	assert.Equal(t, ScopeNoteRules{Rules: []ScopeNoteRule{{Authority: "Wikidata"}, {Authority: "Smartlogic", Field: "shortLabel"}}}, rules)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"rules": [{"authority": "Wikidata", "field": "aliases"}]}`), 0600))
+	_, err = LoadScopeNoteRules(path)
+	assert.EqualError(t, err, `scope note rules: rule 0 reads unknown string field "aliases"`)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"rules": [{"field": "scopeNote"}]}`), 0600))
+	_, err = LoadScopeNoteRules(path)
+	assert.EqualError(t, err, `scope note rules: rule 0 has no authority`)
+}
@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -15,6 +17,7 @@ import (
 
 	"github.com/Financial-Times/aggregate-concept-transformer/concordances"
 	"github.com/Financial-Times/aggregate-concept-transformer/kinesis"
+	"github.com/Financial-Times/aggregate-concept-transformer/resilience"
 	"github.com/Financial-Times/aggregate-concept-transformer/s3"
 	"github.com/Financial-Times/aggregate-concept-transformer/sqs"
 	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
@@ -26,21 +29,18 @@ const (
 	managedLocationAuthority = "ManagedLocation"
 	thingsAPIEndpoint        = "/things"
 	conceptsAPIEnpoint       = "/concepts"
-)
 
-var irregularConceptTypePaths = map[string]string{
-	"AlphavilleSeries":            "alphaville-series",
-	"BoardRole":                   "membership-roles",
-	"Dummy":                       "dummies",
-	"Person":                      "people",
-	"PublicCompany":               "organisations",
-	"NAICSIndustryClassification": "industry-classifications",
-}
+	defaultCanonicalCacheSize = 10000
+	defaultCanonicalCacheTTL  = time.Minute
+	defaultCheckpointTTL      = time.Hour
+)
 
 type Service interface {
 	ListenForNotifications(ctx context.Context, workerID int)
 	ProcessMessage(ctx context.Context, UUID string, bookmark string) error
+	PlanMessage(ctx context.Context, UUID string, bookmark string) (Plan, error)
 	GetConcordedConcept(ctx context.Context, UUID string, bookmark string) (ConcordedConcept, string, error)
+	Aggregate(ctx context.Context, UUID string, bookmark string) (AggregationResult, error)
 	Healthchecks() []fthealth.Check
 }
 
@@ -84,18 +84,186 @@ func (r *systemHealth) processChannel() {
 }
 
 type AggregateService struct {
-	s3                              s3.Client
-	concordances                    concordances.Client
-	conceptUpdatesSqs               sqs.Client
-	eventsSqs                       sqs.Client
-	kinesis                         kinesis.Client
-	neoWriterAddress                string
-	varnishPurgerAddress            string
-	elasticsearchWriterAddress      string
-	httpClient                      httpClient
-	typesToPurgeFromPublicEndpoints []string
-	health                          *systemHealth
-	processTimeout                  time.Duration
+	s3                         s3.Client
+	concordances               concordances.Client
+	conceptUpdatesSqs          sqs.Client
+	eventsSqs                  sqs.Client
+	kinesis                    kinesis.Client
+	neoWriterAddress           string
+	varnishPurgerAddress       string
+	elasticsearchWriterAddress string
+	httpClient                 httpClient
+	health                     *systemHealth
+	processTimeout             time.Duration
+	mergePolicy                MergePolicy
+	aliasRules                 AliasRules
+	scopeNoteRules             ScopeNoteRules
+	typeHierarchy              TypeHierarchy
+	purgeCascade               PurgeCascade
+	routing                    RoutingTable
+	primaryAuthorities         PrimaryAuthorities
+	canonicalCache             *canonicalCache
+	hierarchyDepth             int
+	hashStore                  HashStore
+	sinkPipeline               SinkPipeline
+	sinks                      map[string]Sink
+	checkpoints                *checkpointStore
+	deadLetterSqs              sqs.DeadLetterClient
+	maxReceiveCount            int
+	attempts                   *attemptLog
+	resiliencePolicy           resilience.Policy
+	neoWriter                  *resilience.Client
+	elasticsearchWriter        *resilience.Client
+	varnishPurger              *resilience.Client
+	bulkMaxItems               int
+	bulkFlushInterval          time.Duration
+	purgeWindow                time.Duration
+	purgeBatchSize             int
+}
+
+// Option configures optional behaviour of the AggregateService.
+type Option func(*AggregateService)
+
+// WithMergePolicy sets the per-field merge policy used when aggregating source concepts.
+func WithMergePolicy(policy MergePolicy) Option {
+	return func(s *AggregateService) {
+		s.mergePolicy = policy
+	}
+}
+
+// WithAliasRules sets the per-type rules used to normalise the aliases of concorded concepts.
+func WithAliasRules(rules AliasRules) Option {
+	return func(s *AggregateService) {
+		s.aliasRules = rules
+	}
+}
+
+// WithScopeNoteRules sets the ordered rules used to choose the scope note of concorded concepts.
+func WithScopeNoteRules(rules ScopeNoteRules) Option {
+	return func(s *AggregateService) {
+		s.scopeNoteRules = rules
+	}
+}
+
+// WithTypeHierarchy sets the hierarchy used to choose the most specific type declared by the source concepts.
+func WithTypeHierarchy(hierarchy TypeHierarchy) Option {
+	return func(s *AggregateService) {
+		s.typeHierarchy = hierarchy
+	}
+}
+
+// WithPurgeCascade sets the related concepts purged from Varnish along with a concept, by concept type.
+func WithPurgeCascade(cascade PurgeCascade) Option {
+	return func(s *AggregateService) {
+		s.purgeCascade = cascade
+	}
+}
+
+// WithRoutingTable sets the paths of the concept types, the writers their concepts are sent to and whether their
+// public endpoints are purged.
+func WithRoutingTable(table RoutingTable) Option {
+	return func(s *AggregateService) {
+		s.routing = table
+	}
+}
+
+// WithPrimaryAuthorities sets the authorities whose concepts can be the canonical concept, highest priority first.
+func WithPrimaryAuthorities(authorities PrimaryAuthorities) Option {
+	return func(s *AggregateService) {
+		s.primaryAuthorities = authorities
+	}
+}
+
+// WithCanonicalCache sets how many resolved relationship UUIDs are cached, and for how long.
+// A size of zero or less disables the cache.
+func WithCanonicalCache(size int, ttl time.Duration) Option {
+	return func(s *AggregateService) {
+		s.canonicalCache = newCanonicalCache(size, ttl)
+	}
+}
+
+// WithHierarchyCycleGuard makes ProcessMessage refuse concepts whose broader or parent relationships lead back
+// to themselves within depth steps. A depth of zero or less disables the check.
+func WithHierarchyCycleGuard(depth int) Option {
+	return func(s *AggregateService) {
+		s.hierarchyDepth = depth
+	}
+}
+
+// WithHashStore makes ProcessMessage skip concepts that are unchanged since they were last written to every sink,
+// as recorded in store. A nil store writes every concept.
+func WithHashStore(store HashStore) Option {
+	return func(s *AggregateService) {
+		s.hashStore = store
+	}
+}
+
+// WithSinkPipeline sets the sinks ProcessMessage sends concepts to, in order.
+func WithSinkPipeline(pipeline SinkPipeline) Option {
+	return func(s *AggregateService) {
+		s.sinkPipeline = pipeline
+	}
+}
+
+// WithSink registers sink under its name, so the sink pipeline can send concepts to it. A sink with the name of
+// a built-in sink replaces it.
+func WithSink(sink Sink) Option {
+	return func(s *AggregateService) {
+		s.sinks[sink.Name()] = sink
+	}
+}
+
+// WithCheckpointTTL sets how long the progress of a concept whose sink pipeline failed is kept for a retry to
+// resume from. A ttl of zero or less makes retries run the whole pipeline again. Checkpoints are only kept in
+// the memory of this instance: a retry received by another instance, or after a restart, runs the whole pipeline
+// again, and the events and Kinesis notification of the changes Neo4j reported the first time are lost.
+func WithCheckpointTTL(ttl time.Duration) Option {
+	return func(s *AggregateService) {
+		s.checkpoints = newCheckpointStore(ttl)
+	}
+}
+
+// WithDeadLetterQueue forwards concept updates that cannot be processed to client, describing the failure and the
+// attempts made, before they are removed from the queue.
+func WithDeadLetterQueue(client sqs.DeadLetterClient) Option {
+	return func(s *AggregateService) {
+		s.deadLetterSqs = client
+	}
+}
+
+// WithMaxReceiveCount dead-letters concept updates that have failed transiently once they have been received
+// count times. A count of zero or less retries them until they expire from the queue.
+func WithMaxReceiveCount(count int) Option {
+	return func(s *AggregateService) {
+		s.maxReceiveCount = count
+	}
+}
+
+// WithResilience sets how calls to the writers and the purger are retried, and when their circuit breakers open.
+func WithResilience(policy resilience.Policy) Option {
+	return func(s *AggregateService) {
+		s.resiliencePolicy = policy
+	}
+}
+
+// WithElasticsearchBulk writes concepts to Elasticsearch through the bulk endpoint of the writer, in batches of up
+// to maxItems concepts that are sent at the latest flushInterval after their first concept was added. A maxItems
+// of zero or less writes each concept with its own request.
+func WithElasticsearchBulk(maxItems int, flushInterval time.Duration) Option {
+	return func(s *AggregateService) {
+		s.bulkMaxItems = maxItems
+		s.bulkFlushInterval = flushInterval
+	}
+}
+
+// WithPurgeCoalescing purges the targets of concurrent workers together: the targets asked for within window are
+// purged once each, in requests of up to batchSize targets. A window of zero or less purges the targets of each
+// concept with their own requests, as soon as they are asked for.
+func WithPurgeCoalescing(window time.Duration, batchSize int) Option {
+	return func(s *AggregateService) {
+		s.purgeWindow = window
+		s.purgeBatchSize = batchSize
+	}
 }
 
 func NewService(
@@ -111,7 +279,8 @@ func NewService(
 	httpClient httpClient,
 	feedback <-chan bool,
 	done <-chan struct{},
-	processTimeout time.Duration) *AggregateService {
+	processTimeout time.Duration,
+	opts ...Option) *AggregateService {
 
 	health := &systemHealth{
 		healthy:  false, // Set to false. Once health check passes app will read from SQS
@@ -121,20 +290,65 @@ func NewService(
 	}
 	go health.processChannel()
 
-	return &AggregateService{
-		s3:                              S3Client,
-		concordances:                    concordancesClient,
-		conceptUpdatesSqs:               conceptUpdatesSQSClient,
-		eventsSqs:                       eventsSQSClient,
-		kinesis:                         kinesisClient,
-		neoWriterAddress:                neoAddress,
-		elasticsearchWriterAddress:      elasticsearchAddress,
-		varnishPurgerAddress:            varnishPurgerAddress,
-		httpClient:                      httpClient,
-		typesToPurgeFromPublicEndpoints: typesToPurgeFromPublicEndpoints,
-		health:                          health,
-		processTimeout:                  processTimeout,
+	svc := &AggregateService{
+		s3:                         S3Client,
+		concordances:               concordancesClient,
+		conceptUpdatesSqs:          conceptUpdatesSQSClient,
+		eventsSqs:                  eventsSQSClient,
+		kinesis:                    kinesisClient,
+		neoWriterAddress:           neoAddress,
+		elasticsearchWriterAddress: elasticsearchAddress,
+		varnishPurgerAddress:       varnishPurgerAddress,
+		httpClient:                 httpClient,
+		health:                     health,
+		processTimeout:             processTimeout,
+		mergePolicy:                DefaultMergePolicy(),
+		aliasRules:                 DefaultAliasRules(),
+		scopeNoteRules:             DefaultScopeNoteRules(),
+		typeHierarchy:              DefaultTypeHierarchy(),
+		purgeCascade:               DefaultPurgeCascade(),
+		routing:                    DefaultRoutingTable(),
+		primaryAuthorities:         DefaultPrimaryAuthorities(),
+		canonicalCache:             newCanonicalCache(defaultCanonicalCacheSize, defaultCanonicalCacheTTL),
+		sinkPipeline:               DefaultSinkPipeline(),
+		checkpoints:                newCheckpointStore(defaultCheckpointTTL),
+		attempts:                   newAttemptLog(),
+		sinks:                      map[string]Sink{},
+	}
+	for _, opt := range opts {
+		opt(svc)
+	}
+
+	svc.neoWriter = resilience.NewClient("concepts-rw-neo4j", httpClient, svc.resiliencePolicy)
+	svc.elasticsearchWriter = resilience.NewClient("concept-rw-elasticsearch", httpClient, svc.resiliencePolicy)
+	svc.varnishPurger = resilience.NewClient("varnish-purger", httpClient, svc.resiliencePolicy)
+	var elasticsearchSink Sink = &elasticsearchWriterSink{client: svc.elasticsearchWriter, address: elasticsearchAddress, routes: svc.routing}
+	if svc.bulkMaxItems > 0 {
+		elasticsearchSink = &elasticsearchBulkSink{writer: newBulkWriter(svc.elasticsearchWriter, elasticsearchAddress, svc.bulkMaxItems, svc.bulkFlushInterval, processTimeout), routes: svc.routing}
+	}
+	purgerSink := &varnishPurgerSink{client: svc.varnishPurger, address: varnishPurgerAddress, typesToPurgeFromPublicEndpoints: typesToPurgeFromPublicEndpoints, routes: svc.routing, cascade: svc.purgeCascade}
+	if svc.purgeWindow > 0 {
+		purgerSink.coalescer = newPurgeCoalescer(svc.varnishPurger, varnishPurgerAddress, svc.purgeWindow, svc.purgeBatchSize, processTimeout)
+	}
+	builtinSinks := []Sink{
+		&neo4jWriterSink{client: svc.neoWriter, address: neoAddress, routes: svc.routing},
+		purgerSink,
+		elasticsearchSink,
+		&eventsSink{client: eventsSQSClient},
+		&kinesisSink{client: kinesisClient},
+	}
+	for _, sink := range builtinSinks {
+		if _, replaced := svc.sinks[sink.Name()]; !replaced {
+			svc.sinks[sink.Name()] = sink
+		}
+	}
+	if bulk, ok := svc.sinks[ElasticsearchSink].(*elasticsearchBulkSink); ok {
+		go bulk.writer.run()
 	}
+	if purger, ok := svc.sinks[VarnishSink].(*varnishPurgerSink); ok && purger.coalescer != nil {
+		go purger.coalescer.run()
+	}
+	return svc
 }
 
 func (s *AggregateService) ListenForNotifications(ctx context.Context, workerID int) {
@@ -166,7 +380,7 @@ func (s *AggregateService) ListenForNotifications(ctx context.Context, workerID
 					defer reqWG.Done()
 					err := s.processConceptUpdate(ctx, update)
 					if err != nil {
-						logger.WithError(err).WithUUID(n.UUID).Error("Error processing message.")
+						logger.WithError(err).WithUUID(update.UUID).Error("Error processing message.")
 					}
 
 				}(listenCtx, &wg, n)
@@ -180,19 +394,9 @@ func (s *AggregateService) processConceptUpdate(ctx context.Context, n sqs.Conce
 	timeoutCtx, timeoutCancel := context.WithTimeout(ctx, s.processTimeout)
 	defer timeoutCancel()
 
-	errCh := make(chan error)
+	errCh := make(chan error, 1)
 	go func(ch chan<- error) {
-		internalErr := s.ProcessMessage(timeoutCtx, n.UUID, n.Bookmark)
-		if internalErr != nil {
-			ch <- internalErr
-			return
-		}
-		internalErr = s.conceptUpdatesSqs.RemoveMessageFromQueue(timeoutCtx, n.ReceiptHandle)
-		if internalErr != nil {
-			ch <- fmt.Errorf("error removing message from SQS: %w", internalErr)
-			return
-		}
-		ch <- nil
+		ch <- s.ProcessMessage(timeoutCtx, n.UUID, n.Bookmark)
 	}(errCh)
 
 	var err error
@@ -201,182 +405,217 @@ func (s *AggregateService) processConceptUpdate(ctx context.Context, n sqs.Conce
 		err = timeoutCtx.Err()
 	case err = <-errCh:
 	}
-
-	return err
-}
-
-func (s *AggregateService) ProcessMessage(ctx context.Context, UUID string, bookmark string) error {
-	// Get the concorded concept
-	concordedConcept, transactionID, err := s.GetConcordedConcept(ctx, UUID, bookmark)
 	if err != nil {
-		return err
+		return s.handleFailure(ctx, n, err)
 	}
-	if concordedConcept.PrefUUID != UUID {
-		logger.WithTransactionID(transactionID).WithUUID(UUID).Infof("Requested concept %s is source node for canonical concept %s", UUID, concordedConcept.PrefUUID)
+
+	s.attempts.forget(n)
+	if err = s.conceptUpdatesSqs.RemoveMessageFromQueue(ctx, n.ReceiptHandle); err != nil {
+		return fmt.Errorf("error removing message from SQS: %w", err)
 	}
+	return nil
+}
 
-	// Write to Neo4j
-	logger.WithTransactionID(transactionID).WithUUID(concordedConcept.PrefUUID).Debug("Sending concept to Neo4j")
-	conceptChanges, err := sendToWriter(ctx, s.httpClient, s.neoWriterAddress, resolveConceptType(concordedConcept.Type), concordedConcept.PrefUUID, concordedConcept, transactionID)
-	if err != nil {
-		return err
+// handleFailure records a failed attempt to process n. Permanent failures, and transient failures of updates
+// received maxReceiveCount times, are dead-lettered; other failures are returned so that the update is retried
+// once its visibility timeout expires.
+func (s *AggregateService) handleFailure(ctx context.Context, n sqs.ConceptUpdate, cause error) error {
+	class := classifyFailure(cause)
+	attempts := s.attempts.record(n, class, cause)
+	if class == FailurePermanent || (s.maxReceiveCount > 0 && n.ReceiveCount >= s.maxReceiveCount) {
+		return s.deadLetter(ctx, n, class, cause, attempts)
 	}
-	rawJson, err := json.Marshal(conceptChanges)
+	return cause
+}
+
+// deadLetter takes a notification that cannot be processed successfully off the queue, so that it is not
+// retried until it expires, forwards it to the dead-letter queue and raises an alert so the source data can be
+// fixed. Without a dead-letter queue the notification is left on the queue, so that the update is not lost.
+func (s *AggregateService) deadLetter(ctx context.Context, n sqs.ConceptUpdate, class string, cause error, attempts []sqs.Attempt) error {
+	log := logger.WithError(cause).
+		WithUUID(n.UUID).
+		WithField("alert_tag", "AggregateConceptTransformerDeadLetter").
+		WithField("error_class", class).
+		WithField("receive_count", n.ReceiveCount)
+	if s.deadLetterSqs == nil {
+		log.Error("Concept update cannot be processed and there is no dead-letter queue, leaving it on the queue")
+		return cause
+	}
+	log.Error("Concept update cannot be processed, removing it from the queue")
+	letter := sqs.DeadLetter{
+		UUID:         n.UUID,
+		Bookmark:     n.Bookmark,
+		MessageID:    n.MessageID,
+		ErrorClass:   class,
+		Error:        cause.Error(),
+		ReceiveCount: n.ReceiveCount,
+		Attempts:     attempts,
+	}
+	if err := s.deadLetterSqs.SendDeadLetter(ctx, letter); err != nil {
+		return fmt.Errorf("error sending message to dead-letter SQS queue: %w", err)
+	}
+	if err := s.conceptUpdatesSqs.RemoveMessageFromQueue(ctx, n.ReceiptHandle); err != nil {
+		return fmt.Errorf("error removing message from SQS: %w", err)
+	}
+	s.attempts.forget(n)
+	return nil
+}
+
+func (s *AggregateService) ProcessMessage(ctx context.Context, UUID string, bookmark string) error {
+	concordedConcept, transactionID, warnings, err := s.prepare(ctx, UUID, bookmark)
 	if err != nil {
-		logger.WithError(err).WithTransactionID(transactionID).WithUUID(concordedConcept.PrefUUID).Errorf("failed to marshall concept changes record: %v", conceptChanges)
 		return err
 	}
-	var updateRecord sqs.ConceptChanges
-	if err = json.Unmarshal(rawJson, &updateRecord); err != nil {
-		logger.WithError(err).WithTransactionID(transactionID).WithUUID(concordedConcept.PrefUUID).Errorf("failed to unmarshall raw json into update record: %v", rawJson)
-		return err
+	if len(warnings) > 0 {
+		logger.WithTransactionID(transactionID).WithUUID(concordedConcept.PrefUUID).WithField("violations", warnings).Warn("Concept has validation warnings")
 	}
 
-	if len(updateRecord.ChangedRecords) < 1 {
-		logger.WithTransactionID(transactionID).WithUUID(concordedConcept.PrefUUID).Info("concept was unchanged since last update, skipping!")
+	// Skip the writers when the concept has not changed since it was last written
+	hash, unchanged := s.isUnchanged(concordedConcept)
+	if unchanged {
+		logger.WithTransactionID(transactionID).WithUUID(concordedConcept.PrefUUID).Info("concept was unchanged since last written, skipping!")
 		return nil
 	}
-	logger.WithTransactionID(transactionID).WithUUID(concordedConcept.PrefUUID).Debug("concept successfully updated in neo4j")
-
-	// Purge concept URLs in varnish
-	// Always purge top level concept
-	if err = sendToPurger(ctx, s.httpClient, s.varnishPurgerAddress, updateRecord.UpdatedIds, concordedConcept.Type, s.typesToPurgeFromPublicEndpoints, transactionID); err != nil {
-		logger.WithTransactionID(transactionID).WithUUID(concordedConcept.PrefUUID).Errorf("Concept couldn't be purged from Varnish cache")
-	}
-
-	//optionally purge other affected concepts
-	if concordedConcept.Type == "FinancialInstrument" {
-		if err = sendToPurger(ctx, s.httpClient, s.varnishPurgerAddress, []string{concordedConcept.SourceRepresentations[0].IssuedBy}, "Organisation", s.typesToPurgeFromPublicEndpoints, transactionID); err != nil {
-			logger.WithTransactionID(transactionID).WithUUID(concordedConcept.SourceRepresentations[0].IssuedBy).Errorf("Concept couldn't be purged from Varnish cache")
-		}
-	}
 
-	if concordedConcept.Type == "Membership" {
-		if err = sendToPurger(ctx, s.httpClient, s.varnishPurgerAddress, []string{concordedConcept.PersonUUID}, "Person", s.typesToPurgeFromPublicEndpoints, transactionID); err != nil {
-			logger.WithTransactionID(transactionID).WithUUID(concordedConcept.PersonUUID).Errorf("Concept couldn't be purged from Varnish cache")
-		}
+	// Send to each sink in the pipeline
+	write := &SinkWrite{Concept: concordedConcept, TransactionID: transactionID}
+	if s.checkpoints.restore(write) {
+		logger.WithTransactionID(transactionID).WithUUID(concordedConcept.PrefUUID).WithField("completed", write.Completed).Info("Resuming sink pipeline from checkpoint")
 	}
+	err = s.sinkPipeline.run(ctx, s.sinks, write)
 
-	// Write to Elasticsearch
-	if isTypeAllowedInElastic(concordedConcept) {
-		logger.WithTransactionID(transactionID).WithUUID(concordedConcept.PrefUUID).Debug("Writing concept to elastic search")
-		if _, err = sendToWriter(ctx, s.httpClient, s.elasticsearchWriterAddress, resolveConceptType(concordedConcept.Type), concordedConcept.PrefUUID, concordedConcept, transactionID); err != nil {
-			return err
-		}
+	// The concordances of the concept may have changed, so relationships to it must be resolved again
+	s.canonicalCache.forget(write.Changes.UpdatedIds...)
+	for _, src := range concordedConcept.SourceRepresentations {
+		s.canonicalCache.forget(src.UUID)
 	}
-
-	if err = s.eventsSqs.SendEvents(ctx, updateRecord.ChangedRecords); err != nil {
-		logger.WithTransactionID(transactionID).WithUUID(concordedConcept.PersonUUID).Errorf("unable to send events: %v to Event Queue", updateRecord.ChangedRecords)
-		return err
-	}
-
-	//Send notification to stream
-	rawIDList, err := json.Marshal(conceptChanges.UpdatedIds)
 	if err != nil {
-		logger.WithError(err).WithTransactionID(transactionID).WithUUID(concordedConcept.PrefUUID).Errorf("failed to marshall concept changes record: %v", conceptChanges.UpdatedIds)
+		s.checkpoints.save(write)
 		return err
 	}
-	logger.WithTransactionID(transactionID).WithUUID(concordedConcept.PrefUUID).Debugf("sending notification of updated concepts to kinesis conceptsQueue: %v", conceptChanges)
-	if err = s.kinesis.AddRecordToStream(ctx, rawIDList, concordedConcept.Type); err != nil {
-		logger.WithError(err).WithTransactionID(transactionID).WithUUID(concordedConcept.PrefUUID).Errorf("Failed to update stream with notification record %v", conceptChanges)
-		return err
+	s.checkpoints.clear(write)
+	s.recordHash(concordedConcept.PrefUUID, hash)
+	if write.Unchanged {
+		return nil
 	}
 	logger.WithTransactionID(transactionID).WithUUID(concordedConcept.PrefUUID).Infof("Finished processing update of %s", UUID)
 
 	return nil
 }
 
-func bucketConcordances(concordanceRecords []concordances.ConcordanceRecord) (map[string][]concordances.ConcordanceRecord, string, error) {
-	if concordanceRecords == nil || len(concordanceRecords) == 0 {
-		err := fmt.Errorf("no concordances provided")
-		logger.WithError(err).Error("Error grouping concordance records")
-		return nil, "", err
+// prepare aggregates the concept of UUID and checks that it can be written, returning it with the transaction ID
+// and any validation warnings.
+func (s *AggregateService) prepare(ctx context.Context, UUID string, bookmark string) (ConcordedConcept, string, []Violation, error) {
+	// Get the concorded concept
+	concordedConcept, transactionID, err := s.GetConcordedConcept(ctx, UUID, bookmark)
+	if err != nil {
+		return ConcordedConcept{}, "", nil, err
+	}
+	if concordedConcept.PrefUUID != UUID {
+		logger.WithTransactionID(transactionID).WithUUID(UUID).Infof("Requested concept %s is source node for canonical concept %s", UUID, concordedConcept.PrefUUID)
 	}
 
-	bucketedConcordances := map[string][]concordances.ConcordanceRecord{}
-	for _, v := range concordanceRecords {
-		bucketedConcordances[v.Authority] = append(bucketedConcordances[v.Authority], v)
+	// Validate before anything is written
+	warnings, err := validateConcept(concordedConcept)
+	if err != nil {
+		return ConcordedConcept{}, "", nil, err
+	}
+	if err = s.checkHierarchy(ctx, concordedConcept, bookmark); err != nil {
+		return ConcordedConcept{}, "", nil, err
 	}
+	return concordedConcept, transactionID, warnings, nil
+}
 
-	var primaryAuthority string
-	var err error
-	slRecords, slFound := bucketedConcordances[smartlogicAuthority]
-	if slFound {
-		if len(slRecords) == 1 {
-			primaryAuthority = smartlogicAuthority
-		} else {
-			err = fmt.Errorf("more than 1 primary authority")
-		}
+// isUnchanged returns the hash of c and whether it matches the hash recorded when c was last written.
+// Failures of the hash store are logged and treated as a change, so that the concept is written.
+func (s *AggregateService) isUnchanged(c ConcordedConcept) (string, bool) {
+	if s.hashStore == nil {
+		return "", false
 	}
-	mlRecords, mlFound := bucketedConcordances[managedLocationAuthority]
-	if mlFound {
-		if len(mlRecords) == 1 {
-			if primaryAuthority == "" {
-				primaryAuthority = managedLocationAuthority
-			}
-		} else {
-			err = fmt.Errorf("more than 1 ManagedLocation primary authority")
-		}
+	hash, err := conceptHash(c)
+	if err != nil {
+		logger.WithError(err).WithUUID(c.PrefUUID).Error("Failed to hash concept")
+		return "", false
 	}
+	last, found, err := s.hashStore.Get(c.PrefUUID)
 	if err != nil {
-		logger.WithError(err).
-			WithField("alert_tag", "AggregateConceptTransformerMultiplePrimaryAuthorities").
-			WithField("primary_authorities", fmt.Sprintf("Smartlogic=%v, ManagedLocation=%v", slRecords, mlRecords)).
-			Error("Error grouping concordance records")
-		return nil, "", err
+		logger.WithError(err).WithUUID(c.PrefUUID).Error("Failed to read concept hash")
+		return hash, false
+	}
+	return hash, found && last == hash
+}
+
+func (s *AggregateService) recordHash(prefUUID string, hash string) {
+	if s.hashStore == nil || hash == "" {
+		return
+	}
+	if err := s.hashStore.Put(prefUUID, hash); err != nil {
+		logger.WithError(err).WithUUID(prefUUID).Error("Failed to record concept hash")
 	}
-	return bucketedConcordances, primaryAuthority, nil
 }
 
 func (s *AggregateService) GetConcordedConcept(ctx context.Context, UUID string, bookmark string) (ConcordedConcept, string, error) {
+	result, err := s.Aggregate(ctx, UUID, bookmark)
+	if err != nil {
+		return ConcordedConcept{}, "", err
+	}
+	return result.Concept, result.TransactionID, nil
+}
 
-	type concordedData struct {
-		Concept       ConcordedConcept
-		TransactionID string
-		Err           error
+func (s *AggregateService) Aggregate(ctx context.Context, UUID string, bookmark string) (AggregationResult, error) {
+
+	type aggregatedData struct {
+		Result AggregationResult
+		Err    error
 	}
-	ch := make(chan concordedData)
+	ch := make(chan aggregatedData)
 
 	go func() {
-		concept, tranID, err := s.getConcordedConcept(ctx, UUID, bookmark)
-		ch <- concordedData{Concept: concept, TransactionID: tranID, Err: err}
+		result, err := s.aggregate(ctx, UUID, bookmark)
+		ch <- aggregatedData{Result: result, Err: err}
 	}()
 	select {
 	case data := <-ch:
-		return data.Concept, data.TransactionID, data.Err
+		return data.Result, data.Err
 	case <-ctx.Done():
-		return ConcordedConcept{}, "", ctx.Err()
+		return AggregationResult{}, ctx.Err()
 	}
 }
 
-func (s *AggregateService) getConcordedConcept(ctx context.Context, UUID string, bookmark string) (ConcordedConcept, string, error) {
-	var scopeNoteOptions = map[string][]string{}
-	var transactionID string
-	var err error
-	concordedConcept := ConcordedConcept{}
+// canonicalNotFoundError is returned when the source concept of the primary authority of a concordance is not in S3.
+type canonicalNotFoundError struct {
+	UUID string
+}
+
+func (e *canonicalNotFoundError) Error() string {
+	return fmt.Sprintf("canonical concept %s not found in S3", e.UUID)
+}
 
+// loadSources reads from S3 the source concepts concorded with UUID, in merge order with the canonical source
+// last, along with their transaction IDs and the primary authority of the concordance.
+func (s *AggregateService) loadSources(ctx context.Context, UUID string, bookmark string) ([]s3.Concept, []string, string, error) {
 	concordedRecords, err := s.concordances.GetConcordance(ctx, UUID, bookmark)
 	if err != nil {
-		return ConcordedConcept{}, "", err
+		return nil, nil, "", err
 	}
 	logger.WithField("UUID", UUID).Debugf("Returned concordance record: %v", concordedRecords)
 
-	bucketedConcordances, primaryAuthority, err := bucketConcordances(concordedRecords)
+	bucketedConcordances, primaryAuthority, err := bucketConcordances(concordedRecords, s.primaryAuthorities)
 	if err != nil {
-		return ConcordedConcept{}, "", err
+		return nil, nil, "", &noCanonicalConceptError{cause: err}
 	}
 
-	// Get all concepts from S3
-	for authority, concordanceRecords := range bucketedConcordances {
+	// Get all concepts from S3, visiting the authorities in a stable order so that the merge is deterministic
+	var sources []s3.Concept
+	var sourceTransactionIDs []string
+	for _, authority := range sortedAuthorities(bucketedConcordances) {
 		if authority == primaryAuthority {
 			continue
 		}
-		for _, conc := range concordanceRecords {
-			var found bool
-			var sourceConcept s3.Concept
-			found, sourceConcept, transactionID, err = s.s3.GetConceptAndTransactionID(ctx, conc.UUID)
+		for _, conc := range bucketedConcordances[authority] {
+			found, sourceConcept, transactionID, err := s.s3.GetConceptAndTransactionID(ctx, conc.UUID)
 			if err != nil {
-				return ConcordedConcept{}, "", err
+				return nil, nil, "", err
 			}
 
 			if !found {
@@ -388,43 +627,109 @@ func (s *AggregateService) getConcordedConcept(ctx context.Context, UUID string,
 				sourceConcept.Type = "Thing"
 			}
 
-			concordedConcept = mergeCanonicalInformation(concordedConcept, sourceConcept, scopeNoteOptions)
+			sources = append(sources, sourceConcept)
+			sourceTransactionIDs = append(sourceTransactionIDs, transactionID)
 		}
 	}
 
 	if primaryAuthority != "" {
 		canonicalConcept := bucketedConcordances[primaryAuthority][0]
-		var found bool
-		var primaryConcept s3.Concept
-		found, primaryConcept, transactionID, err = s.s3.GetConceptAndTransactionID(ctx, canonicalConcept.UUID)
+		found, primaryConcept, transactionID, err := s.s3.GetConceptAndTransactionID(ctx, canonicalConcept.UUID)
 		if err != nil {
-			return ConcordedConcept{}, "", err
+			return nil, nil, "", err
 		} else if !found {
-			err = fmt.Errorf("canonical concept %s not found in S3", canonicalConcept.UUID)
+			err = &canonicalNotFoundError{UUID: canonicalConcept.UUID}
 			logger.WithField("UUID", UUID).Error(err.Error())
-			return ConcordedConcept{}, "", err
+			return nil, nil, "", err
 		}
-		concordedConcept = mergeCanonicalInformation(concordedConcept, primaryConcept, scopeNoteOptions)
+		sources = append(sources, primaryConcept)
+		sourceTransactionIDs = append(sourceTransactionIDs, transactionID)
 	}
-	concordedConcept.Aliases = deduplicateAndSkipEmptyAliases(concordedConcept.Aliases)
-	concordedConcept.ScopeNote = chooseScopeNote(concordedConcept, scopeNoteOptions)
-
-	return concordedConcept, transactionID, nil
+	return sources, sourceTransactionIDs, primaryAuthority, nil
 }
 
-func chooseScopeNote(concept ConcordedConcept, scopeNoteOptions map[string][]string) string {
-	if sn, ok := scopeNoteOptions[smartlogicAuthority]; ok {
-		return strings.Join(removeMatchingEntries(sn, concept.PrefLabel), " | ")
+func (s *AggregateService) aggregate(ctx context.Context, UUID string, bookmark string) (AggregationResult, error) {
+	sources, sourceTransactionIDs, primaryAuthority, err := s.loadSources(ctx, UUID, bookmark)
+	if err != nil {
+		return AggregationResult{}, err
+	}
+	transactionID := sourceTransactionIDs[len(sourceTransactionIDs)-1]
+
+	concordedConcept, suppliers, err := s.mergeCanonicalInformation(sources)
+	if err != nil {
+		logger.WithError(err).WithUUID(UUID).Error("Error merging source concepts")
+		return AggregationResult{}, err
 	}
-	if sn, ok := scopeNoteOptions["Wikidata"]; ok {
-		return strings.Join(removeMatchingEntries(sn, concept.PrefLabel), " | ")
+	unresolved, err := s.resolveRelationships(ctx, &concordedConcept, bookmark)
+	if err != nil {
+		logger.WithError(err).WithUUID(UUID).Error("Error resolving relationships to canonical concepts")
+		return AggregationResult{}, err
+	}
+	if len(unresolved) > 0 {
+		logger.WithField("UUID", UUID).
+			WithField("alert_tag", "AggregateConceptTransformerUnresolvedReferences").
+			WithField("unresolved_references", unresolved).
+			Warn("Relationships could not be resolved to canonical concepts")
 	}
-	if sn, ok := scopeNoteOptions["TME"]; ok {
-		if concept.Type == "Location" {
-			return strings.Join(removeMatchingEntries(sn, concept.PrefLabel), " | ")
+
+	lineage, err := s.followSupersession(ctx, concordedConcept, bookmark)
+	if err != nil {
+		logger.WithError(err).WithUUID(UUID).Error("Error following supersession chains")
+		return AggregationResult{}, err
+	}
+	concordedConcept.TerminalSupersededByUUIDs = lineage.TerminalUUIDs
+	if len(lineage.Unresolved) > 0 {
+		logger.WithField("UUID", UUID).
+			WithField("alert_tag", "AggregateConceptTransformerUnresolvedReferences").
+			WithField("unresolved_references", lineage.Unresolved).
+			Warn("Supersession chains lead to concepts that cannot be aggregated")
+	}
+	if len(lineage.Cycles) > 0 {
+		logger.WithField("UUID", UUID).
+			WithField("alert_tag", "AggregateConceptTransformerSupersessionCycle").
+			WithField("cycles", lineage.Cycles).
+			Warn("Supersession chains loop back on themselves")
+	}
+
+	conflicts := detectConflicts(sources, primaryAuthority != "", s.mergePolicy)
+	if conflict, ok := s.typeHierarchy.conflict(sources); ok {
+		conflicts = append(conflicts, conflict)
+	}
+	if len(conflicts) > 0 {
+		logger.WithField("UUID", UUID).
+			WithField("alert_tag", "AggregateConceptTransformerMergeConflicts").
+			WithField("conflicting_fields", conflictingFields(conflicts)).
+			Warn("Source concepts supply conflicting values")
+	}
+
+	provenance := Provenance{}
+	for field, from := range suppliers {
+		for _, i := range from {
+			provenance[field] = append(provenance[field], FieldProvenance{
+				Authority:     sources[i].Authority,
+				SourceUUID:    sources[i].UUID,
+				TransactionID: sourceTransactionIDs[i],
+			})
 		}
 	}
-	return ""
+
+	return AggregationResult{
+		Concept:       concordedConcept,
+		TransactionID: transactionID,
+		Provenance:    provenance,
+		Conflicts:     conflicts,
+		Unresolved:    unresolved,
+		Lineage:       lineage,
+	}, nil
+}
+
+func sortedAuthorities(bucketedConcordances map[string][]concordances.ConcordanceRecord) []string {
+	authorities := make([]string, 0, len(bucketedConcordances))
+	for authority := range bucketedConcordances {
+		authorities = append(authorities, authority)
+	}
+	sort.Strings(authorities)
+	return authorities
 }
 
 func removeMatchingEntries(slice []string, matcher string) []string {
@@ -438,7 +743,7 @@ func removeMatchingEntries(slice []string, matcher string) []string {
 }
 
 func (s *AggregateService) Healthchecks() []fthealth.Check {
-	return []fthealth.Check{
+	checks := []fthealth.Check{
 		s.s3.Healthcheck(),
 		s.conceptUpdatesSqs.Healthcheck(),
 		s.RWElasticsearchHealthCheck(),
@@ -447,195 +752,95 @@ func (s *AggregateService) Healthchecks() []fthealth.Check {
 		s.concordances.Healthcheck(),
 		s.kinesis.Healthcheck(),
 	}
+	if s.deadLetterSqs != nil {
+		checks = append(checks, s.deadLetterSqs.Healthcheck())
+	}
+	return checks
 }
 
-func deduplicateAndSkipEmptyAliases(aliases []string) []string {
-	aMap := map[string]bool{}
-	var outAliases []string
-	for _, v := range aliases {
-		if v == "" {
-			continue
+// mergeCanonicalInformation builds the concorded concept from sources, which are in merge order with the
+// canonical source last. The fields covered by the merge policy are chosen by the policy of the service.
+// It also returns, by JSON field name, the indexes of the sources that supplied each populated field.
+// A *ValidationError is returned when the sources declare types the type hierarchy marks as incompatible; other
+// types on different branches are merged by taking the type of the later source.
+func (s *AggregateService) mergeCanonicalInformation(sources []s3.Concept) (ConcordedConcept, map[string][]int, error) {
+	c := ConcordedConcept{}
+	if len(sources) == 0 {
+		return c, map[string][]int{}, nil
+	}
+	canonical := len(sources) - 1
+	var typeSource int
+	var aliasSources []int
+	for i, src := range sources {
+		for _, earlier := range sources[:i] {
+			if s.typeHierarchy.incompatible(earlier.Type, src.Type) {
+				return ConcordedConcept{}, nil, &ValidationError{
+					UUID: sources[canonical].UUID,
+					Type: c.Type,
+					Violations: []Violation{{
+						Field: "type",
+						Message: fmt.Sprintf("%s of %s (%s) is incompatible with %s of %s (%s)",
+							src.Type, src.UUID, src.Authority, earlier.Type, earlier.UUID, earlier.Authority),
+						Severity: SeverityFatal,
+					}},
+				}
+			}
 		}
-		aMap[v] = true
-	}
-	for a := range aMap {
-		outAliases = append(outAliases, a)
+		t, ok := s.typeHierarchy.moreSpecific(c.Type, src.Type)
+		if !ok {
+			t = src.Type
+		}
+		if t != c.Type {
+			c.Type = t
+			typeSource = i
+		}
+		c.Aliases = append(c.Aliases, src.Aliases...)
+		c.Aliases = append(c.Aliases, src.PrefLabel)
+		if src.PrefLabel != "" || len(src.Aliases) > 0 {
+			aliasSources = append(aliasSources, i)
+		}
+		c.SourceRepresentations = append(c.SourceRepresentations, src)
 	}
-	return outAliases
-}
+	c.PrefUUID = sources[canonical].UUID
 
-func getMoreSpecificType(existingType string, newType string) string {
-
-	// Thing type shouldn't wipe things.
-	if newType == "Thing" && existingType != "" {
-		return existingType
+	suppliers := s.mergePolicy.apply(&c, sources)
+	c.MembershipRoles = reconcileMembershipRoles(c.MembershipRoles)
+	c.NAICSIndustryClassifications = reconcileNAICSClassifications(c.NAICSIndustryClassifications)
+	suppliers["prefUUID"] = []int{canonical}
+	if c.Type != "" {
+		suppliers["type"] = []int{typeSource}
 	}
 
-	// If we've already called it a PublicCompany, keep that information.
-	if existingType == "PublicCompany" && (newType == "Organisation" || newType == "Company") {
-		return existingType
+	c.Aliases = s.aliasRules.forType(c.Type).normalise(c.Aliases, c.PrefLabel)
+	if len(c.Aliases) > 0 {
+		suppliers["aliases"] = aliasSources
 	}
-	return newType
-}
 
-func buildScopeNoteOptions(scopeNotes map[string][]string, s s3.Concept) {
-	var newScopeNote string
-	if s.Authority == "TME" {
-		newScopeNote = s.PrefLabel
-	} else {
-		newScopeNote = s.ScopeNote
-	}
-	if newScopeNote != "" {
-		scopeNotes[s.Authority] = append(scopeNotes[s.Authority], newScopeNote)
+	var scopeNoteSources []int
+	c.ScopeNote, scopeNoteSources = s.scopeNoteRules.choose(c, sources)
+	if c.ScopeNote != "" {
+		suppliers["scopeNote"] = scopeNoteSources
 	}
+	return c, suppliers, nil
 }
 
-func mergeCanonicalInformation(c ConcordedConcept, s s3.Concept, scopeNoteOptions map[string][]string) ConcordedConcept {
-	c.PrefUUID = s.UUID
-	c.PrefLabel = s.PrefLabel
-	c.Type = getMoreSpecificType(c.Type, s.Type)
-	c.Aliases = append(c.Aliases, s.Aliases...)
-	c.Aliases = append(c.Aliases, s.PrefLabel)
-	if s.Strapline != "" {
-		c.Strapline = s.Strapline
-	}
-	if s.DescriptionXML != "" {
-		c.DescriptionXML = s.DescriptionXML
-	}
-	if s.ImageURL != "" {
-		c.ImageURL = s.ImageURL
-	}
-	if s.EmailAddress != "" {
-		c.EmailAddress = s.EmailAddress
-	}
-	if s.FacebookPage != "" {
-		c.FacebookPage = s.FacebookPage
-	}
-	if s.TwitterHandle != "" {
-		c.TwitterHandle = s.TwitterHandle
-	}
-	buildScopeNoteOptions(scopeNoteOptions, s)
-	if s.ShortLabel != "" {
-		c.ShortLabel = s.ShortLabel
-	}
-	if len(s.SupersededByUUIDs) > 0 {
-		c.SupersededByUUIDs = s.SupersededByUUIDs
-	}
-	if len(s.ParentUUIDs) > 0 {
-		c.ParentUUIDs = s.ParentUUIDs
-	}
-	if len(s.BroaderUUIDs) > 0 {
-		c.BroaderUUIDs = s.BroaderUUIDs
-	}
-	if len(s.RelatedUUIDs) > 0 {
-		c.RelatedUUIDs = s.RelatedUUIDs
-	}
-	c.SourceRepresentations = append(c.SourceRepresentations, s)
-	if s.ProperName != "" {
-		c.ProperName = s.ProperName
-	}
-	if s.ShortName != "" {
-		c.ShortName = s.ShortName
-	}
-	if len(s.TradeNames) > 0 {
-		c.TradeNames = s.TradeNames
-	}
-	if len(s.FormerNames) > 0 {
-		c.FormerNames = s.FormerNames
-	}
-	if s.CountryCode != "" {
-		c.CountryCode = s.CountryCode
-	}
-	if s.CountryOfRisk != "" {
-		c.CountryOfRisk = s.CountryOfRisk
-	}
-	if s.CountryOfIncorporation != "" {
-		c.CountryOfIncorporation = s.CountryOfIncorporation
-	}
-	if s.CountryOfOperations != "" {
-		c.CountryOfOperations = s.CountryOfOperations
-	}
-	if s.PostalCode != "" {
-		c.PostalCode = s.PostalCode
-	}
-	if s.YearFounded > 0 {
-		c.YearFounded = s.YearFounded
-	}
-	if s.LeiCode != "" {
-		c.LeiCode = s.LeiCode
-	}
-	if s.BirthYear > 0 {
-		c.BirthYear = s.BirthYear
-	}
-	if s.Salutation != "" {
-		c.Salutation = s.Salutation
-	}
-	if s.ISO31661 != "" {
-		c.ISO31661 = s.ISO31661
-	}
-
-	for _, mr := range s.MembershipRoles {
-		c.MembershipRoles = append(c.MembershipRoles, MembershipRole{
-			RoleUUID:        mr.RoleUUID,
-			InceptionDate:   mr.InceptionDate,
-			TerminationDate: mr.TerminationDate,
-		})
-	}
+func sendToPurger(ctx context.Context, client httpClient, baseURL string, conceptUUIDs []string, conceptType string, routes RoutingTable, conceptTypesWithPublicEndpoints []string, tid string) error {
 
-	for _, ic := range s.NAICSIndustryClassifications {
-		c.NAICSIndustryClassifications = append(c.NAICSIndustryClassifications, NAICSIndustryClassification{
-			UUID: ic.UUID,
-			Rank: ic.Rank,
-		})
+	targets := purgeTargets(conceptUUIDs, conceptType, routes, conceptTypesWithPublicEndpoints)
+	if err := postPurge(ctx, client, baseURL, targets); err != nil {
+		return err
 	}
+	logger.WithTransactionID(tid).Debugf("Concepts with ids %s successfully purged from varnish cache", conceptUUIDs)
 
-	if s.OrganisationUUID != "" {
-		c.OrganisationUUID = s.OrganisationUUID
-	}
-	if s.PersonUUID != "" {
-		c.PersonUUID = s.PersonUUID
-	}
-	if s.InceptionDate != "" {
-		c.InceptionDate = s.InceptionDate
-	}
-	if s.TerminationDate != "" {
-		c.TerminationDate = s.TerminationDate
-	}
-	if s.FigiCode != "" {
-		c.FigiCode = s.FigiCode
-	}
-	if s.IssuedBy != "" {
-		c.IssuedBy = s.IssuedBy
-	}
-	if s.IndustryIdentifier != "" {
-		c.IndustryIdentifier = s.IndustryIdentifier
-	}
-	c.IsDeprecated = s.IsDeprecated
-	return c
+	return nil
 }
 
-func sendToPurger(ctx context.Context, client httpClient, baseURL string, conceptUUIDs []string, conceptType string, conceptTypesWithPublicEndpoints []string, tid string) error {
-
-	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimRight(baseURL, "/")+"/purge", nil)
+func postPurge(ctx context.Context, client httpClient, baseURL string, targets []string) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", purgeURL(baseURL, targets), nil)
 	if err != nil {
 		return err
 	}
 
-	queryParams := req.URL.Query()
-	for _, cUUID := range conceptUUIDs {
-		queryParams.Add("target", thingsAPIEndpoint+"/"+cUUID)
-		queryParams.Add("target", conceptsAPIEnpoint+"/"+cUUID)
-	}
-
-	if contains(conceptType, conceptTypesWithPublicEndpoints) {
-		urlParam := resolveConceptType(conceptType)
-		for _, cUUID := range conceptUUIDs {
-			queryParams.Add("target", "/"+urlParam+"/"+cUUID)
-		}
-	}
-
-	req.URL.RawQuery = queryParams.Encode()
-
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
@@ -646,9 +851,28 @@ func sendToPurger(ctx context.Context, client httpClient, baseURL string, concep
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("request was not successful, status code: %v", resp.StatusCode)
 	}
-	logger.WithTransactionID(tid).Debugf("Concepts with ids %s successfully purged from varnish cache", conceptUUIDs)
+	return nil
+}
+
+// purgeTargets returns the paths that are purged from Varnish for concepts of conceptType. The public endpoint is
+// purged for the types listed in conceptTypesWithPublicEndpoints and those routes mark to have it purged.
+func purgeTargets(conceptUUIDs []string, conceptType string, routes RoutingTable, conceptTypesWithPublicEndpoints []string) []string {
+	var targets []string
+	for _, cUUID := range conceptUUIDs {
+		targets = append(targets, thingsAPIEndpoint+"/"+cUUID, conceptsAPIEnpoint+"/"+cUUID)
+	}
 
-	return err
+	if contains(conceptType, conceptTypesWithPublicEndpoints) || routes.purgesPublicEndpoint(conceptType) {
+		urlParam := routes.path(conceptType)
+		for _, cUUID := range conceptUUIDs {
+			targets = append(targets, "/"+urlParam+"/"+cUUID)
+		}
+	}
+	return targets
+}
+
+func purgeURL(baseURL string, targets []string) string {
+	return strings.TrimRight(baseURL, "/") + "/purge?" + url.Values{"target": targets}.Encode()
 }
 
 func contains(element string, types []string) bool {
@@ -707,7 +931,7 @@ func sendToWriter(ctx context.Context, client httpClient, baseURL string, urlPar
 
 func createWriteRequest(ctx context.Context, baseURL string, urlParam string, msgBody io.Reader, uuid string) (*http.Request, string, error) {
 
-	reqURL := strings.TrimRight(baseURL, "/") + "/" + urlParam + "/" + uuid
+	reqURL := writeURL(baseURL, urlParam, uuid)
 
 	request, err := http.NewRequestWithContext(ctx, "PUT", reqURL, msgBody)
 	if err != nil {
@@ -716,13 +940,8 @@ func createWriteRequest(ctx context.Context, baseURL string, urlParam string, ms
 	return request, reqURL, err
 }
 
-//Turn stored singular type to plural form
-func resolveConceptType(conceptType string) string {
-	if ipath, ok := irregularConceptTypePaths[conceptType]; ok && ipath != "" {
-		return ipath
-	}
-
-	return toSnakeCase(conceptType) + "s"
+func writeURL(baseURL string, urlParam string, uuid string) string {
+	return strings.TrimRight(baseURL, "/") + "/" + urlParam + "/" + uuid
 }
 
 var matchFirstCap = regexp.MustCompile("(.)([A-Z][a-z]+)")
@@ -755,6 +974,9 @@ func (s *AggregateService) RWNeo4JHealthCheck() fthealth.Check {
 			if resp != nil && resp.StatusCode != http.StatusOK {
 				return "", fmt.Errorf("writer %v returned status %d", urlToCheck, resp.StatusCode)
 			}
+			if err = s.neoWriter.BreakerErr(); err != nil {
+				return "", err
+			}
 			return "", nil
 		},
 	}
@@ -781,6 +1003,9 @@ func (s *AggregateService) VarnishPurgerHealthCheck() fthealth.Check {
 			if resp.StatusCode != http.StatusOK {
 				return "", fmt.Errorf("purger %v returned status %d", urlToCheck, resp.StatusCode)
 			}
+			if err = s.varnishPurger.BreakerErr(); err != nil {
+				return "", err
+			}
 			return "", nil
 		},
 	}
@@ -807,30 +1032,10 @@ func (s *AggregateService) RWElasticsearchHealthCheck() fthealth.Check {
 			if resp != nil && resp.StatusCode != http.StatusOK {
 				return "", fmt.Errorf("writer %v returned status %d", urlToCheck, resp.StatusCode)
 			}
+			if err = s.elasticsearchWriter.BreakerErr(); err != nil {
+				return "", err
+			}
 			return "", nil
 		},
 	}
 }
-
-func isTypeAllowedInElastic(concordedConcept ConcordedConcept) bool {
-	switch concordedConcept.Type {
-	case "FinancialInstrument": //, "MembershipRole", "BoardRole":
-		return false
-	case "MembershipRole":
-		return false
-	case "BoardRole":
-		return false
-	case "Membership":
-		for _, sr := range concordedConcept.SourceRepresentations {
-			//Allow smartlogic curated memberships through to elasticsearch as we will use them to discover authors
-			if sr.Authority == "Smartlogic" {
-				return true
-			}
-		}
-		return false
-	case "IndustryClassification", "NAICSIndustryClassification":
-		return false
-	}
-
-	return true
-}
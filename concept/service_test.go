@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/Financial-Times/aggregate-concept-transformer/concordances"
+	"github.com/Financial-Times/aggregate-concept-transformer/resilience"
 	"github.com/Financial-Times/aggregate-concept-transformer/s3"
 	"github.com/Financial-Times/aggregate-concept-transformer/sqs"
 	"github.com/stretchr/testify/assert"
@@ -106,14 +107,17 @@ func TestAggregateService_ListenForNotifications_ProcessConceptNotInS3(t *testin
 	var receiptHandle = "1"
 	var nonExistingConcept = "99247059-04ec-3abb-8693-a0b8951fdkor"
 	mockSqsClient.conceptsQueue[receiptHandle] = nonExistingConcept
-	go svc.ListenForNotifications(context.Background(), 1)
-	time.Sleep(500 * time.Microsecond)
 	hasIt, _, _, err := s3mock.GetConceptAndTransactionID(context.Background(), nonExistingConcept)
 	assert.Equal(t, hasIt, false)
 	assert.NoError(t, err)
-	err = mockSqsClient.RemoveMessageFromQueue(context.Background(), &receiptHandle)
-	assert.Equal(t, 0, len(mockSqsClient.Queue()))
-	assert.NoError(t, err)
+	go svc.ListenForNotifications(context.Background(), 1)
+	// The canonical concept is missing, but without a dead-letter queue the update is left on the queue
+	assert.Eventually(t, func() bool {
+		svc.attempts.mu.Lock()
+		defer svc.attempts.mu.Unlock()
+		return len(svc.attempts.attempts) > 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Contains(t, mockSqsClient.Queue(), receiptHandle)
 }
 
 func TestAggregateService_ListenForNotifications_CannotProcessRemoveMessageNotPresentOnQueue(t *testing.T) {
@@ -443,6 +447,9 @@ func TestAggregateService_GetConcordedConcept_SupersededConcept(t *testing.T) {
 		SupersededByUUIDs: []string{
 			"28090964-9997-4bc2-9638-7a11135aaff9",
 		},
+		TerminalSupersededByUUIDs: []string{
+			"28090964-9997-4bc2-9638-7a11135aaff9",
+		},
 		MembershipRoles: []MembershipRole{
 			{
 				RoleUUID:        "ccdff192-4d6c-4539-bbe8-7e24e81ed49e",
@@ -504,6 +511,8 @@ func TestAggregateService_GetConcordedConcept_ConceptWithRelationships(t *testin
 		ParentUUIDs:    []string{"ec467314-63cf-4976-a124-77175d10423d"},
 		BroaderUUIDs:   []string{"575a2223-6307-4000-8882-935c27f4e8bb"},
 		RelatedUUIDs:   []string{"b73e632c-9b8d-477d-bb45-aaf574bc015c"},
+		ImpliedByUUIDs: []string{"b5d7c6b5-db7d-4bce-9d6a-f62195571f92"},
+		HasFocusUUIDs:  []string{"2e7429bd-7a84-41cb-a619-2c702893e359"},
 		DescriptionXML: "<body>The best brand</body>",
 		Strapline:      "The Best Brand",
 		ImageURL:       "localhost:8080/12345",
@@ -578,19 +587,22 @@ func TestAggregateService_GetConcordedConcept_Organisation(t *testing.T) {
 		},
 		Aliases: []string{
 			"Strix Group Plc",
-			"STRIX GROUP PLC",
 			"Strix Group",
 			"Castletown Thermostats",
 			"Steam Plc",
 		},
-		CountryCode:            "GB",
-		CountryOfRisk:          "GB",
-		CountryOfIncorporation: "IM",
-		CountryOfOperations:    "GB",
-		PostalCode:             "IM9 2RG",
-		YearFounded:            1951,
-		EmailAddress:           "info@strix.com",
-		LeiCode:                "213800KZEW5W6BZMNT62",
+		CountryCode:                "GB",
+		CountryOfRisk:              "GB",
+		CountryOfIncorporation:     "IM",
+		CountryOfOperations:        "GB",
+		CountryOfRiskUUID:          "GB_UUID",
+		CountryOfIncorporationUUID: "IM_UUID",
+		CountryOfOperationsUUID:    "GB_UUID",
+		ParentOrganisation:         "123",
+		PostalCode:                 "IM9 2RG",
+		YearFounded:                1951,
+		EmailAddress:               "info@strix.com",
+		LeiCode:                    "213800KZEW5W6BZMNT62",
 		SourceRepresentations: []s3.Concept{
 			{
 				UUID:       "c28fa0b4-4245-11e8-842f-0ed5f89f718b",
@@ -637,6 +649,104 @@ func TestAggregateService_GetConcordedConcept_Organisation(t *testing.T) {
 	assert.Equal(t, expectedConcept, c)
 }
 
+func TestAggregateService_GetConcordedConcept_ResolvesRelationshipsToCanonicalConcepts(t *testing.T) {
+	svc, _, _, _, _, _, _ := setupTestService(200, payload)
+	concordClient := svc.concordances.(*mockConcordancesClient)
+	concordClient.concordances["GB_UUID"] = []concordances.ConcordanceRecord{
+		{UUID: "GB_UUID", Authority: "FACTSET"},
+		{UUID: "canonical-gb", Authority: "Smartlogic"},
+	}
+	concordClient.concordances["b5d7c6b5-db7d-4bce-9d6a-f62195571f92"] = []concordances.ConcordanceRecord{
+		{UUID: "b5d7c6b5-db7d-4bce-9d6a-f62195571f92", Authority: "TME"},
+		{UUID: "canonical-implied", Authority: "Smartlogic"},
+	}
+
+	c, _, err := svc.GetConcordedConcept(context.Background(), "c28fa0b4-4245-11e8-842f-0ed5f89f718b", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "canonical-gb", c.CountryOfRiskUUID)
+	assert.Equal(t, "canonical-gb", c.CountryOfOperationsUUID)
+	assert.Equal(t, "IM_UUID", c.CountryOfIncorporationUUID)
+	assert.Equal(t, "GB_UUID", c.SourceRepresentations[0].CountryOfRiskUUID)
+
+	c, _, err = svc.GetConcordedConcept(context.Background(), "781bb463-dc53-4d3e-9d49-c48dc4cf6d55", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"canonical-implied"}, c.ImpliedByUUIDs)
+
+	concordClient.concordances["613b1f72-cc74-4d8f-9406-28fc91b82a2a"] = []concordances.ConcordanceRecord{
+		{UUID: "613b1f72-cc74-4d8f-9406-28fc91b82a2a", Authority: "FACTSET"},
+		{UUID: "canonical-issuer", Authority: "Smartlogic"},
+	}
+	concordClient.concordances["a4528fc9-0615-4bfa-bc99-596ea1ddec28"] = []concordances.ConcordanceRecord{
+		{UUID: "a4528fc9-0615-4bfa-bc99-596ea1ddec28", Authority: "TME"},
+		{UUID: "canonical-organisation", Authority: "ManagedLocation"},
+	}
+	c, _, err = svc.GetConcordedConcept(context.Background(), "28090964-9997-4bc2-9638-7a11135aaff9", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "canonical-issuer", c.IssuedBy)
+	assert.Equal(t, "canonical-organisation", c.OrganisationUUID)
+}
+
+func TestAggregateService_GetConcordedConcept_CachesCanonicalUUIDs(t *testing.T) {
+	svc, _, _, _, _, _, _ := setupTestService(200, payload)
+	concordClient := svc.concordances.(*mockConcordancesClient)
+	concordClient.concordances["GB_UUID"] = []concordances.ConcordanceRecord{
+		{UUID: "GB_UUID", Authority: "FACTSET"},
+		{UUID: "canonical-gb", Authority: "Smartlogic"},
+	}
+	countLookups := func() int {
+		var n int
+		for _, UUID := range concordClient.calls {
+			if UUID == "GB_UUID" {
+				n++
+			}
+		}
+		return n
+	}
+
+	_, _, err := svc.GetConcordedConcept(context.Background(), "c28fa0b4-4245-11e8-842f-0ed5f89f718b", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, countLookups())
+
+	_, _, err = svc.GetConcordedConcept(context.Background(), "c28fa0b4-4245-11e8-842f-0ed5f89f718b", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, countLookups())
+
+	_, _, err = svc.GetConcordedConcept(context.Background(), "c28fa0b4-4245-11e8-842f-0ed5f89f718b", "bookmark")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, countLookups())
+
+	svc.canonicalCache.forget("GB_UUID")
+	_, _, err = svc.GetConcordedConcept(context.Background(), "c28fa0b4-4245-11e8-842f-0ed5f89f718b", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, countLookups())
+}
+
+func TestAggregateService_GetConcordedConcept_RelationshipResolutionFailure(t *testing.T) {
+	svc, _, _, _, _, _, _ := setupTestService(200, payload)
+	svc.concordances.(*mockConcordancesClient).uuidErrs = map[string]error{"GB_UUID": errors.New("concordances unavailable")}
+
+	_, _, err := svc.GetConcordedConcept(context.Background(), "c28fa0b4-4245-11e8-842f-0ed5f89f718b", "")
+	assert.EqualError(t, err, "failed to resolve canonical concept for GB_UUID: concordances unavailable")
+}
+
+func TestAggregateService_Aggregate_ReportsUnresolvedReferences(t *testing.T) {
+	svc, _, _, _, _, _, _ := setupTestService(200, payload)
+	svc.concordances.(*mockConcordancesClient).concordances["GB_UUID"] = []concordances.ConcordanceRecord{
+		{UUID: "GB_UUID", Authority: "FACTSET"},
+		{UUID: "canonical-gb", Authority: "Smartlogic"},
+		{UUID: "another-gb", Authority: "Smartlogic"},
+	}
+
+	result, err := svc.Aggregate(context.Background(), "c28fa0b4-4245-11e8-842f-0ed5f89f718b", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "GB_UUID", result.Concept.CountryOfRiskUUID)
+	assert.Equal(t, "GB_UUID", result.Concept.CountryOfOperationsUUID)
+	assert.Equal(t, []UnresolvedReference{
+		{Field: "countryOfRiskUUID", UUID: "GB_UUID", Reason: "no single canonical concept: more than 1 Smartlogic primary authority"},
+		{Field: "countryOfOperationsUUID", UUID: "GB_UUID", Reason: "no single canonical concept: more than 1 Smartlogic primary authority"},
+	}, result.Unresolved)
+}
+
 func TestAggregateService_GetConcordedConcept_PublicCompany(t *testing.T) {
 	svc, _, _, _, _, _, _ := setupTestService(200, payload)
 	expectedConcept := ConcordedConcept{
@@ -652,20 +762,23 @@ func TestAggregateService_GetConcordedConcept_PublicCompany(t *testing.T) {
 		},
 		Aliases: []string{
 			"Strix Group Plc",
-			"STRIX GROUP PLC",
 			"Strix Group",
 			"Castletown Thermostats",
 			"Steam Plc",
 			"Test FT Concorded Organisation",
 		},
-		CountryCode:            "GB",
-		CountryOfRisk:          "GB",
-		CountryOfIncorporation: "IM",
-		CountryOfOperations:    "GB",
-		PostalCode:             "IM9 2RG",
-		YearFounded:            1951,
-		EmailAddress:           "info@strix.com",
-		LeiCode:                "213800KZEW5W6BZMNT62",
+		CountryCode:                "GB",
+		CountryOfRisk:              "GB",
+		CountryOfIncorporation:     "IM",
+		CountryOfOperations:        "GB",
+		CountryOfRiskUUID:          "GB_UUID",
+		CountryOfIncorporationUUID: "IM_UUID",
+		CountryOfOperationsUUID:    "GB_UUID",
+		ParentOrganisation:         "123",
+		PostalCode:                 "IM9 2RG",
+		YearFounded:                1951,
+		EmailAddress:               "info@strix.com",
+		LeiCode:                    "213800KZEW5W6BZMNT62",
 		SourceRepresentations: []s3.Concept{
 			{
 				UUID:       "c28fa0b4-4245-11e8-842f-0ed5f89f718b",
@@ -931,6 +1044,25 @@ func TestAggregateService_GetConcordedConcept_IndustryClassification(t *testing.
 	assert.Equal(t, expectedConcept, c)
 }
 
+func TestAggregateService_Aggregate_Provenance(t *testing.T) {
+	svc, _, _, _, _, _, _ := setupTestService(200, payload)
+
+	result, err := svc.Aggregate(context.Background(), "a141f50f-31d7-4f89-8143-eec971e54ba8", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "tid_636", result.TransactionID)
+
+	factset := FieldProvenance{Authority: "FACTSET", SourceUUID: "c28fa0b4-4245-11e8-842f-0ed5f89f718b", TransactionID: "tid_631"}
+	smartlogic := FieldProvenance{Authority: "Smartlogic", SourceUUID: "a141f50f-31d7-4f89-8143-eec971e54ba8", TransactionID: "tid_636"}
+	assert.Equal(t, []FieldProvenance{factset}, result.Provenance["leiCode"])
+	assert.Equal(t, []FieldProvenance{factset}, result.Provenance["countryOfIncorporation"])
+	assert.Equal(t, []FieldProvenance{smartlogic}, result.Provenance["prefLabel"])
+	assert.Equal(t, []FieldProvenance{smartlogic}, result.Provenance["prefUUID"])
+	assert.Equal(t, []FieldProvenance{factset}, result.Provenance["type"])
+	assert.Equal(t, []FieldProvenance{factset, smartlogic}, result.Provenance["aliases"])
+	assert.NotContains(t, result.Provenance, "scopeNote")
+	assert.NotContains(t, result.Provenance, "isDeprecated")
+}
+
 func TestAggregateService_ProcessMessage_Success(t *testing.T) {
 	svc, _, _, eventQueue, _, _, _ := setupTestService(200, payload)
 	err := svc.ProcessMessage(context.Background(), "28090964-9997-4bc2-9638-7a11135aaff9", "")
@@ -1006,6 +1138,8 @@ func TestAggregateService_ProcessMessage_FactsetMembershipNotSentToEs(t *testing
 			"%2F34a571fb-d779-4610-a7ba-2e127676db4d&target=%2Fconcepts%2F34a571fb-d779-4610-a7ba-2e127676db4d",
 		"varnish-purger/purge?target=%2Fthings%2F99309d51-8969-4a1e-8346-d51f1981479b&target=%2F" +
 			"concepts%2F99309d51-8969-4a1e-8346-d51f1981479b&target=%2Fpeople%2F99309d51-8969-4a1e-8346-d51f1981479b",
+		"varnish-purger/purge?target=%2Fthings%2Fa141f50f-31d7-4f89-8143-eec971e54ba8&target=%2Fconcepts" +
+			"%2Fa141f50f-31d7-4f89-8143-eec971e54ba8&target=%2Forganisations%2Fa141f50f-31d7-4f89-8143-eec971e54ba8",
 	}, mockWriter.called)
 	assert.NoError(t, err)
 	assert.Equal(t, 3, len(eventQueue.eventList))
@@ -1022,6 +1156,8 @@ func TestAggregateService_ProcessMessage_SmartlogicMembershipSentToEs(t *testing
 			"&target=%2Fconcepts%2F34a571fb-d779-4610-a7ba-2e127676db4d",
 		"varnish-purger/purge?target=%2Fthings%2F63ffa4d3-d7cc-4939-9bec-9ed46a78389e&target=%2Fconcepts" +
 			"%2F63ffa4d3-d7cc-4939-9bec-9ed46a78389e&target=%2Fpeople%2F63ffa4d3-d7cc-4939-9bec-9ed46a78389e",
+		"varnish-purger/purge?target=%2Fthings%2F9d4be817-dab9-4292-acf8-32416ebe9e94&target=%2Fconcepts" +
+			"%2F9d4be817-dab9-4292-acf8-32416ebe9e94&target=%2Forganisations%2F9d4be817-dab9-4292-acf8-32416ebe9e94",
 		"concept-rw-elasticsearch/memberships/ddacda04-b7cd-4d2e-86b1-7dfef0ff56a2",
 	}, mockWriter.called)
 	assert.NoError(t, err)
@@ -1104,6 +1240,8 @@ func TestAggregateService_ProcessMessage_Success_PurgeOnMembership(t *testing.T)
 		"varnish-purger/purge?target=%2Fthings%2F3b961db6-02c1-4fde-b96d-aefd339a02a6" +
 			"&target=%2Fconcepts%2F3b961db6-02c1-4fde-b96d-aefd339a02a6" +
 			"&target=%2Fpeople%2F3b961db6-02c1-4fde-b96d-aefd339a02a6",
+		"varnish-purger/purge?target=%2Fthings%2F064ce159-8835-3426-b456-c86d48de8511&target=%2Fconcepts" +
+			"%2F064ce159-8835-3426-b456-c86d48de8511&target=%2Forganisations%2F064ce159-8835-3426-b456-c86d48de8511",
 	}, mockWriter.called)
 	assert.NoError(t, err)
 }
@@ -1179,6 +1317,59 @@ func TestAggregateService_ProcessMessage_S3CanonicalNotFound(t *testing.T) {
 	assert.EqualError(t, err, "canonical concept 45f278ef-91b2-45f7-9545-fbc79c1b4004 not found in S3")
 }
 
+func TestAggregateService_ProcessMessage_InvalidConceptNotWritten(t *testing.T) {
+	svc, s3mock, _, eventQueue, _, _, _ := setupTestService(200, payload)
+	s3mock.concepts["e9a4f0c2-3d5b-4a5e-8b0a-6a7f3f4d2c11"] = struct {
+		transactionID string
+		concept       s3.Concept
+	}{
+		transactionID: "tid_422",
+		concept: s3.Concept{
+			UUID:             "e9a4f0c2-3d5b-4a5e-8b0a-6a7f3f4d2c11",
+			PrefLabel:        "Membership without a person",
+			Authority:        "Smartlogic",
+			AuthValue:        "e9a4f0c2-3d5b-4a5e-8b0a-6a7f3f4d2c11",
+			Type:             "Membership",
+			OrganisationUUID: "a4528fc9-0615-4bfa-bc99-596ea1ddec28",
+			InceptionDate:    "2002-13-01",
+		},
+	}
+
+	err := svc.ProcessMessage(context.Background(), "e9a4f0c2-3d5b-4a5e-8b0a-6a7f3f4d2c11", "")
+	var validationErr *ValidationError
+	assert.True(t, errors.As(err, &validationErr))
+	assert.EqualError(t, err, `concept e9a4f0c2-3d5b-4a5e-8b0a-6a7f3f4d2c11 of type Membership is invalid: inceptionDate "2002-13-01" is not a valid date; personUUID is required`)
+	assert.Empty(t, svc.httpClient.(*mockHTTPClient).called)
+	assert.Empty(t, eventQueue.eventList)
+}
+
+func TestAggregateService_ProcessConceptUpdate_InvalidConceptRemovedFromQueue(t *testing.T) {
+	svc, s3mock, conceptsQueue, _, _, _, _ := setupTestService(200, payload)
+	s3mock.concepts["e9a4f0c2-3d5b-4a5e-8b0a-6a7f3f4d2c11"] = struct {
+		transactionID string
+		concept       s3.Concept
+	}{
+		transactionID: "tid_422",
+		concept: s3.Concept{
+			UUID:      "e9a4f0c2-3d5b-4a5e-8b0a-6a7f3f4d2c11",
+			PrefLabel: "Financial instrument without an issuer",
+			Authority: "Smartlogic",
+			AuthValue: "e9a4f0c2-3d5b-4a5e-8b0a-6a7f3f4d2c11",
+			Type:      "FinancialInstrument",
+		},
+	}
+	receiptHandle := "3"
+	conceptsQueue.conceptsQueue[receiptHandle] = "e9a4f0c2-3d5b-4a5e-8b0a-6a7f3f4d2c11"
+	deadLetters := &mockDeadLetterClient{}
+	WithDeadLetterQueue(deadLetters)(svc)
+
+	err := svc.processConceptUpdate(context.Background(), sqs.ConceptUpdate{UUID: "e9a4f0c2-3d5b-4a5e-8b0a-6a7f3f4d2c11", ReceiptHandle: &receiptHandle})
+	assert.NoError(t, err)
+	assert.NotContains(t, conceptsQueue.Queue(), receiptHandle)
+	assert.Len(t, deadLetters.letters, 1)
+	assert.Empty(t, svc.httpClient.(*mockHTTPClient).called)
+}
+
 func TestAggregateService_ProcessMessage_CancelContext(t *testing.T) {
 	svc, _, _, _, _, _, _ := setupTestService(200, payload)
 	ctx, cancel := context.WithCancel(context.Background())
@@ -1205,29 +1396,51 @@ func TestAggregateService_Healthchecks(t *testing.T) {
 	}
 }
 
-func TestResolveConceptType(t *testing.T) {
-	person := resolveConceptType("Person")
+func TestAggregateService_Healthchecks_OpenCircuitBreaker(t *testing.T) {
+	svc, _, _, _, _, _, _ := setupTestService(503, payload)
+	svc = NewService(svc.s3, svc.conceptUpdatesSqs, svc.eventsSqs, svc.concordances, svc.kinesis, neo4jUrl, esUrl, varnishPurgerUrl, nil, svc.httpClient, nil, nil, time.Second,
+		WithResilience(resilience.Policy{FailureThreshold: 1, Cooldown: time.Minute}))
+
+	err := svc.ProcessMessage(context.Background(), "28090964-9997-4bc2-9638-7a11135aaff9", "")
+	assert.EqualError(t, err, "Request to concepts-rw-neo4j/people/28090964-9997-4bc2-9638-7a11135aaff9 returned status: 503; skipping 28090964-9997-4bc2-9638-7a11135aaff9")
+
+	svc.httpClient.(*mockHTTPClient).statusCode = 200
+	_, err = svc.RWNeo4JHealthCheck().Checker()
+	var openErr *resilience.CircuitOpenError
+	assert.True(t, errors.As(err, &openErr))
+	_, err = svc.RWElasticsearchHealthCheck().Checker()
+	assert.NoError(t, err)
+
+	calls := len(svc.httpClient.(*mockHTTPClient).called)
+	err = svc.ProcessMessage(context.Background(), "28090964-9997-4bc2-9638-7a11135aaff9", "")
+	assert.True(t, errors.As(err, &openErr))
+	assert.Len(t, svc.httpClient.(*mockHTTPClient).called, calls, "writes should fail fast while the breaker is open")
+}
+
+func TestRoutingTable_Path(t *testing.T) {
+	routes := DefaultRoutingTable()
+	person := routes.path("Person")
 	assert.Equal(t, "people", person)
 
-	specialReport := resolveConceptType("SpecialReport")
+	specialReport := routes.path("SpecialReport")
 	assert.Equal(t, "special-reports", specialReport)
 
-	financialInstrument := resolveConceptType("FinancialInstrument")
+	financialInstrument := routes.path("FinancialInstrument")
 	assert.Equal(t, "financial-instruments", financialInstrument)
 
-	alphavilleSeries := resolveConceptType("AlphavilleSeries")
+	alphavilleSeries := routes.path("AlphavilleSeries")
 	assert.Equal(t, "alphaville-series", alphavilleSeries)
 
-	topic := resolveConceptType("Topic")
+	topic := routes.path("Topic")
 	assert.Equal(t, "topics", topic)
 
-	brand := resolveConceptType("Brand")
+	brand := routes.path("Brand")
 	assert.Equal(t, "brands", brand)
 
-	orgs := resolveConceptType("Organisation")
+	orgs := routes.path("Organisation")
 	assert.Equal(t, "organisations", orgs)
 
-	company := resolveConceptType("PublicCompany")
+	company := routes.path("PublicCompany")
 	assert.Equal(t, "organisations", company)
 }
 
@@ -0,0 +1,175 @@
+package concept
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/sqs"
+	logger "github.com/Financial-Times/go-logger"
+)
+
+const (
+	Neo4jSink         = "neo4j"
+	VarnishSink       = "varnish"
+	ElasticsearchSink = "elasticsearch"
+	EventsSink        = "events"
+	KinesisSink       = "kinesis"
+)
+
+// Sink is a destination that concorded concepts are sent to once they have been aggregated and validated.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, w *SinkWrite) error
+}
+
+// SinkPlanner is implemented by sinks that can describe what they would send for w without sending it.
+type SinkPlanner interface {
+	Plan(w *SinkWrite) SinkPlan
+}
+
+// SinkWrite is a concept on its way through the sink pipeline. Sinks record what they changed in it for the
+// sinks after them.
+type SinkWrite struct {
+	Concept       ConcordedConcept
+	TransactionID string
+	// Changes are the concepts and events the Neo4j writer reports as changed.
+	Changes sqs.ConceptChanges
+	// Unchanged is set by a sink that finds the concept was already up to date, and stops the pipeline.
+	Unchanged bool
+	// Completed are the sinks the concept has been sent to, which are skipped when the pipeline is run again.
+	Completed []string
+}
+
+// SinkConfig places a sink in the pipeline.
+type SinkConfig struct {
+	Name string `json:"name"`
+	// Enabled is true unless set otherwise.
+	Enabled *bool `json:"enabled,omitempty"`
+	// BestEffort sinks have their failures logged instead of failing the message.
+	BestEffort bool `json:"bestEffort,omitempty"`
+	// Types limits the sink to concepts of these types, and ExcludeTypes skips concepts of these types.
+	Types        []string `json:"types,omitempty"`
+	ExcludeTypes []string `json:"excludeTypes,omitempty"`
+}
+
+// SinkPipeline lists the sinks concepts are sent to, in order.
+type SinkPipeline struct {
+	Sinks []SinkConfig `json:"sinks"`
+}
+
+// DefaultSinkPipeline writes to Neo4j, purges Varnish on a best effort basis, writes to Elasticsearch and then
+// sends the events and the Kinesis notification.
+func DefaultSinkPipeline() SinkPipeline {
+	return SinkPipeline{
+		Sinks: []SinkConfig{
+			{Name: Neo4jSink},
+			{Name: VarnishSink, BestEffort: true},
+			{Name: ElasticsearchSink},
+			{Name: EventsSink},
+			{Name: KinesisSink},
+		},
+	}
+}
+
+// LoadSinkPipeline reads the sink pipeline from the JSON file at path, replacing the default pipeline.
+// An empty path returns DefaultSinkPipeline.
+func LoadSinkPipeline(path string) (SinkPipeline, error) {
+	if path == "" {
+		return DefaultSinkPipeline(), nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return SinkPipeline{}, fmt.Errorf("failed to read sink pipeline: %w", err)
+	}
+	var pipeline SinkPipeline
+	if err = json.Unmarshal(data, &pipeline); err != nil {
+		return SinkPipeline{}, fmt.Errorf("failed to parse sink pipeline: %w", err)
+	}
+	return pipeline, pipeline.Validate()
+}
+
+// Validate checks that every sink is named and appears only once.
+func (p SinkPipeline) Validate() error {
+	seen := map[string]bool{}
+	for i, cfg := range p.Sinks {
+		if cfg.Name == "" {
+			return fmt.Errorf("sink pipeline: sink %d has no name", i)
+		}
+		if seen[cfg.Name] {
+			return fmt.Errorf("sink pipeline: %q is listed more than once", cfg.Name)
+		}
+		seen[cfg.Name] = true
+	}
+	return nil
+}
+
+// run sends w to each enabled sink that applies to its type and has not completed, in order, until one reports
+// the concept unchanged. The first failure of a sink that is not best effort stops the pipeline and is returned.
+func (p SinkPipeline) run(ctx context.Context, sinks map[string]Sink, w *SinkWrite) error {
+	for _, cfg := range p.Sinks {
+		if !cfg.enabled() || !cfg.appliesTo(w.Concept.Type) || contains(cfg.Name, w.Completed) {
+			continue
+		}
+		sink, ok := sinks[cfg.Name]
+		if !ok {
+			return fmt.Errorf("sink pipeline: unknown sink %q", cfg.Name)
+		}
+		if err := sink.Send(ctx, w); err != nil {
+			if !cfg.BestEffort {
+				return err
+			}
+			logger.WithError(err).WithTransactionID(w.TransactionID).WithUUID(w.Concept.PrefUUID).
+				WithField("sink", cfg.Name).Error("Best effort sink failed")
+		}
+		w.Completed = append(w.Completed, cfg.Name)
+		if w.Unchanged {
+			return nil
+		}
+	}
+	return nil
+}
+
+// plan describes what run would do with w, without calling any sink. Neo4j cannot be asked what it would change,
+// so the plan assumes the concept is changed.
+func (p SinkPipeline) plan(sinks map[string]Sink, w *SinkWrite) ([]SinkPlan, error) {
+	var plans []SinkPlan
+	for _, cfg := range p.Sinks {
+		plan := SinkPlan{Sink: cfg.Name, BestEffort: cfg.BestEffort}
+		switch {
+		case !cfg.enabled():
+			plan.Skipped = "sink is disabled"
+		case !cfg.appliesTo(w.Concept.Type):
+			plan.Skipped = fmt.Sprintf("sink does not take concepts of type %s", w.Concept.Type)
+		case contains(cfg.Name, w.Completed):
+			plan.Skipped = "sink completed before an earlier attempt failed"
+		default:
+			sink, ok := sinks[cfg.Name]
+			if !ok {
+				return nil, fmt.Errorf("sink pipeline: unknown sink %q", cfg.Name)
+			}
+			planner, ok := sink.(SinkPlanner)
+			if !ok {
+				plan.Note = "sink cannot describe what it would send"
+				break
+			}
+			plan = planner.Plan(w)
+			plan.Sink = cfg.Name
+			plan.BestEffort = cfg.BestEffort
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+func (c SinkConfig) enabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+func (c SinkConfig) appliesTo(conceptType string) bool {
+	if len(c.Types) > 0 && !contains(conceptType, c.Types) {
+		return false
+	}
+	return !contains(conceptType, c.ExcludeTypes)
+}
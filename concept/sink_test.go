@@ -0,0 +1,239 @@
+package concept
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/s3"
+	"github.com/Financial-Times/aggregate-concept-transformer/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingSink struct {
+	name      string
+	err       error
+	unchanged bool
+	sent      *[]string
+}
+
+func (r *recordingSink) Name() string {
+	return r.name
+}
+
+func (r *recordingSink) Send(ctx context.Context, w *SinkWrite) error {
+	*r.sent = append(*r.sent, r.name)
+	w.Unchanged = r.unchanged
+	return r.err
+}
+
+func TestSinkPipeline_Run(t *testing.T) {
+	disabled := false
+	testCases := map[string]struct {
+		pipeline    []SinkConfig
+		conceptType string
+		errs        map[string]error
+		unchanged   map[string]bool
+		expected    []string
+		expectedErr string
+	}{
+		"Sinks run in order": {
+			pipeline: []SinkConfig{{Name: "c"}, {Name: "a"}, {Name: "b"}},
+			expected: []string{"c", "a", "b"},
+		},
+		"Disabled sink skipped": {
+			pipeline: []SinkConfig{{Name: "a"}, {Name: "b", Enabled: &disabled}, {Name: "c"}},
+			expected: []string{"a", "c"},
+		},
+		"Sink limited to other types skipped": {
+			pipeline:    []SinkConfig{{Name: "a", Types: []string{"Person"}}, {Name: "b", Types: []string{"Topic", "Brand"}}},
+			conceptType: "Topic",
+			expected:    []string{"b"},
+		},
+		"Excluded type skipped": {
+			pipeline:    []SinkConfig{{Name: "a", ExcludeTypes: []string{"Topic"}}, {Name: "b", ExcludeTypes: []string{"Person"}}},
+			conceptType: "Topic",
+			expected:    []string{"b"},
+		},
+		"Best effort failure carries on": {
+			pipeline: []SinkConfig{{Name: "a", BestEffort: true}, {Name: "b"}},
+			errs:     map[string]error{"a": errors.New("purge failed")},
+			expected: []string{"a", "b"},
+		},
+		"Failure stops the pipeline": {
+			pipeline:    []SinkConfig{{Name: "a"}, {Name: "b"}, {Name: "c"}},
+			errs:        map[string]error{"b": errors.New("write failed")},
+			expected:    []string{"a", "b"},
+			expectedErr: "write failed",
+		},
+		"Unchanged stops the pipeline": {
+			pipeline:  []SinkConfig{{Name: "a"}, {Name: "b"}},
+			unchanged: map[string]bool{"a": true},
+			expected:  []string{"a"},
+		},
+		"Unknown sink": {
+			pipeline:    []SinkConfig{{Name: "a"}, {Name: "missing"}},
+			expected:    []string{"a"},
+			expectedErr: `sink pipeline: unknown sink "missing"`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var sent []string
+			sinks := map[string]Sink{}
+			for _, sinkName := range []string{"a", "b", "c"} {
+				sinks[sinkName] = &recordingSink{name: sinkName, err: tc.errs[sinkName], unchanged: tc.unchanged[sinkName], sent: &sent}
+			}
+			conceptType := tc.conceptType
+			if conceptType == "" {
+				conceptType = "Person"
+			}
+
+			w := &SinkWrite{Concept: ConcordedConcept{PrefUUID: "uuid", Type: conceptType}, TransactionID: "tid_test"}
+			err := SinkPipeline{Sinks: tc.pipeline}.run(context.Background(), sinks, w)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, sent)
+		})
+	}
+}
+
+func TestLoadSinkPipeline(t *testing.T) {
+	pipeline, err := LoadSinkPipeline("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultSinkPipeline(), pipeline)
+
+	dir, err := ioutil.TempDir("", "sink-pipeline")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "pipeline.json")
+	err = ioutil.WriteFile(path, []byte(`{"sinks": [{"name": "neo4j"}, {"name": "elasticsearch", "enabled": false, "excludeTypes": ["Membership"]}]}`), 0600)
+	assert.NoError(t, err)
+	pipeline, err = LoadSinkPipeline(path)
+	assert.NoError(t, err)
+	assert.Len(t, pipeline.Sinks, 2)
+	assert.True(t, pipeline.Sinks[0].enabled())
+	assert.False(t, pipeline.Sinks[1].enabled())
+	assert.Equal(t, []string{"Membership"}, pipeline.Sinks[1].ExcludeTypes)
+
+	err = ioutil.WriteFile(path, []byte(`{"sinks": [{"name": "neo4j"}, {"name": "neo4j"}]}`), 0600)
+	assert.NoError(t, err)
+	_, err = LoadSinkPipeline(path)
+	assert.EqualError(t, err, `sink pipeline: "neo4j" is listed more than once`)
+
+	err = ioutil.WriteFile(path, []byte(`{"sinks": [{"bestEffort": true}]}`), 0600)
+	assert.NoError(t, err)
+	_, err = LoadSinkPipeline(path)
+	assert.EqualError(t, err, "sink pipeline: sink 0 has no name")
+}
+
+func TestNeo4jWriterSink(t *testing.T) {
+	client := &mockHTTPClient{resp: payload, statusCode: 200}
+	sink := &neo4jWriterSink{client: client, address: "concepts-rw-neo4j", routes: DefaultRoutingTable()}
+	w := &SinkWrite{Concept: ConcordedConcept{PrefUUID: "28090964-9997-4bc2-9638-7a11135aaff9", Type: "Person"}, TransactionID: "tid_test"}
+
+	err := sink.Send(context.Background(), w)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"concepts-rw-neo4j/people/28090964-9997-4bc2-9638-7a11135aaff9"}, client.called)
+	assert.Len(t, w.Changes.ChangedRecords, 3)
+	assert.False(t, w.Unchanged)
+
+	client.resp = `{"events": [], "updatedIDs": []}`
+	w = &SinkWrite{Concept: w.Concept, TransactionID: "tid_test"}
+	err = sink.Send(context.Background(), w)
+	assert.NoError(t, err)
+	assert.True(t, w.Unchanged)
+}
+
+func TestVarnishPurgerSink_Membership(t *testing.T) {
+	client := &mockHTTPClient{statusCode: 200}
+	sink := &varnishPurgerSink{client: client, address: "varnish-purger", typesToPurgeFromPublicEndpoints: []string{"Person"}, routes: DefaultRoutingTable(), cascade: DefaultPurgeCascade()}
+	w := &SinkWrite{
+		Concept:       ConcordedConcept{PrefUUID: "membership", Type: "Membership", PersonUUID: "person"},
+		TransactionID: "tid_test",
+		Changes:       sqs.ConceptChanges{UpdatedIds: []string{"membership"}},
+	}
+
+	err := sink.Send(context.Background(), w)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"varnish-purger/purge?target=%2Fthings%2Fmembership&target=%2Fconcepts%2Fmembership",
+		"varnish-purger/purge?target=%2Fthings%2Fperson&target=%2Fconcepts%2Fperson&target=%2Fpeople%2Fperson",
+	}, client.called)
+
+	client.statusCode = 503
+	err = sink.Send(context.Background(), w)
+	assert.EqualError(t, err, "concepts couldn't be purged from Varnish cache: membership: request was not successful, status code: 503; person: request was not successful, status code: 503")
+}
+
+func TestElasticsearchWriterSink_SkipsTypesNotInElasticsearch(t *testing.T) {
+	client := &mockHTTPClient{statusCode: 200}
+	sink := &elasticsearchWriterSink{client: client, address: "concept-rw-elasticsearch", routes: DefaultRoutingTable()}
+
+	err := sink.Send(context.Background(), &SinkWrite{Concept: ConcordedConcept{PrefUUID: "role", Type: "MembershipRole"}})
+	assert.NoError(t, err)
+	assert.Empty(t, client.called)
+
+	err = sink.Send(context.Background(), &SinkWrite{Concept: ConcordedConcept{
+		PrefUUID:              "membership",
+		Type:                  "Membership",
+		SourceRepresentations: []s3.Concept{{UUID: "membership", Authority: "Smartlogic"}},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"concept-rw-elasticsearch/memberships/membership"}, client.called)
+}
+
+func TestEventsSink_TransactionID(t *testing.T) {
+	client := &mockSQSClient{}
+	sink := &eventsSink{client: client}
+	w := &SinkWrite{
+		Concept:       ConcordedConcept{PrefUUID: "uuid"},
+		TransactionID: "tid_update",
+		Changes: sqs.ConceptChanges{ChangedRecords: []sqs.Event{
+			{ConceptUUID: "uuid"},
+			{ConceptUUID: "other", TransactionID: "tid_neo4j"},
+		}},
+	}
+
+	err := sink.Send(context.Background(), w)
+	assert.NoError(t, err)
+	if assert.Len(t, client.eventList, 2) {
+		assert.Equal(t, "tid_update", client.eventList[0].TransactionID)
+		assert.Equal(t, "tid_neo4j", client.eventList[1].TransactionID, "the transaction ID reported by Neo4j should be kept")
+	}
+	assert.Empty(t, w.Changes.ChangedRecords[0].TransactionID, "the changes kept for a retry should not be altered")
+}
+
+func TestEventsSink_NoEvents(t *testing.T) {
+	client := &mockSQSClient{err: errors.New("no entries in batch")}
+	sink := &eventsSink{client: client}
+
+	err := sink.Send(context.Background(), &SinkWrite{Concept: ConcordedConcept{PrefUUID: "uuid"}})
+	assert.NoError(t, err)
+}
+
+func TestAggregateService_ProcessMessage_CustomSinkPipeline(t *testing.T) {
+	svc, _, _, eventsQueue, _, _, _ := setupTestService(200, payload)
+	var sent []string
+	WithSink(&recordingSink{name: "audit", sent: &sent})(svc)
+	WithSinkPipeline(SinkPipeline{Sinks: []SinkConfig{
+		{Name: Neo4jSink},
+		{Name: ElasticsearchSink, ExcludeTypes: []string{"Person"}},
+		{Name: "audit"},
+		{Name: EventsSink},
+	}})(svc)
+
+	err := svc.ProcessMessage(context.Background(), "28090964-9997-4bc2-9638-7a11135aaff9", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"concepts-rw-neo4j/people/28090964-9997-4bc2-9638-7a11135aaff9"}, svc.httpClient.(*mockHTTPClient).called)
+	assert.Equal(t, []string{"audit"}, sent)
+	assert.Len(t, eventsQueue.eventList, 3)
+}
@@ -0,0 +1,254 @@
+package concept
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/kinesis"
+	"github.com/Financial-Times/aggregate-concept-transformer/sqs"
+	logger "github.com/Financial-Times/go-logger"
+)
+
+// neo4jWriterSink writes concepts to Neo4j and records the concepts and events the writer reports as changed.
+// The concept is marked unchanged when the writer reports no changes. Concepts the routing table keeps out of Neo4j
+// are passed on to the sinks after it.
+type neo4jWriterSink struct {
+	client  httpClient
+	address string
+	routes  RoutingTable
+}
+
+func (n *neo4jWriterSink) Name() string {
+	return Neo4jSink
+}
+
+func (n *neo4jWriterSink) Send(ctx context.Context, w *SinkWrite) error {
+	c := w.Concept
+	if !n.routes.writesTo(Neo4jSink, c) {
+		return nil
+	}
+	logger.WithTransactionID(w.TransactionID).WithUUID(c.PrefUUID).Debug("Sending concept to Neo4j")
+	changes, err := sendToWriter(ctx, n.client, n.address, n.routes.path(c.Type), c.PrefUUID, c, w.TransactionID)
+	if err != nil {
+		return err
+	}
+	w.Changes = changes
+	if len(changes.ChangedRecords) < 1 {
+		logger.WithTransactionID(w.TransactionID).WithUUID(c.PrefUUID).Info("concept was unchanged since last update, skipping!")
+		w.Unchanged = true
+		return nil
+	}
+	logger.WithTransactionID(w.TransactionID).WithUUID(c.PrefUUID).Debug("concept successfully updated in neo4j")
+	return nil
+}
+
+func (n *neo4jWriterSink) Plan(w *SinkWrite) SinkPlan {
+	c := w.Concept
+	if !n.routes.writesTo(Neo4jSink, c) {
+		return SinkPlan{Skipped: fmt.Sprintf("concepts of type %s are not written to Neo4j", c.Type)}
+	}
+	return SinkPlan{
+		Requests: []PlannedRequest{{Method: "PUT", URL: writeURL(n.address, n.routes.path(c.Type), c.PrefUUID), Body: &c}},
+		Note:     "the sinks after it are skipped if Neo4j reports no changes",
+	}
+}
+
+// varnishPurgerSink purges the URLs of the updated concepts from Varnish, along with those of the related concepts
+// the cascade declares for their type. With a coalescer the URLs are purged together with those of other concepts.
+type varnishPurgerSink struct {
+	client                          httpClient
+	address                         string
+	typesToPurgeFromPublicEndpoints []string
+	routes                          RoutingTable
+	cascade                         PurgeCascade
+	coalescer                       *purgeCoalescer
+}
+
+// purgeGroup is a set of concepts of the same type that are purged together.
+type purgeGroup struct {
+	label       string
+	uuids       []string
+	conceptType string
+}
+
+func (v *varnishPurgerSink) Name() string {
+	return VarnishSink
+}
+
+func (v *varnishPurgerSink) Send(ctx context.Context, w *SinkWrite) error {
+	if v.coalescer != nil {
+		return v.sendCoalesced(ctx, w)
+	}
+	var failed []string
+	for _, group := range v.purgeGroups(w) {
+		if err := sendToPurger(ctx, v.client, v.address, group.uuids, group.conceptType, v.routes, v.typesToPurgeFromPublicEndpoints, w.TransactionID); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", group.label, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("concepts couldn't be purged from Varnish cache: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (v *varnishPurgerSink) sendCoalesced(ctx context.Context, w *SinkWrite) error {
+	var targets []string
+	for _, group := range v.purgeGroups(w) {
+		targets = append(targets, purgeTargets(group.uuids, group.conceptType, v.routes, v.typesToPurgeFromPublicEndpoints)...)
+	}
+	failures, err := v.coalescer.purge(ctx, targets)
+	if err != nil {
+		return err
+	}
+	if len(failures) == 0 {
+		logger.WithTransactionID(w.TransactionID).WithUUID(w.Concept.PrefUUID).Debugf("Targets %s successfully purged from varnish cache", targets)
+		return nil
+	}
+
+	var failed []string
+	for _, target := range targets {
+		if err, ok := failures[target]; ok {
+			failed = append(failed, fmt.Sprintf("%s: %v", target, err))
+		}
+	}
+	return fmt.Errorf("targets couldn't be purged from Varnish cache: %s", strings.Join(failed, "; "))
+}
+
+func (v *varnishPurgerSink) Plan(w *SinkWrite) SinkPlan {
+	var plan SinkPlan
+	for _, group := range v.purgeGroups(w) {
+		targets := purgeTargets(group.uuids, group.conceptType, v.routes, v.typesToPurgeFromPublicEndpoints)
+		plan.Requests = append(plan.Requests, PlannedRequest{Method: "POST", URL: purgeURL(v.address, targets), PurgeTargets: targets})
+	}
+	if v.coalescer != nil {
+		plan.Note = fmt.Sprintf("targets are purged together with those of other concepts within %v, in requests of up to %d targets", v.coalescer.window, v.coalescer.maxTargets)
+	}
+	return plan
+}
+
+// purgeGroups returns the concepts purged for w: the updated concepts, and the related concepts of the cascade.
+func (v *varnishPurgerSink) purgeGroups(w *SinkWrite) []purgeGroup {
+	c := w.Concept
+	groups := []purgeGroup{{label: c.PrefUUID, uuids: updatedIDs(w), conceptType: c.Type}}
+	return append(groups, v.cascade.related(c)...)
+}
+
+// elasticsearchWriterSink writes the concepts the routing table sends to Elasticsearch.
+type elasticsearchWriterSink struct {
+	client  httpClient
+	address string
+	routes  RoutingTable
+}
+
+func (e *elasticsearchWriterSink) Name() string {
+	return ElasticsearchSink
+}
+
+func (e *elasticsearchWriterSink) Send(ctx context.Context, w *SinkWrite) error {
+	c := w.Concept
+	if !e.routes.writesTo(ElasticsearchSink, c) {
+		return nil
+	}
+	logger.WithTransactionID(w.TransactionID).WithUUID(c.PrefUUID).Debug("Writing concept to elastic search")
+	_, err := sendToWriter(ctx, e.client, e.address, e.routes.path(c.Type), c.PrefUUID, c, w.TransactionID)
+	return err
+}
+
+func (e *elasticsearchWriterSink) Plan(w *SinkWrite) SinkPlan {
+	c := w.Concept
+	if !e.routes.writesTo(ElasticsearchSink, c) {
+		return SinkPlan{Skipped: fmt.Sprintf("concepts of type %s are not written to Elasticsearch", c.Type)}
+	}
+	return SinkPlan{
+		Requests: []PlannedRequest{{Method: "PUT", URL: writeURL(e.address, e.routes.path(c.Type), c.PrefUUID), Body: &c}},
+	}
+}
+
+// eventsSink sends the change events reported by Neo4j to the events queue.
+type eventsSink struct {
+	client sqs.Client
+}
+
+func (e *eventsSink) Name() string {
+	return EventsSink
+}
+
+// Send sends the events with the transaction ID of the concept update, unless Neo4j reported one.
+func (e *eventsSink) Send(ctx context.Context, w *SinkWrite) error {
+	if len(w.Changes.ChangedRecords) == 0 {
+		return nil
+	}
+	events := make([]sqs.Event, len(w.Changes.ChangedRecords))
+	for i, event := range w.Changes.ChangedRecords {
+		if event.TransactionID == "" {
+			event.TransactionID = w.TransactionID
+		}
+		events[i] = event
+	}
+	if err := e.client.SendEvents(ctx, events); err != nil {
+		logger.WithTransactionID(w.TransactionID).WithUUID(w.Concept.PrefUUID).Errorf("unable to send events: %v to Event Queue", w.Changes.ChangedRecords)
+		return err
+	}
+	return nil
+}
+
+// Plan shows an event for the concept itself. The events actually sent are those Neo4j reports, which also
+// include concordance events and carry the aggregate hash Neo4j computed.
+func (e *eventsSink) Plan(w *SinkWrite) SinkPlan {
+	if len(w.Changes.ChangedRecords) > 0 {
+		return SinkPlan{Payload: w.Changes.ChangedRecords}
+	}
+	c := w.Concept
+	return SinkPlan{
+		Payload: []sqs.Event{{
+			ConceptType:   c.Type,
+			ConceptUUID:   c.PrefUUID,
+			TransactionID: w.TransactionID,
+			EventDetails:  sqs.ConceptEvent{Type: "Concept Updated"},
+		}},
+		Note: "the events sent are those Neo4j reports as changed",
+	}
+}
+
+// kinesisSink notifies the concepts stream of the updated concepts.
+type kinesisSink struct {
+	client kinesis.Client
+}
+
+func (k *kinesisSink) Name() string {
+	return KinesisSink
+}
+
+func (k *kinesisSink) Send(ctx context.Context, w *SinkWrite) error {
+	c := w.Concept
+	rawIDList, err := json.Marshal(updatedIDs(w))
+	if err != nil {
+		logger.WithError(err).WithTransactionID(w.TransactionID).WithUUID(c.PrefUUID).Errorf("failed to marshall concept changes record: %v", w.Changes.UpdatedIds)
+		return err
+	}
+	logger.WithTransactionID(w.TransactionID).WithUUID(c.PrefUUID).Debugf("sending notification of updated concepts to kinesis conceptsQueue: %v", w.Changes)
+	if err = k.client.AddRecordToStream(ctx, rawIDList, c.Type, c.PrefUUID); err != nil {
+		logger.WithError(err).WithTransactionID(w.TransactionID).WithUUID(c.PrefUUID).Errorf("Failed to update stream with notification record %v", w.Changes)
+		return err
+	}
+	return nil
+}
+
+func (k *kinesisSink) Plan(w *SinkWrite) SinkPlan {
+	return SinkPlan{
+		Payload: updatedIDs(w),
+		Note:    fmt.Sprintf("sent to the stream with partition key %s", k.client.PartitionKey(w.Concept.Type, w.Concept.PrefUUID)),
+	}
+}
+
+// updatedIDs returns the concepts Neo4j reported as updated, or just the concept itself when Neo4j is not
+// in the pipeline.
+func updatedIDs(w *SinkWrite) []string {
+	if len(w.Changes.UpdatedIds) > 0 {
+		return w.Changes.UpdatedIds
+	}
+	return []string{w.Concept.PrefUUID}
+}
@@ -0,0 +1,179 @@
+package concept
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/s3"
+)
+
+const thingType = "Thing"
+
+// TypeHierarchy declares the parent of each concept type. Thing is the root of the hierarchy, and types that
+// are not declared are treated as direct children of Thing. Incompatible lists pairs of types that cannot be
+// merged; each pair also covers the descendants of its types.
+type TypeHierarchy struct {
+	Parents      map[string]string `json:"parents"`
+	Incompatible [][]string        `json:"incompatible"`
+}
+
+// DefaultTypeHierarchy returns the hierarchy of the concept types known to UPP.
+func DefaultTypeHierarchy() TypeHierarchy {
+	return TypeHierarchy{
+		Parents: map[string]string{
+			"Concept":                     thingType,
+			"Classification":              "Concept",
+			"AlphavilleSeries":            "Classification",
+			"Brand":                       "Classification",
+			"Genre":                       "Classification",
+			"Section":                     "Classification",
+			"SpecialReport":               "Classification",
+			"Subject":                     "Classification",
+			"Topic":                       "Concept",
+			"Location":                    "Concept",
+			"Person":                      "Concept",
+			"Organisation":                "Concept",
+			"Company":                     "Organisation",
+			"PublicCompany":               "Company",
+			"PrivateCompany":              "Company",
+			"Membership":                  "Concept",
+			"MembershipRole":              "Concept",
+			"BoardRole":                   "MembershipRole",
+			"FinancialInstrument":         "Concept",
+			"IndustryClassification":      "Concept",
+			"NAICSIndustryClassification": "IndustryClassification",
+		},
+		Incompatible: [][]string{
+			{"Person", "Organisation"},
+		},
+	}
+}
+
+// LoadTypeHierarchy reads the JSON file at path and adds its parents and incompatible pairs to the default
+// hierarchy, replacing the parent of any type it redeclares. An empty path returns DefaultTypeHierarchy.
+func LoadTypeHierarchy(path string) (TypeHierarchy, error) {
+	hierarchy := DefaultTypeHierarchy()
+	if path == "" {
+		return hierarchy, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return TypeHierarchy{}, fmt.Errorf("failed to read type hierarchy: %w", err)
+	}
+	var override TypeHierarchy
+	if err = json.Unmarshal(data, &override); err != nil {
+		return TypeHierarchy{}, fmt.Errorf("failed to parse type hierarchy: %w", err)
+	}
+	for conceptType, parent := range override.Parents {
+		hierarchy.Parents[conceptType] = parent
+	}
+	hierarchy.Incompatible = append(hierarchy.Incompatible, override.Incompatible...)
+	return hierarchy, hierarchy.Validate()
+}
+
+// Validate checks that Thing has no parent, that every parent is Thing or a declared type, that there are no
+// cycles and that every incompatible pair is two declared types on different branches.
+func (h TypeHierarchy) Validate() error {
+	if _, ok := h.Parents[thingType]; ok {
+		return fmt.Errorf("type hierarchy: %s cannot have a parent", thingType)
+	}
+	for conceptType, parent := range h.Parents {
+		if _, ok := h.Parents[parent]; !ok && parent != thingType {
+			return fmt.Errorf("type hierarchy: parent %q of %q is not declared", parent, conceptType)
+		}
+	}
+	for conceptType := range h.Parents {
+		seen := map[string]bool{}
+		for t := conceptType; t != thingType; t = h.Parents[t] {
+			if seen[t] {
+				return fmt.Errorf("type hierarchy: %q is its own ancestor", t)
+			}
+			seen[t] = true
+		}
+	}
+	for _, pair := range h.Incompatible {
+		if len(pair) != 2 {
+			return fmt.Errorf("type hierarchy: incompatible pair %q does not have two types", pair)
+		}
+		for _, conceptType := range pair {
+			if _, ok := h.Parents[conceptType]; !ok {
+				return fmt.Errorf("type hierarchy: incompatible type %q is not declared", conceptType)
+			}
+		}
+		if _, ok := h.moreSpecific(pair[0], pair[1]); ok {
+			return fmt.Errorf("type hierarchy: %q and %q are on the same branch and cannot be incompatible", pair[0], pair[1])
+		}
+	}
+	return nil
+}
+
+// isA reports whether conceptType is ancestor or one of its descendants.
+func (h TypeHierarchy) isA(conceptType string, ancestor string) bool {
+	for t := conceptType; ; t = h.parent(t) {
+		if t == ancestor {
+			return true
+		}
+		if t == thingType {
+			return false
+		}
+	}
+}
+
+func (h TypeHierarchy) parent(conceptType string) string {
+	if parent, ok := h.Parents[conceptType]; ok {
+		return parent
+	}
+	return thingType
+}
+
+// moreSpecific returns the more specific of two types when one is the other or one of its descendants.
+// An empty type is less specific than any other, Thing included. ok is false when the types are on different
+// branches of the hierarchy. Such types are only rejected when they are incompatible.
+func (h TypeHierarchy) moreSpecific(a string, b string) (t string, ok bool) {
+	switch {
+	case a == "":
+		return b, true
+	case b == "" || h.isA(a, b):
+		return a, true
+	case h.isA(b, a):
+		return b, true
+	}
+	return "", false
+}
+
+// incompatible reports whether a and b, or types they descend from, are declared as an incompatible pair.
+func (h TypeHierarchy) incompatible(a string, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	for _, pair := range h.Incompatible {
+		if (h.isA(a, pair[0]) && h.isA(b, pair[1])) || (h.isA(a, pair[1]) && h.isA(b, pair[0])) {
+			return true
+		}
+	}
+	return false
+}
+
+// conflict returns a conflict on the type when sources declare types on different branches of the hierarchy
+// that are not incompatible. The type of the later source is kept for those, as it was before the hierarchy.
+func (h TypeHierarchy) conflict(sources []s3.Concept) (Conflict, bool) {
+	var merged string
+	var conflicting bool
+	var values []ConflictValue
+	for _, src := range sources {
+		if t, ok := h.moreSpecific(merged, src.Type); ok {
+			merged = t
+		} else {
+			conflicting = true
+			merged = src.Type
+		}
+		if src.Type != "" && src.Type != thingType {
+			values = append(values, ConflictValue{Authority: src.Authority, SourceUUID: src.UUID, Value: src.Type})
+		}
+	}
+	if !conflicting {
+		return Conflict{}, false
+	}
+	return Conflict{Field: "type", Values: values}, true
+}
@@ -0,0 +1,194 @@
+package concept
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Financial-Times/aggregate-concept-transformer/concordances"
+	"github.com/Financial-Times/aggregate-concept-transformer/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypeHierarchy_MoreSpecific(t *testing.T) {
+	testCases := map[string]struct {
+		a, b     string
+		expected string
+		ok       bool
+	}{
+		"Same type":                        {a: "Person", b: "Person", expected: "Person", ok: true},
+		"Empty type":                       {a: "", b: "Brand", expected: "Brand", ok: true},
+		"Empty type before Thing":          {a: "", b: "Thing", expected: "Thing", ok: true},
+		"Empty type after Thing":           {a: "Thing", b: "", expected: "Thing", ok: true},
+		"Thing never wins":                 {a: "PublicCompany", b: "Thing", expected: "PublicCompany", ok: true},
+		"Descendant wins when first":       {a: "PrivateCompany", b: "Organisation", expected: "PrivateCompany", ok: true},
+		"Descendant wins when second":      {a: "IndustryClassification", b: "NAICSIndustryClassification", expected: "NAICSIndustryClassification", ok: true},
+		"Undeclared types are below Thing": {a: "Thing", b: "Widget", expected: "Widget", ok: true},
+		"Different branches":               {a: "Person", b: "Organisation"},
+		"Siblings":                         {a: "PublicCompany", b: "PrivateCompany"},
+		"Undeclared against declared":      {a: "Widget", b: "Concept"},
+	}
+
+	h := DefaultTypeHierarchy()
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			actual, ok := h.moreSpecific(tc.a, tc.b)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestTypeHierarchy_Incompatible(t *testing.T) {
+	h := DefaultTypeHierarchy()
+
+	assert.True(t, h.incompatible("Person", "Organisation"))
+	assert.True(t, h.incompatible("PublicCompany", "Person"), "descendants of incompatible types should be incompatible")
+	assert.False(t, h.incompatible("PublicCompany", "PrivateCompany"))
+	assert.False(t, h.incompatible("Widget", "Person"))
+	assert.False(t, h.incompatible("", "Person"))
+}
+
+func TestAggregateService_MergeCanonicalInformation_AllThings(t *testing.T) {
+	svc, _, _, _, _, _, _ := setupTestService(200, payload)
+
+	c, suppliers, err := svc.mergeCanonicalInformation([]s3.Concept{
+		{UUID: "a", Authority: "TME", Type: "Thing"},
+		{UUID: "b", Authority: "FACTSET", Type: "Thing"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Thing", c.Type, "sources that are all Thing should give a Thing")
+	assert.Equal(t, []int{0}, suppliers["type"])
+	assert.Equal(t, "things", svc.routing.path(c.Type))
+}
+
+func TestLoadTypeHierarchy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "type-hierarchy")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	h, err := LoadTypeHierarchy("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultTypeHierarchy(), h)
+	assert.NoError(t, h.Validate())
+
+	path := filepath.Join(dir, "hierarchy.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"parents": {"MutualCompany": "Company"}}`), 0600))
+	h, err = LoadTypeHierarchy(path)
+	assert.NoError(t, err)
+	actual, ok := h.moreSpecific("Organisation", "MutualCompany")
+	assert.True(t, ok)
+	assert.Equal(t, "MutualCompany", actual)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"incompatible": [["Location", "Organisation"]]}`), 0600))
+	h, err = LoadTypeHierarchy(path)
+	assert.NoError(t, err)
+	assert.True(t, h.incompatible("Location", "PublicCompany"))
+	assert.True(t, h.incompatible("Person", "Organisation"), "the default pairs should be kept")
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"incompatible": [["Company", "Organisation"]]}`), 0600))
+	_, err = LoadTypeHierarchy(path)
+	assert.EqualError(t, err, `type hierarchy: "Company" and "Organisation" are on the same branch and cannot be incompatible`)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"incompatible": [["Widget", "Person"]]}`), 0600))
+	_, err = LoadTypeHierarchy(path)
+	assert.EqualError(t, err, `type hierarchy: incompatible type "Widget" is not declared`)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"parents": {"MutualCompany": "Cooperative"}}`), 0600))
+	_, err = LoadTypeHierarchy(path)
+	assert.EqualError(t, err, `type hierarchy: parent "Cooperative" of "MutualCompany" is not declared`)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"parents": {"Concept": "PublicCompany"}}`), 0600))
+	_, err = LoadTypeHierarchy(path)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "is its own ancestor")
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"parents": {"Thing": "Concept"}}`), 0600))
+	_, err = LoadTypeHierarchy(path)
+	assert.EqualError(t, err, `type hierarchy: Thing cannot have a parent`)
+}
+
+func TestAggregateService_GetConcordedConcept_IncompatibleTypes(t *testing.T) {
+	svc, s3mock, _, _, _, _, _ := setupTestService(200, payload)
+	svc.concordances.(*mockConcordancesClient).concordances["0d4fa2a6-6b1e-4d3a-9c5e-7f2b8a1c3e90"] = []concordances.ConcordanceRecord{
+		{UUID: "0d4fa2a6-6b1e-4d3a-9c5e-7f2b8a1c3e90", Authority: "Smartlogic"},
+		{UUID: "3a6e2b1f-8c4d-3e5f-a7b9-0c1d2e3f4a5b", Authority: "TME"},
+	}
+	s3mock.concepts["0d4fa2a6-6b1e-4d3a-9c5e-7f2b8a1c3e90"] = struct {
+		transactionID string
+		concept       s3.Concept
+	}{
+		transactionID: "tid_901",
+		concept: s3.Concept{
+			UUID:      "0d4fa2a6-6b1e-4d3a-9c5e-7f2b8a1c3e90",
+			PrefLabel: "Jordan",
+			Authority: "Smartlogic",
+			AuthValue: "0d4fa2a6-6b1e-4d3a-9c5e-7f2b8a1c3e90",
+			Type:      "Organisation",
+		},
+	}
+	s3mock.concepts["3a6e2b1f-8c4d-3e5f-a7b9-0c1d2e3f4a5b"] = struct {
+		transactionID string
+		concept       s3.Concept
+	}{
+		transactionID: "tid_902",
+		concept: s3.Concept{
+			UUID:      "3a6e2b1f-8c4d-3e5f-a7b9-0c1d2e3f4a5b",
+			PrefLabel: "Jordan",
+			Authority: "TME",
+			AuthValue: "TnN0ZWluX1BOX1BvbGl0aWNpYW5fMTE0Mg==-UE4=",
+			Type:      "Person",
+		},
+	}
+
+	_, _, err := svc.GetConcordedConcept(context.Background(), "0d4fa2a6-6b1e-4d3a-9c5e-7f2b8a1c3e90", "")
+	var validationErr *ValidationError
+	assert.True(t, errors.As(err, &validationErr))
+	assert.EqualError(t, err, "concept 0d4fa2a6-6b1e-4d3a-9c5e-7f2b8a1c3e90 of type Person is invalid: "+
+		"type Organisation of 0d4fa2a6-6b1e-4d3a-9c5e-7f2b8a1c3e90 (Smartlogic) is incompatible with Person of 3a6e2b1f-8c4d-3e5f-a7b9-0c1d2e3f4a5b (TME)")
+}
+
+func TestAggregateService_Aggregate_SiblingTypes(t *testing.T) {
+	svc, s3mock, _, _, _, _, _ := setupTestService(200, payload)
+	svc.concordances.(*mockConcordancesClient).concordances["0d4fa2a6-6b1e-4d3a-9c5e-7f2b8a1c3e90"] = []concordances.ConcordanceRecord{
+		{UUID: "0d4fa2a6-6b1e-4d3a-9c5e-7f2b8a1c3e90", Authority: "Smartlogic"},
+		{UUID: "3a6e2b1f-8c4d-3e5f-a7b9-0c1d2e3f4a5b", Authority: "FACTSET"},
+	}
+	s3mock.concepts["0d4fa2a6-6b1e-4d3a-9c5e-7f2b8a1c3e90"] = struct {
+		transactionID string
+		concept       s3.Concept
+	}{
+		transactionID: "tid_901",
+		concept: s3.Concept{
+			UUID:      "0d4fa2a6-6b1e-4d3a-9c5e-7f2b8a1c3e90",
+			PrefLabel: "Acme",
+			Authority: "Smartlogic",
+			AuthValue: "0d4fa2a6-6b1e-4d3a-9c5e-7f2b8a1c3e90",
+			Type:      "PrivateCompany",
+		},
+	}
+	s3mock.concepts["3a6e2b1f-8c4d-3e5f-a7b9-0c1d2e3f4a5b"] = struct {
+		transactionID string
+		concept       s3.Concept
+	}{
+		transactionID: "tid_902",
+		concept: s3.Concept{
+			UUID:      "3a6e2b1f-8c4d-3e5f-a7b9-0c1d2e3f4a5b",
+			PrefLabel: "Acme",
+			Authority: "FACTSET",
+			AuthValue: "000D63-E",
+			Type:      "PublicCompany",
+		},
+	}
+
+	result, err := svc.Aggregate(context.Background(), "0d4fa2a6-6b1e-4d3a-9c5e-7f2b8a1c3e90", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "PrivateCompany", result.Concept.Type, "the type of the later source should be kept")
+	assert.Contains(t, result.Conflicts, Conflict{Field: "type", Values: []ConflictValue{
+		{Authority: "FACTSET", SourceUUID: "3a6e2b1f-8c4d-3e5f-a7b9-0c1d2e3f4a5b", Value: "PublicCompany"},
+		{Authority: "Smartlogic", SourceUUID: "0d4fa2a6-6b1e-4d3a-9c5e-7f2b8a1c3e90", Value: "PrivateCompany"},
+	}})
+}
@@ -0,0 +1,187 @@
+package concept
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Severity states whether a violation stops a concept from being written.
+type Severity string
+
+const (
+	SeverityFatal   Severity = "fatal"
+	SeverityWarning Severity = "warning"
+)
+
+// Violation is a single problem found when validating a concorded concept. Field is the JSON name of the offending field.
+type Violation struct {
+	Field    string   `json:"field"`
+	Message  string   `json:"message"`
+	Severity Severity `json:"severity"`
+}
+
+// ValidationError is returned when a concorded concept has fatal violations and must not be sent to the writers.
+type ValidationError struct {
+	UUID       string
+	Type       string
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	var msgs []string
+	for _, v := range e.Violations {
+		if v.Severity == SeverityFatal {
+			msgs = append(msgs, v.Field+" "+v.Message)
+		}
+	}
+	return fmt.Sprintf("concept %s of type %s is invalid: %s", e.UUID, e.Type, strings.Join(msgs, "; "))
+}
+
+type validationRule func(c ConcordedConcept) []Violation
+
+// commonValidationRules apply to every concept, whatever its type.
+var commonValidationRules = []validationRule{
+	required("prefUUID", SeverityFatal, func(c ConcordedConcept) string { return c.PrefUUID }),
+	validDate("inceptionDate", func(c ConcordedConcept) string { return c.InceptionDate }),
+	validDate("terminationDate", func(c ConcordedConcept) string { return c.TerminationDate }),
+}
+
+var organisationValidationRules = []validationRule{
+	validCountryCode("countryCode", func(c ConcordedConcept) string { return c.CountryCode }),
+	validCountryCode("countryOfRisk", func(c ConcordedConcept) string { return c.CountryOfRisk }),
+	validCountryCode("countryOfIncorporation", func(c ConcordedConcept) string { return c.CountryOfIncorporation }),
+	validCountryCode("countryOfOperations", func(c ConcordedConcept) string { return c.CountryOfOperations }),
+}
+
+// typeValidationRules are the additional rules for each concept type.
+var typeValidationRules = map[string][]validationRule{
+	"Membership": {
+		required("personUUID", SeverityFatal, func(c ConcordedConcept) string { return c.PersonUUID }),
+		required("organisationUUID", SeverityFatal, func(c ConcordedConcept) string { return c.OrganisationUUID }),
+		validMembershipRoles,
+	},
+	"FinancialInstrument": {
+		required("issuedBy", SeverityFatal, func(c ConcordedConcept) string { return c.IssuedBy }),
+		required("figiCode", SeverityWarning, func(c ConcordedConcept) string { return c.FigiCode }),
+	},
+	"Organisation":  organisationValidationRules,
+	"Company":       organisationValidationRules,
+	"PublicCompany": organisationValidationRules,
+	"Location": {
+		validCountryCode("iso31661", func(c ConcordedConcept) string { return c.ISO31661 }),
+	},
+}
+
+// validateConcept checks c against the common rules and the rules for its type. It returns the warnings found,
+// or a *ValidationError listing every violation when at least one of them is fatal.
+func validateConcept(c ConcordedConcept) ([]Violation, error) {
+	var violations []Violation
+	for _, rule := range commonValidationRules {
+		violations = append(violations, rule(c)...)
+	}
+	for _, rule := range typeValidationRules[c.Type] {
+		violations = append(violations, rule(c)...)
+	}
+
+	for _, v := range violations {
+		if v.Severity == SeverityFatal {
+			return nil, &ValidationError{UUID: c.PrefUUID, Type: c.Type, Violations: violations}
+		}
+	}
+	return violations, nil
+}
+
+func required(field string, severity Severity, value func(c ConcordedConcept) string) validationRule {
+	return func(c ConcordedConcept) []Violation {
+		if strings.TrimSpace(value(c)) == "" {
+			return []Violation{{Field: field, Message: "is required", Severity: severity}}
+		}
+		return nil
+	}
+}
+
+func validDate(field string, value func(c ConcordedConcept) string) validationRule {
+	return func(c ConcordedConcept) []Violation {
+		if v := value(c); v != "" && !isValidDate(v) {
+			return []Violation{{Field: field, Message: fmt.Sprintf("%q is not a valid date", v), Severity: SeverityFatal}}
+		}
+		return nil
+	}
+}
+
+// validCountryCode warns about codes that are not ISO 3166-1 alpha-2 codes rather than rejecting them, so that
+// concepts with legacy country data are still written.
+func validCountryCode(field string, value func(c ConcordedConcept) string) validationRule {
+	return func(c ConcordedConcept) []Violation {
+		if v := value(c); v != "" && !iso31661Codes[v] {
+			return []Violation{{Field: field, Message: fmt.Sprintf("%q is not an ISO 3166-1 alpha-2 code", v), Severity: SeverityWarning}}
+		}
+		return nil
+	}
+}
+
+func validMembershipRoles(c ConcordedConcept) []Violation {
+	var violations []Violation
+	for i, mr := range c.MembershipRoles {
+		field := fmt.Sprintf("membershipRoles[%d]", i)
+		if mr.RoleUUID == "" {
+			violations = append(violations, Violation{Field: field + ".membershipRoleUUID", Message: "is required", Severity: SeverityFatal})
+		}
+		if mr.InceptionDate != "" && !isValidDate(mr.InceptionDate) {
+			violations = append(violations, Violation{Field: field + ".inceptionDate", Message: fmt.Sprintf("%q is not a valid date", mr.InceptionDate), Severity: SeverityFatal})
+		}
+		if mr.TerminationDate != "" && !isValidDate(mr.TerminationDate) {
+			violations = append(violations, Violation{Field: field + ".terminationDate", Message: fmt.Sprintf("%q is not a valid date", mr.TerminationDate), Severity: SeverityFatal})
+		}
+	}
+	return violations
+}
+
+func isValidDate(v string) bool {
+	_, err := parseDate(v)
+	return err == nil
+}
+
+// parseDate parses a date given either as YYYY-MM-DD or as an RFC 3339 timestamp.
+func parseDate(v string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// iso31661Codes holds the officially assigned ISO 3166-1 alpha-2 codes, plus XK which is commonly used for Kosovo.
+var iso31661Codes = func() map[string]bool {
+	codes := map[string]bool{}
+	for _, code := range strings.Fields(`
+		AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ
+		BA BB BD BE BF BG BH BI BJ BL BM BN BO BQ BR BS BT BV BW BY BZ
+		CA CC CD CF CG CH CI CK CL CM CN CO CR CU CV CW CX CY CZ
+		DE DJ DK DM DO DZ
+		EC EE EG EH ER ES ET
+		FI FJ FK FM FO FR
+		GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY
+		HK HM HN HR HT HU
+		ID IE IL IM IN IO IQ IR IS IT
+		JE JM JO JP
+		KE KG KH KI KM KN KP KR KW KY KZ
+		LA LB LC LI LK LR LS LT LU LV LY
+		MA MC MD ME MF MG MH MK ML MM MN MO MP MQ MR MS MT MU MV MW MX MY MZ
+		NA NC NE NF NG NI NL NO NP NR NU NZ
+		OM
+		PA PE PF PG PH PK PL PM PN PR PS PT PW PY
+		QA
+		RE RO RS RU RW
+		SA SB SC SD SE SG SH SI SJ SK SL SM SN SO SR SS ST SV SX SY SZ
+		TC TD TF TG TH TJ TK TL TM TN TO TR TT TV TW TZ
+		UA UG UM US UY UZ
+		VA VC VE VG VI VN VU
+		WF WS
+		XK
+		YE YT
+		ZA ZM ZW`) {
+		codes[code] = true
+	}
+	return codes
+}()
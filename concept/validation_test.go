@@ -0,0 +1,106 @@
+package concept
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConcept(t *testing.T) {
+	testCases := map[string]struct {
+		concept    ConcordedConcept
+		warnings   []Violation
+		violations []Violation
+	}{
+		"Valid membership": {
+			concept: ConcordedConcept{
+				PrefUUID:         "973509c1-5238-4c83-9a7d-89009e839ff8",
+				Type:             "Membership",
+				PersonUUID:       "28090964-9997-4bc2-9638-7a11135aaff9",
+				OrganisationUUID: "a4528fc9-0615-4bfa-bc99-596ea1ddec28",
+				InceptionDate:    "2002-06-01",
+				TerminationDate:  "2011-11-29T00:00:00Z",
+				MembershipRoles:  []MembershipRole{{RoleUUID: "ccdff192-4d6c-4539-bbe8-7e24e81ed49e", InceptionDate: "2002-06-01"}},
+			},
+		},
+		"Membership without person or role": {
+			concept: ConcordedConcept{
+				PrefUUID:         "973509c1-5238-4c83-9a7d-89009e839ff8",
+				Type:             "Membership",
+				OrganisationUUID: "a4528fc9-0615-4bfa-bc99-596ea1ddec28",
+				MembershipRoles:  []MembershipRole{{InceptionDate: "1st June 2002"}},
+			},
+			violations: []Violation{
+				{Field: "personUUID", Message: "is required", Severity: SeverityFatal},
+				{Field: "membershipRoles[0].membershipRoleUUID", Message: "is required", Severity: SeverityFatal},
+				{Field: "membershipRoles[0].inceptionDate", Message: `"1st June 2002" is not a valid date`, Severity: SeverityFatal},
+			},
+		},
+		"Financial instrument without FIGI code": {
+			concept: ConcordedConcept{
+				PrefUUID: "6562674e-dbfa-4cb0-85b2-41b0948b7cc2",
+				Type:     "FinancialInstrument",
+				IssuedBy: "4e484678-cf47-4168-b844-6adb47f8eb58",
+			},
+			warnings: []Violation{
+				{Field: "figiCode", Message: "is required", Severity: SeverityWarning},
+			},
+		},
+		"Financial instrument without issuer": {
+			concept: ConcordedConcept{
+				PrefUUID: "6562674e-dbfa-4cb0-85b2-41b0948b7cc2",
+				Type:     "FinancialInstrument",
+				FigiCode: "BBG000Y1HJT8",
+			},
+			violations: []Violation{
+				{Field: "issuedBy", Message: "is required", Severity: SeverityFatal},
+			},
+		},
+		"Organisation with invalid country codes": {
+			concept: ConcordedConcept{
+				PrefUUID:               "c28fa0b4-4245-11e8-842f-0ed5f89f718b",
+				Type:                   "PublicCompany",
+				CountryCode:            "GB",
+				CountryOfIncorporation: "UK",
+				CountryOfRisk:          "gb",
+			},
+			warnings: []Violation{
+				{Field: "countryOfRisk", Message: `"gb" is not an ISO 3166-1 alpha-2 code`, Severity: SeverityWarning},
+				{Field: "countryOfIncorporation", Message: `"UK" is not an ISO 3166-1 alpha-2 code`, Severity: SeverityWarning},
+			},
+		},
+		"Location with invalid ISO code": {
+			concept: ConcordedConcept{
+				PrefUUID: "f8024a12-2d71-4f0e-996d-bcbc07df3921",
+				Type:     "Location",
+				ISO31661: "BEL",
+			},
+			warnings: []Violation{
+				{Field: "iso31661", Message: `"BEL" is not an ISO 3166-1 alpha-2 code`, Severity: SeverityWarning},
+			},
+		},
+		"Type without specific rules": {
+			concept: ConcordedConcept{
+				PrefUUID: "781bb463-dc53-4d3e-9d49-c48dc4cf6d55",
+				Type:     "Brand",
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			warnings, err := validateConcept(tc.concept)
+			assert.Equal(t, tc.warnings, warnings)
+			if tc.violations == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var validationErr *ValidationError
+			assert.True(t, errors.As(err, &validationErr))
+			assert.Equal(t, tc.concept.PrefUUID, validationErr.UUID)
+			assert.Equal(t, tc.concept.Type, validationErr.Type)
+			assert.Equal(t, tc.violations, validationErr.Violations)
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"time"
 
+	"github.com/Financial-Times/aggregate-concept-transformer/resilience"
 	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
 	"github.com/Financial-Times/go-logger"
 )
@@ -23,23 +24,28 @@ type Client interface {
 type RWClient struct {
 	address    *url.URL
 	httpClient *http.Client
+	resilient  *resilience.Client
 }
 
-func NewClient(address string) (Client, error) {
+// NewClient returns a client for the concordances store at address. Concordances are read following policy,
+// while health checks make a single attempt.
+func NewClient(address string, policy resilience.Policy) (Client, error) {
 	parsedURL, err := url.Parse(address)
 	if err != nil {
 		return nil, err
 	}
+	httpClient := &http.Client{
+		Timeout: time.Second * 5,
+	}
 	return &RWClient{
-		address: parsedURL,
-		httpClient: &http.Client{
-			Timeout: time.Second * 5,
-		},
+		address:    parsedURL,
+		httpClient: httpClient,
+		resilient:  resilience.NewClient("concordances-rw-neo4j", httpClient, policy),
 	}, nil
 }
 
 func (c *RWClient) GetConcordance(ctx context.Context, uuid string, bookmark string) ([]ConcordanceRecord, error) {
-	respBody, status, err := c.makeRequest(ctx, "GET", fmt.Sprintf("/concordances/%s", uuid), nil, bookmark)
+	respBody, status, err := c.makeRequest(ctx, c.resilient, "GET", fmt.Sprintf("/concordances/%s", uuid), nil, bookmark)
 	if err != nil {
 		logger.WithError(err).Error("Could not get concordances")
 		return nil, err
@@ -83,7 +89,7 @@ func (c *RWClient) Healthcheck() fthealth.Check {
 		Checker: func() (string, error) {
 			ctx, cancel := context.WithCancel(context.Background())
 			defer cancel()
-			_, status, err := c.makeRequest(ctx, "GET", "/__gtg", nil, "")
+			_, status, err := c.makeRequest(ctx, c.httpClient, "GET", "/__gtg", nil, "")
 			if err != nil {
 				errMsg := "failed to request gtg from concordances-rw-neo4j"
 				return errMsg, errors.New(errMsg)
@@ -92,12 +98,15 @@ func (c *RWClient) Healthcheck() fthealth.Check {
 				errMsg := "bad status from gtg for concordances-rw-neo4j"
 				return errMsg, errors.New(errMsg)
 			}
+			if err = c.resilient.BreakerErr(); err != nil {
+				return err.Error(), err
+			}
 			return "", nil
 		},
 	}
 }
 
-func (c *RWClient) makeRequest(ctx context.Context, method string, path string, body []byte, bookmark string) ([]byte, int, error) {
+func (c *RWClient) makeRequest(ctx context.Context, client resilience.Doer, method string, path string, body []byte, bookmark string) ([]byte, int, error) {
 	finalURL := *c.address
 	finalURL.Path = finalURL.Path + path
 
@@ -110,7 +119,7 @@ func (c *RWClient) makeRequest(ctx context.Context, method string, path string,
 		req.Header.Add("bookmark", bookmark)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, 0, err
 	}
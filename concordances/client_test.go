@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/Financial-Times/aggregate-concept-transformer/resilience"
 	"github.com/Financial-Times/go-logger"
 
 	"github.com/stretchr/testify/suite"
@@ -20,7 +21,7 @@ type RWTestSuite struct {
 }
 
 func (suite *RWTestSuite) SetupTest() {
-	client, err := NewClient("http://localhost")
+	client, err := NewClient("http://localhost", resilience.Policy{})
 	suite.Nil(err)
 	suite.client = client.(*RWClient)
 }
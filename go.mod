@@ -16,5 +16,6 @@ require (
 	github.com/rcrowley/go-metrics v0.0.0-20190706150252-9beb055b7962
 	github.com/sirupsen/logrus v1.2.0
 	github.com/stretchr/testify v1.6.1
+	golang.org/x/text v0.3.3
 	gopkg.in/jarcoal/httpmock.v1 v1.0.0-20181025172632-c463961d8bfe
 )
@@ -12,41 +12,77 @@ import (
 )
 
 type Client interface {
-	AddRecordToStream(ctx context.Context, updatedConcept []byte, conceptType string) error
+	AddRecordToStream(ctx context.Context, updatedConcept []byte, conceptType string, prefUUID string) error
+	PartitionKey(conceptType string, prefUUID string) string
 	Healthcheck() fthealth.Check
 }
 
 type KinesisClient struct {
-	streamName string
-	svc        *kinesis.Kinesis
+	streamName   string
+	svc          *kinesis.Kinesis
+	partitionKey string
 }
 
-func NewClient(streamName string, region string, arn string) (Client, error) {
+// NewClient returns a client that sends each record with its own PutRecord call, or a BatchProducer when
+// config has a BatchSize.
+func NewClient(streamName string, region string, arn string, config ProducerConfig) (Client, error) {
 	sess := session.Must(session.NewSession())
 	svc := kinesis.New(sess, &aws.Config{
 		Region:      aws.String(region),
 		Credentials: stscreds.NewCredentials(sess, arn, func(p *stscreds.AssumeRoleProvider) {}),
 	})
 
-	_, err := svc.DescribeStream(&kinesis.DescribeStreamInput{
-		StreamName: aws.String(streamName),
-	})
+	shards, err := describeShards(svc, streamName)
 	if err != nil {
 		logger.WithError(err).Error("Could not verify connection to Kinesis stream")
 		return &KinesisClient{}, err
 	}
 
-	return &KinesisClient{
-		streamName: streamName,
-		svc:        svc,
-	}, nil
+	client := &KinesisClient{
+		streamName:   streamName,
+		svc:          svc,
+		partitionKey: config.PartitionKey,
+	}
+	if config.BatchSize <= 0 {
+		return client, nil
+	}
+	putRecords := func(ctx context.Context, input *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+		return svc.PutRecordsWithContext(ctx, input)
+	}
+	return newBatchProducer(client, newShardMap(shards), config, putRecords), nil
+}
+
+// describeShards returns the shards of the stream, following the pages of the description.
+func describeShards(svc *kinesis.Kinesis, streamName string) ([]*kinesis.Shard, error) {
+	var shards []*kinesis.Shard
+	input := &kinesis.DescribeStreamInput{StreamName: aws.String(streamName)}
+	for {
+		output, err := svc.DescribeStream(input)
+		if err != nil {
+			return nil, err
+		}
+		if output == nil || output.StreamDescription == nil {
+			return shards, nil
+		}
+		shards = append(shards, output.StreamDescription.Shards...)
+		if !aws.BoolValue(output.StreamDescription.HasMoreShards) || len(shards) == 0 {
+			return shards, nil
+		}
+		input.ExclusiveStartShardId = shards[len(shards)-1].ShardId
+	}
+}
+
+// PartitionKey returns the partition key of the record for a concept, as chosen by the partition key strategy
+// of the client.
+func (c *KinesisClient) PartitionKey(conceptType string, prefUUID string) string {
+	return PartitionKey(c.partitionKey, conceptType, prefUUID)
 }
 
-func (c *KinesisClient) AddRecordToStream(ctx context.Context, updatedConcept []byte, conceptType string) error {
+func (c *KinesisClient) AddRecordToStream(ctx context.Context, updatedConcept []byte, conceptType string, prefUUID string) error {
 	putRecordInput := &kinesis.PutRecordInput{
 		Data:         updatedConcept,
 		StreamName:   aws.String(c.streamName),
-		PartitionKey: aws.String(conceptType),
+		PartitionKey: aws.String(c.PartitionKey(conceptType, prefUUID)),
 	}
 
 	if _, err := c.svc.PutRecordWithContext(ctx, putRecordInput); err != nil {
@@ -0,0 +1,354 @@
+package kinesis
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/big"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+// Partition key strategies
+const (
+	// PartitionByType uses the type of the concept, sending all concepts of a type to the same shard.
+	PartitionByType = "type"
+	// PartitionByPrefUUID uses the UUID of the concept.
+	PartitionByPrefUUID = "prefUUID"
+	// PartitionByHash uses a hash of the type and the UUID of the concept.
+	PartitionByHash = "hash"
+)
+
+// Limits of Kinesis on PutRecords calls and on the throughput of each shard
+const (
+	maxBatchRecords       = 500
+	maxBatchBytes         = 5 << 20
+	maxRecordBytes        = 1 << 20
+	shardRecordsPerSecond = 1000
+	shardBytesPerSecond   = 1 << 20
+)
+
+const (
+	defaultFlushInterval = 100 * time.Millisecond
+	retryBaseDelay       = 100 * time.Millisecond
+	putRecordsTimeout    = 10 * time.Second
+)
+
+// ProducerConfig sets how records are partitioned and batched.
+type ProducerConfig struct {
+	// PartitionKey is the partition key strategy, PartitionByType if empty.
+	PartitionKey string
+	// BatchSize is the maximum number of records sent in one PutRecords call, 0 to send each record with its
+	// own PutRecord call.
+	BatchSize int
+	// FlushInterval is how often the records collected are sent when there are fewer than BatchSize of them.
+	FlushInterval time.Duration
+	// MaxRetries is the number of times a record that failed in a PutRecords call is sent again.
+	MaxRetries int
+}
+
+// Validate checks the partition key strategy and that the batches fit the limits of Kinesis.
+func (c ProducerConfig) Validate() error {
+	switch c.PartitionKey {
+	case "", PartitionByType, PartitionByPrefUUID, PartitionByHash:
+	default:
+		return fmt.Errorf("kinesis producer: unknown partition key strategy %q", c.PartitionKey)
+	}
+	if c.BatchSize < 0 || c.BatchSize > maxBatchRecords {
+		return fmt.Errorf("kinesis producer: batch size must be between 0 and %d, got %d", maxBatchRecords, c.BatchSize)
+	}
+	if c.FlushInterval < 0 || c.MaxRetries < 0 {
+		return fmt.Errorf("kinesis producer: flush interval and retries cannot be negative: %+v", c)
+	}
+	return nil
+}
+
+// PartitionKey returns the partition key of the record for a concept under strategy. Every strategy gives all the
+// records of a concept the same key, so that they reach the same shard in order. The type is used for records
+// without a prefUUID.
+func PartitionKey(strategy string, conceptType string, prefUUID string) string {
+	if prefUUID == "" {
+		return conceptType
+	}
+	switch strategy {
+	case PartitionByPrefUUID:
+		return prefUUID
+	case PartitionByHash:
+		h := fnv.New64a()
+		h.Write([]byte(conceptType + "/" + prefUUID))
+		return fmt.Sprintf("%016x", h.Sum64())
+	default:
+		return conceptType
+	}
+}
+
+// BatchProducer collects the records of concurrent workers and sends them to the stream with PutRecords calls.
+// Records that fail are sent again with backoff, up to MaxRetries times, while the rest of the batch is done
+// with. Batches are sent while the next one is collected. A concept has at most one record in flight, and its
+// later records wait for it to be written or for a failed one to be retried, so that the records of a concept
+// are written in order. Records are held back while their shard is at its throughput limit.
+type BatchProducer struct {
+	*KinesisClient
+	config     ProducerConfig
+	shards     shardMap
+	limiter    *shardLimiter
+	putRecords func(ctx context.Context, input *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error)
+	now        func() time.Time
+	incoming   chan *pendingRecord
+	sent       chan sentBatch
+	queue      []*pendingRecord
+	inFlight   map[string]bool
+}
+
+// sentBatch is a batch whose PutRecords call has finished, with the records of it to retry.
+type sentBatch struct {
+	batch []*pendingRecord
+	retry []*pendingRecord
+}
+
+type pendingRecord struct {
+	entry     *kinesis.PutRecordsRequestEntry
+	concept   string
+	shard     string
+	size      int
+	attempts  int
+	notBefore time.Time
+	result    chan error
+}
+
+func newBatchProducer(client *KinesisClient, shards shardMap, config ProducerConfig, putRecords func(ctx context.Context, input *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error)) *BatchProducer {
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultFlushInterval
+	}
+	p := &BatchProducer{
+		KinesisClient: client,
+		config:        config,
+		shards:        shards,
+		limiter:       newShardLimiter(),
+		putRecords:    putRecords,
+		now:           time.Now,
+		incoming:      make(chan *pendingRecord),
+		sent:          make(chan sentBatch),
+		inFlight:      map[string]bool{},
+	}
+	go p.run()
+	return p
+}
+
+// AddRecordToStream adds the record to the next batch and waits for it to be written. A record whose context is
+// done before then may still be written.
+func (p *BatchProducer) AddRecordToStream(ctx context.Context, updatedConcept []byte, conceptType string, prefUUID string) error {
+	key := p.PartitionKey(conceptType, prefUUID)
+	r := &pendingRecord{
+		entry:   &kinesis.PutRecordsRequestEntry{Data: updatedConcept, PartitionKey: aws.String(key)},
+		concept: key,
+		shard:   p.shards.shardFor(key),
+		size:    len(updatedConcept) + len(key),
+		result:  make(chan error, 1),
+	}
+	if prefUUID != "" {
+		r.concept = prefUUID
+	}
+	if r.size > maxRecordBytes {
+		return fmt.Errorf("record of %d bytes is larger than the %d bytes Kinesis accepts", r.size, maxRecordBytes)
+	}
+
+	select {
+	case p.incoming <- r:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-r.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run sends a batch whenever BatchSize records are waiting, and every FlushInterval, for the life of the service.
+// Each batch is sent from its own goroutine, so that workers can add records while it is in flight.
+func (p *BatchProducer) run() {
+	ticker := time.NewTicker(p.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case r := <-p.incoming:
+			p.queue = append(p.queue, r)
+			if len(p.queue) < p.config.BatchSize {
+				continue
+			}
+		case s := <-p.sent:
+			for _, r := range s.batch {
+				delete(p.inFlight, r.concept)
+			}
+			// Failed records go back ahead of the later records of their concepts
+			p.queue = append(s.retry, p.queue...)
+			continue
+		case <-ticker.C:
+		}
+		p.flush()
+	}
+}
+
+func (p *BatchProducer) flush() {
+	var batch []*pendingRecord
+	batch, p.queue = p.nextBatch()
+	if len(batch) == 0 {
+		return
+	}
+	for _, r := range batch {
+		p.inFlight[r.concept] = true
+	}
+	go func() {
+		p.sent <- sentBatch{batch: batch, retry: p.send(batch)}
+	}()
+}
+
+// nextBatch takes the records that can be sent now from the queue, keeping the order of the records of each
+// concept and leaving the records of concepts that are in flight. The records left are returned in order.
+func (p *BatchProducer) nextBatch() (batch []*pendingRecord, rest []*pendingRecord) {
+	now := p.now()
+	held := map[string]bool{}
+	size := 0
+	for _, r := range p.queue {
+		if held[r.concept] || p.inFlight[r.concept] || len(batch) >= p.config.BatchSize || size+r.size > maxBatchBytes ||
+			r.notBefore.After(now) || !p.limiter.allow(r.shard, r.size, now) {
+			held[r.concept] = true
+			rest = append(rest, r)
+			continue
+		}
+		held[r.concept] = true
+		size += r.size
+		batch = append(batch, r)
+	}
+	return batch, rest
+}
+
+// send puts batch to the stream, hands the records that succeeded or ran out of retries their results, and
+// returns the records to retry.
+func (p *BatchProducer) send(batch []*pendingRecord) []*pendingRecord {
+	entries := make([]*kinesis.PutRecordsRequestEntry, len(batch))
+	for i, r := range batch {
+		entries[i] = r.entry
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), putRecordsTimeout)
+	defer cancel()
+	output, err := p.putRecords(ctx, &kinesis.PutRecordsInput{Records: entries, StreamName: aws.String(p.streamName)})
+
+	var retry []*pendingRecord
+	for i, r := range batch {
+		failure := err
+		if failure == nil {
+			failure = recordFailure(output, i)
+		}
+		if failure == nil {
+			r.result <- nil
+			continue
+		}
+		r.attempts++
+		if r.attempts > p.config.MaxRetries {
+			r.result <- fmt.Errorf("failed to put record to Kinesis stream %s after %d attempts: %w", p.streamName, r.attempts, failure)
+			continue
+		}
+		r.notBefore = p.now().Add(retryBaseDelay << uint(r.attempts-1))
+		retry = append(retry, r)
+	}
+	if len(retry) > 0 {
+		logger.WithField("records", len(batch)).WithField("retried", len(retry)).Warnf("Records failed to be put to Kinesis stream %s, retrying", p.streamName)
+	}
+	return retry
+}
+
+func recordFailure(output *kinesis.PutRecordsOutput, i int) error {
+	if output == nil || i >= len(output.Records) || output.Records[i] == nil {
+		return errors.New("no result returned for record")
+	}
+	result := output.Records[i]
+	if result.ErrorCode == nil {
+		return nil
+	}
+	return fmt.Errorf("%s: %s", aws.StringValue(result.ErrorCode), aws.StringValue(result.ErrorMessage))
+}
+
+// shardMap finds the open shard of the stream that a partition key maps to.
+type shardMap []shardRange
+
+type shardRange struct {
+	id    string
+	start *big.Int
+	end   *big.Int
+}
+
+func newShardMap(shards []*kinesis.Shard) shardMap {
+	var m shardMap
+	for _, shard := range shards {
+		if shard == nil || shard.HashKeyRange == nil {
+			continue
+		}
+		if shard.SequenceNumberRange != nil && shard.SequenceNumberRange.EndingSequenceNumber != nil {
+			// Closed by resharding
+			continue
+		}
+		start, okStart := new(big.Int).SetString(aws.StringValue(shard.HashKeyRange.StartingHashKey), 10)
+		end, okEnd := new(big.Int).SetString(aws.StringValue(shard.HashKeyRange.EndingHashKey), 10)
+		if !okStart || !okEnd {
+			continue
+		}
+		m = append(m, shardRange{id: aws.StringValue(shard.ShardId), start: start, end: end})
+	}
+	return m
+}
+
+// shardFor returns the shard whose hash key range holds the MD5 hash of partitionKey, as Kinesis maps records,
+// or "" when no shard is known for it.
+func (m shardMap) shardFor(partitionKey string) string {
+	sum := md5.Sum([]byte(partitionKey))
+	hashKey := new(big.Int).SetBytes(sum[:])
+	for _, r := range m {
+		if hashKey.Cmp(r.start) >= 0 && hashKey.Cmp(r.end) <= 0 {
+			return r.id
+		}
+	}
+	return ""
+}
+
+// shardLimiter keeps the records and bytes sent to each shard within its throughput limits, per second.
+type shardLimiter struct {
+	usage map[string]*shardUsage
+}
+
+type shardUsage struct {
+	second  time.Time
+	records int
+	bytes   int
+}
+
+func newShardLimiter() *shardLimiter {
+	return &shardLimiter{usage: map[string]*shardUsage{}}
+}
+
+// allow counts a record of size bytes against shard and returns true, unless it would take the shard over its
+// limits in the second of now. Records of unknown shards are always allowed.
+func (l *shardLimiter) allow(shard string, size int, now time.Time) bool {
+	if shard == "" {
+		return true
+	}
+	second := now.Truncate(time.Second)
+	u, ok := l.usage[shard]
+	if !ok || !u.second.Equal(second) {
+		u = &shardUsage{second: second}
+		l.usage[shard] = u
+	}
+	if u.records+1 > shardRecordsPerSecond || u.bytes+size > shardBytesPerSecond {
+		return false
+	}
+	u.records++
+	u.bytes += size
+	return true
+}
@@ -0,0 +1,246 @@
+package kinesis
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	logger.InitDefaultLogger("test")
+}
+
+type mockPutRecords struct {
+	mu    sync.Mutex
+	fail  func(call int, key string) *string
+	err   error
+	calls [][]string
+	// hold delays the calls holding the record of a partition key until its channel is closed, after the key has
+	// been sent to held.
+	hold map[string]chan struct{}
+	held chan string
+}
+
+func (m *mockPutRecords) put(ctx context.Context, input *kinesis.PutRecordsInput) (*kinesis.PutRecordsOutput, error) {
+	for _, entry := range input.Records {
+		if release, ok := m.hold[aws.StringValue(entry.PartitionKey)]; ok {
+			m.held <- aws.StringValue(entry.PartitionKey)
+			<-release
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	call := len(m.calls)
+	var keys []string
+	output := &kinesis.PutRecordsOutput{}
+	for _, entry := range input.Records {
+		key := aws.StringValue(entry.PartitionKey)
+		keys = append(keys, key)
+		result := &kinesis.PutRecordsResultEntry{ShardId: aws.String("shardId-000000000000")}
+		if m.fail != nil {
+			if code := m.fail(call, key); code != nil {
+				result = &kinesis.PutRecordsResultEntry{ErrorCode: code, ErrorMessage: aws.String("Rate exceeded for shard")}
+			}
+		}
+		output.Records = append(output.Records, result)
+	}
+	m.calls = append(m.calls, keys)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return output, nil
+}
+
+func (m *mockPutRecords) Calls() [][]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls
+}
+
+func addAll(p *BatchProducer, prefUUIDs ...string) map[string]error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := map[string]error{}
+	for _, prefUUID := range prefUUIDs {
+		wg.Add(1)
+		go func(prefUUID string) {
+			defer wg.Done()
+			err := p.AddRecordToStream(context.Background(), []byte(`["`+prefUUID+`"]`), "Person", prefUUID)
+			mu.Lock()
+			results[prefUUID] = err
+			mu.Unlock()
+		}(prefUUID)
+	}
+	wg.Wait()
+	return results
+}
+
+func newTestProducer(put *mockPutRecords, config ProducerConfig) *BatchProducer {
+	client := &KinesisClient{streamName: "concepts", partitionKey: PartitionByPrefUUID}
+	return newBatchProducer(client, nil, config, put.put)
+}
+
+func TestPartitionKey(t *testing.T) {
+	assert.Equal(t, "Person", PartitionKey(PartitionByType, "Person", "a"))
+	assert.Equal(t, "Person", PartitionKey("", "Person", "a"))
+	assert.Equal(t, "a", PartitionKey(PartitionByPrefUUID, "Person", "a"))
+	assert.Len(t, PartitionKey(PartitionByHash, "Person", "a"), 16)
+	assert.Equal(t, PartitionKey(PartitionByHash, "Person", "a"), PartitionKey(PartitionByHash, "Person", "a"))
+	assert.NotEqual(t, PartitionKey(PartitionByHash, "Person", "a"), PartitionKey(PartitionByHash, "Person", "b"))
+	assert.Equal(t, "Person", PartitionKey(PartitionByPrefUUID, "Person", ""), "records without a UUID should fall back to the type")
+}
+
+func TestProducerConfig_Validate(t *testing.T) {
+	assert.NoError(t, ProducerConfig{}.Validate())
+	assert.NoError(t, ProducerConfig{PartitionKey: PartitionByHash, BatchSize: 500, FlushInterval: time.Second, MaxRetries: 3}.Validate())
+	assert.Error(t, ProducerConfig{PartitionKey: "random"}.Validate())
+	assert.Error(t, ProducerConfig{BatchSize: 501}.Validate())
+	assert.Error(t, ProducerConfig{MaxRetries: -1}.Validate())
+}
+
+func TestBatchProducer_SendsFullBatch(t *testing.T) {
+	put := &mockPutRecords{}
+	p := newTestProducer(put, ProducerConfig{BatchSize: 3, FlushInterval: time.Hour})
+
+	results := addAll(p, "a", "b", "c")
+	assert.Equal(t, map[string]error{"a": nil, "b": nil, "c": nil}, results)
+	if assert.Len(t, put.Calls(), 1) {
+		assert.ElementsMatch(t, []string{"a", "b", "c"}, put.Calls()[0])
+	}
+}
+
+func TestBatchProducer_RetriesOnlyFailedRecords(t *testing.T) {
+	put := &mockPutRecords{fail: func(call int, key string) *string {
+		if call == 0 && key == "b" {
+			return aws.String("ProvisionedThroughputExceededException")
+		}
+		return nil
+	}}
+	p := newTestProducer(put, ProducerConfig{BatchSize: 3, FlushInterval: 10 * time.Millisecond, MaxRetries: 2})
+
+	results := addAll(p, "a", "b", "c")
+	assert.Equal(t, map[string]error{"a": nil, "b": nil, "c": nil}, results)
+	calls := put.Calls()
+	if assert.Len(t, calls, 2) {
+		assert.ElementsMatch(t, []string{"a", "b", "c"}, calls[0])
+		assert.Equal(t, []string{"b"}, calls[1])
+	}
+}
+
+func TestBatchProducer_GivesUpAfterMaxRetries(t *testing.T) {
+	put := &mockPutRecords{err: errors.New("stream unavailable")}
+	p := newTestProducer(put, ProducerConfig{BatchSize: 1, FlushInterval: 10 * time.Millisecond, MaxRetries: 1})
+
+	results := addAll(p, "a")
+	assert.EqualError(t, results["a"], "failed to put record to Kinesis stream concepts after 2 attempts: stream unavailable")
+	assert.Len(t, put.Calls(), 2)
+}
+
+func TestBatchProducer_SendsWhileBatchInFlight(t *testing.T) {
+	release := make(chan struct{})
+	put := &mockPutRecords{hold: map[string]chan struct{}{"a": release}, held: make(chan string, 1)}
+	p := newTestProducer(put, ProducerConfig{BatchSize: 1, FlushInterval: 10 * time.Millisecond})
+
+	held := make(chan map[string]error, 1)
+	go func() { held <- addAll(p, "a") }()
+	assert.Equal(t, "a", <-put.held)
+
+	results := addAll(p, "b")
+	assert.NoError(t, results["b"], "records should be sent while an earlier batch is in flight")
+	close(release)
+	assert.NoError(t, (<-held)["a"])
+	assert.Equal(t, [][]string{{"b"}, {"a"}}, put.Calls())
+}
+
+func TestBatchProducer_ContextDone(t *testing.T) {
+	p := newTestProducer(&mockPutRecords{}, ProducerConfig{BatchSize: 10, FlushInterval: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := p.AddRecordToStream(ctx, []byte(`["a"]`), "Person", "a")
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestBatchProducer_NextBatchKeepsConceptOrder(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	record := func(concept string, data string) *pendingRecord {
+		return &pendingRecord{entry: &kinesis.PutRecordsRequestEntry{Data: []byte(data)}, concept: concept, size: len(data)}
+	}
+	a1, a2, b1, c1 := record("a", "a1"), record("a", "a2"), record("b", "b1"), record("c", "c1")
+	p := &BatchProducer{config: ProducerConfig{BatchSize: 10}, limiter: newShardLimiter(), now: func() time.Time { return now }}
+
+	p.queue = []*pendingRecord{a1, a2, b1}
+	batch, rest := p.nextBatch()
+	assert.Equal(t, []*pendingRecord{a1, b1}, batch, "a call should hold one record of a concept")
+	assert.Equal(t, []*pendingRecord{a2}, rest)
+
+	a1.notBefore = now.Add(time.Second)
+	p.queue = []*pendingRecord{a1, a2, b1}
+	batch, rest = p.nextBatch()
+	assert.Equal(t, []*pendingRecord{b1}, batch, "later records should wait for a record being retried")
+	assert.Equal(t, []*pendingRecord{a1, a2}, rest)
+
+	a1.notBefore = time.Time{}
+	p.inFlight = map[string]bool{"a": true}
+	p.queue = []*pendingRecord{a2, b1}
+	batch, rest = p.nextBatch()
+	assert.Equal(t, []*pendingRecord{b1}, batch, "later records should wait for a record in flight")
+	assert.Equal(t, []*pendingRecord{a2}, rest)
+
+	p.inFlight = nil
+	p.config.BatchSize = 1
+	p.queue = []*pendingRecord{b1, c1}
+	batch, rest = p.nextBatch()
+	assert.Equal(t, []*pendingRecord{b1}, batch)
+	assert.Equal(t, []*pendingRecord{c1}, rest)
+}
+
+func TestShardMap(t *testing.T) {
+	half := new(big.Int).Lsh(big.NewInt(1), 127)
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+	m := newShardMap([]*kinesis.Shard{
+		{
+			ShardId:             aws.String("closed"),
+			HashKeyRange:        &kinesis.HashKeyRange{StartingHashKey: aws.String("0"), EndingHashKey: aws.String(max.String())},
+			SequenceNumberRange: &kinesis.SequenceNumberRange{StartingSequenceNumber: aws.String("1"), EndingSequenceNumber: aws.String("2")},
+		},
+		{
+			ShardId:      aws.String("low"),
+			HashKeyRange: &kinesis.HashKeyRange{StartingHashKey: aws.String("0"), EndingHashKey: aws.String(new(big.Int).Sub(half, big.NewInt(1)).String())},
+		},
+		{
+			ShardId:      aws.String("high"),
+			HashKeyRange: &kinesis.HashKeyRange{StartingHashKey: aws.String(half.String()), EndingHashKey: aws.String(max.String())},
+		},
+	})
+
+	assert.Len(t, m, 2, "closed shards should be left out")
+	// The MD5 hash of "a" starts with 0x0c, and of "b" with 0x92
+	assert.Equal(t, "low", m.shardFor("a"))
+	assert.Equal(t, "high", m.shardFor("b"))
+	assert.Equal(t, "", shardMap(nil).shardFor("a"))
+}
+
+func TestShardLimiter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newShardLimiter()
+
+	for i := 0; i < shardRecordsPerSecond; i++ {
+		assert.True(t, l.allow("shard", 1, now))
+	}
+	assert.False(t, l.allow("shard", 1, now), "a shard should take at most 1000 records a second")
+	assert.True(t, l.allow("other", 1, now))
+	assert.True(t, l.allow("shard", 1, now.Add(time.Second)))
+
+	assert.True(t, l.allow("big", shardBytesPerSecond, now))
+	assert.False(t, l.allow("big", 1, now), "a shard should take at most 1 MiB a second")
+	assert.True(t, l.allow("", shardBytesPerSecond*2, now), "records of unknown shards should not be limited")
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/Financial-Times/aggregate-concept-transformer/concept"
 	"github.com/Financial-Times/aggregate-concept-transformer/concordances"
 	"github.com/Financial-Times/aggregate-concept-transformer/kinesis"
+	"github.com/Financial-Times/aggregate-concept-transformer/resilience"
 	"github.com/Financial-Times/aggregate-concept-transformer/s3"
 	"github.com/Financial-Times/aggregate-concept-transformer/sqs"
 )
@@ -91,6 +92,48 @@ func main() {
 		Desc:   "Duration(seconds) to wait before timing out a request",
 		EnvVar: "HTTP_TIMEOUT",
 	})
+	httpRetries := app.Int(cli.IntOpt{
+		Name:   "httpRetries",
+		Value:  2,
+		Desc:   "Number of times a call to a writer, the purger or the concordances store is retried after an error or a 5xx status",
+		EnvVar: "HTTP_RETRIES",
+	})
+	httpRetryBaseDelay := app.Int(cli.IntOpt{
+		Name:   "httpRetryBaseDelay",
+		Value:  100,
+		Desc:   "Duration(milliseconds) to wait before the first retry, doubling for each further retry",
+		EnvVar: "HTTP_RETRY_BASE_DELAY",
+	})
+	httpRetryMaxDelay := app.Int(cli.IntOpt{
+		Name:   "httpRetryMaxDelay",
+		Value:  2000,
+		Desc:   "Maximum duration(milliseconds) to wait between retries",
+		EnvVar: "HTTP_RETRY_MAX_DELAY",
+	})
+	circuitBreakerThreshold := app.Int(cli.IntOpt{
+		Name:   "circuitBreakerThreshold",
+		Value:  5,
+		Desc:   "Number of consecutive failed calls to a writer, the purger or the concordances store that opens its circuit breaker, 0 to disable the breakers",
+		EnvVar: "CIRCUIT_BREAKER_THRESHOLD",
+	})
+	circuitBreakerCooldown := app.Int(cli.IntOpt{
+		Name:   "circuitBreakerCooldown",
+		Value:  30,
+		Desc:   "Duration(seconds) an open circuit breaker fails calls for before letting a trial call through",
+		EnvVar: "CIRCUIT_BREAKER_COOLDOWN",
+	})
+	elasticsearchBulkSize := app.Int(cli.IntOpt{
+		Name:   "elasticsearchBulkSize",
+		Value:  0,
+		Desc:   "Maximum number of concepts written to Elasticsearch in one bulk request, 0 to write each concept with its own request; experimental",
+		EnvVar: "ES_BULK_SIZE",
+	})
+	elasticsearchBulkFlushInterval := app.Int(cli.IntOpt{
+		Name:   "elasticsearchBulkFlushInterval",
+		Value:  100,
+		Desc:   "Duration(milliseconds) to wait for a bulk request to Elasticsearch to fill up before sending it",
+		EnvVar: "ES_BULK_FLUSH_INTERVAL",
+	})
 	waitTime := app.Int(cli.IntOpt{
 		Name:   "waitTime",
 		Value:  20,
@@ -121,6 +164,18 @@ func main() {
 		Desc:   "Address for the Varnish Purger application",
 		EnvVar: "VARNISH_PURGER_ADDRESS",
 	})
+	purgeCoalesceWindow := app.Int(cli.IntOpt{
+		Name:   "purgeCoalesceWindow",
+		Value:  0,
+		Desc:   "Duration(milliseconds) over which the Varnish purge targets of concurrent workers are collected and purged once each, 0 to purge the targets of each concept as soon as it is written",
+		EnvVar: "PURGE_COALESCE_WINDOW",
+	})
+	purgeBatchSize := app.Int(cli.IntOpt{
+		Name:   "purgeBatchSize",
+		Value:  50,
+		Desc:   "Maximum number of targets in one coalesced request to the Varnish purger",
+		EnvVar: "PURGE_BATCH_SIZE",
+	})
 	typesToPurgeFromPublicEndpoints := app.Strings(cli.StringsOpt{
 		Name:   "typesToPurgeFromPublicEndpoints",
 		Value:  []string{"Person", "Brand", "Organisation", "PublicCompany"},
@@ -144,11 +199,128 @@ func main() {
 		Desc:   "AWS region the Kinesis stream is located",
 		EnvVar: "KINESIS_REGION",
 	})
+	kinesisPartitionKey := app.String(cli.StringOpt{
+		Name:   "kinesisPartitionKey",
+		Value:  kinesis.PartitionByType,
+		Desc:   "Partition key of the Kinesis records of a concept: type, prefUUID or hash",
+		EnvVar: "KINESIS_PARTITION_KEY",
+	})
+	kinesisBatchSize := app.Int(cli.IntOpt{
+		Name:   "kinesisBatchSize",
+		Value:  0,
+		Desc:   "Maximum number of records sent to Kinesis in one PutRecords call, up to 500, 0 to send each record with its own PutRecord call",
+		EnvVar: "KINESIS_BATCH_SIZE",
+	})
+	kinesisFlushInterval := app.Int(cli.IntOpt{
+		Name:   "kinesisFlushInterval",
+		Value:  100,
+		Desc:   "Duration(milliseconds) between PutRecords calls when fewer records than the batch size are waiting",
+		EnvVar: "KINESIS_FLUSH_INTERVAL",
+	})
+	kinesisMaxRetries := app.Int(cli.IntOpt{
+		Name:   "kinesisMaxRetries",
+		Value:  3,
+		Desc:   "Number of times a record that failed in a PutRecords call is sent again",
+		EnvVar: "KINESIS_MAX_RETRIES",
+	})
 	eventsQueueURL := app.String(cli.StringOpt{
 		Name:   "eventsQueueURL",
 		Desc:   "Url of AWS SQS queue to send concept notifications to",
 		EnvVar: "EVENTS_QUEUE_URL",
 	})
+	eventFormat := app.String(cli.StringOpt{
+		Name:   "eventFormat",
+		Value:  sqs.EventFormatCompatible,
+		Desc:   "Format of the messages sent to the events queue: legacy, compatible, envelope or cloudevents",
+		EnvVar: "EVENT_FORMAT",
+	})
+	deadLetterQueueURL := app.String(cli.StringOpt{
+		Name:   "deadLetterQueueURL",
+		Desc:   "Url of AWS SQS queue to forward concept updates that cannot be processed to",
+		EnvVar: "DEAD_LETTER_QUEUE_URL",
+	})
+	maxReceiveCount := app.Int(cli.IntOpt{
+		Name:   "maxReceiveCount",
+		Value:  0,
+		Desc:   "Number of times a concept update failing with a transient error is received before it is dead-lettered, 0 to retry it until it expires",
+		EnvVar: "MAX_RECEIVE_COUNT",
+	})
+	mergePolicyFile := app.String(cli.StringOpt{
+		Name:   "mergePolicyFile",
+		Desc:   "Path to a JSON file overriding the default per-field merge policy",
+		EnvVar: "MERGE_POLICY_FILE",
+	})
+	aliasRulesFile := app.String(cli.StringOpt{
+		Name:   "aliasRulesFile",
+		Desc:   "Path to a JSON file overriding the default per-type alias normalisation rules",
+		EnvVar: "ALIAS_RULES_FILE",
+	})
+	scopeNoteRulesFile := app.String(cli.StringOpt{
+		Name:   "scopeNoteRulesFile",
+		Desc:   "Path to a JSON file replacing the default ordered scope note rules",
+		EnvVar: "SCOPE_NOTE_RULES_FILE",
+	})
+	primaryAuthorities := app.Strings(cli.StringsOpt{
+		Name:   "primaryAuthorities",
+		Value:  concept.DefaultPrimaryAuthorities(),
+		Desc:   "Authorities whose concept becomes the canonical concept, highest priority first",
+		EnvVar: "PRIMARY_AUTHORITIES",
+	})
+	canonicalCacheSize := app.Int(cli.IntOpt{
+		Name:   "canonicalCacheSize",
+		Value:  10000,
+		Desc:   "Maximum number of relationship UUIDs whose canonical UUID is cached, 0 to disable the cache",
+		EnvVar: "CANONICAL_CACHE_SIZE",
+	})
+	canonicalCacheTTL := app.Int(cli.IntOpt{
+		Name:   "canonicalCacheTTL",
+		Value:  60,
+		Desc:   "Duration(seconds) a cached canonical UUID is used for",
+		EnvVar: "CANONICAL_CACHE_TTL",
+	})
+	hierarchyCycleGuardDepth := app.Int(cli.IntOpt{
+		Name:   "hierarchyCycleGuardDepth",
+		Value:  0,
+		Desc:   "Maximum number of broader/parent relationships walked to check that a concept does not create a cycle, 0 to disable the check",
+		EnvVar: "HIERARCHY_CYCLE_GUARD_DEPTH",
+	})
+	hashStore := app.String(cli.StringOpt{
+		Name:   "hashStore",
+		Value:  concept.HashStoreNone,
+		Desc:   "Where the hash of the last written version of each concept is kept, so that unchanged concepts are not written again: none, memory or file",
+		EnvVar: "HASH_STORE",
+	})
+	hashStoreFile := app.String(cli.StringOpt{
+		Name:   "hashStoreFile",
+		Desc:   "Path to the file the hashes are kept in when hashStore is file",
+		EnvVar: "HASH_STORE_FILE",
+	})
+	checkpointTTL := app.Int(cli.IntOpt{
+		Name:   "checkpointTTL",
+		Value:  3600,
+		Desc:   "Duration(seconds) the progress of a concept whose sinks partly failed is kept for a retry to resume from, 0 to retry every sink",
+		EnvVar: "CHECKPOINT_TTL",
+	})
+	sinkPipelineFile := app.String(cli.StringOpt{
+		Name:   "sinkPipelineFile",
+		Desc:   "Path to a JSON file replacing the default pipeline of sinks concepts are sent to",
+		EnvVar: "SINK_PIPELINE_FILE",
+	})
+	typeHierarchyFile := app.String(cli.StringOpt{
+		Name:   "typeHierarchyFile",
+		Desc:   "Path to a JSON file adding to or overriding the default concept type hierarchy",
+		EnvVar: "TYPE_HIERARCHY_FILE",
+	})
+	purgeCascadeFile := app.String(cli.StringOpt{
+		Name:   "purgeCascadeFile",
+		Desc:   "Path to a JSON file overriding the default per-type related concepts purged from Varnish along with a concept",
+		EnvVar: "PURGE_CASCADE_FILE",
+	})
+	routingTableFile := app.String(cli.StringOpt{
+		Name:   "routingTableFile",
+		Desc:   "Path to a JSON file overriding the default per-type URL paths, writers and public endpoint purging",
+		EnvVar: "ROUTING_TABLE_FILE",
+	})
 	requestLoggingOn := app.Bool(cli.BoolOpt{
 		Name:   "requestLoggingOn",
 		Value:  true,
@@ -166,17 +338,47 @@ func main() {
 		logger.InitLogger(*appSystemCode, *logLevel)
 
 		logger.WithFields(log.Fields{
-			"ES_WRITER_ADDRESS":       *elasticsearchWriterAddress,
-			"CONCORDANCES_RW_ADDRESS": *concordancesReaderAddress,
-			"NEO_WRITER_ADDRESS":      *neoWriterAddress,
-			"VARNISH_PURGER_ADDRESS":  *varnishPurgerAddress,
-			"BUCKET_REGION":           *bucketRegion,
-			"BUCKET_NAME":             *bucketName,
-			"SQS_REGION":              *sqsRegion,
-			"CONCEPTS_QUEUE_URL":      *conceptUpdatesQueueURL,
-			"EVENTS_QUEUE_URL":        *eventsQueueURL,
-			"LOG_LEVEL":               *logLevel,
-			"KINESIS_STREAM_NAME":     *kinesisStreamName,
+			"ES_WRITER_ADDRESS":           *elasticsearchWriterAddress,
+			"CONCORDANCES_RW_ADDRESS":     *concordancesReaderAddress,
+			"NEO_WRITER_ADDRESS":          *neoWriterAddress,
+			"VARNISH_PURGER_ADDRESS":      *varnishPurgerAddress,
+			"BUCKET_REGION":               *bucketRegion,
+			"BUCKET_NAME":                 *bucketName,
+			"SQS_REGION":                  *sqsRegion,
+			"CONCEPTS_QUEUE_URL":          *conceptUpdatesQueueURL,
+			"EVENTS_QUEUE_URL":            *eventsQueueURL,
+			"EVENT_FORMAT":                *eventFormat,
+			"DEAD_LETTER_QUEUE_URL":       *deadLetterQueueURL,
+			"MAX_RECEIVE_COUNT":           *maxReceiveCount,
+			"HTTP_RETRIES":                *httpRetries,
+			"HTTP_RETRY_BASE_DELAY":       *httpRetryBaseDelay,
+			"HTTP_RETRY_MAX_DELAY":        *httpRetryMaxDelay,
+			"CIRCUIT_BREAKER_THRESHOLD":   *circuitBreakerThreshold,
+			"CIRCUIT_BREAKER_COOLDOWN":    *circuitBreakerCooldown,
+			"ES_BULK_SIZE":                *elasticsearchBulkSize,
+			"ES_BULK_FLUSH_INTERVAL":      *elasticsearchBulkFlushInterval,
+			"PURGE_COALESCE_WINDOW":       *purgeCoalesceWindow,
+			"PURGE_BATCH_SIZE":            *purgeBatchSize,
+			"LOG_LEVEL":                   *logLevel,
+			"KINESIS_STREAM_NAME":         *kinesisStreamName,
+			"KINESIS_PARTITION_KEY":       *kinesisPartitionKey,
+			"KINESIS_BATCH_SIZE":          *kinesisBatchSize,
+			"KINESIS_FLUSH_INTERVAL":      *kinesisFlushInterval,
+			"KINESIS_MAX_RETRIES":         *kinesisMaxRetries,
+			"MERGE_POLICY_FILE":           *mergePolicyFile,
+			"ALIAS_RULES_FILE":            *aliasRulesFile,
+			"SCOPE_NOTE_RULES_FILE":       *scopeNoteRulesFile,
+			"TYPE_HIERARCHY_FILE":         *typeHierarchyFile,
+			"PURGE_CASCADE_FILE":          *purgeCascadeFile,
+			"ROUTING_TABLE_FILE":          *routingTableFile,
+			"PRIMARY_AUTHORITIES":         *primaryAuthorities,
+			"CANONICAL_CACHE_SIZE":        *canonicalCacheSize,
+			"CANONICAL_CACHE_TTL":         *canonicalCacheTTL,
+			"HIERARCHY_CYCLE_GUARD_DEPTH": *hierarchyCycleGuardDepth,
+			"HASH_STORE":                  *hashStore,
+			"HASH_STORE_FILE":             *hashStoreFile,
+			"SINK_PIPELINE_FILE":          *sinkPipelineFile,
+			"CHECKPOINT_TTL":              *checkpointTTL,
 		}).Info("Starting app with arguments")
 
 		if *bucketName == "" {
@@ -205,6 +407,26 @@ func main() {
 	}
 
 	app.Action = func() {
+		resiliencePolicy := resilience.Policy{
+			Retries:          *httpRetries,
+			BaseDelay:        time.Millisecond * time.Duration(*httpRetryBaseDelay),
+			MaxDelay:         time.Millisecond * time.Duration(*httpRetryMaxDelay),
+			FailureThreshold: *circuitBreakerThreshold,
+			Cooldown:         time.Second * time.Duration(*circuitBreakerCooldown),
+		}
+		if err := resiliencePolicy.Validate(); err != nil {
+			logger.WithError(err).Fatal("Error validating retry and circuit breaker settings")
+		}
+		producerConfig := kinesis.ProducerConfig{
+			PartitionKey:  *kinesisPartitionKey,
+			BatchSize:     *kinesisBatchSize,
+			FlushInterval: time.Millisecond * time.Duration(*kinesisFlushInterval),
+			MaxRetries:    *kinesisMaxRetries,
+		}
+		if err := producerConfig.Validate(); err != nil {
+			logger.WithError(err).Fatal("Error validating Kinesis producer settings")
+		}
+
 		s3Client, err := s3.NewClient(*bucketName, *bucketRegion)
 		if err != nil {
 			logger.WithError(err).Fatal("Error creating S3 client")
@@ -215,21 +437,65 @@ func main() {
 			logger.WithError(err).Fatal("Error creating concept updates SQS client")
 		}
 
-		eventsQueueURL, err := sqs.NewClient(*sqsRegion, *eventsQueueURL, *sqsEndpoint, *messagesToProcess, *visibilityTimeout, *waitTime)
+		eventsQueueURL, err := sqs.NewEventsClient(*sqsRegion, *eventsQueueURL, *sqsEndpoint, *messagesToProcess, *visibilityTimeout, *waitTime, *eventFormat)
 		if err != nil {
 			logger.WithError(err).Fatal("Error creating concept events SQS client")
 		}
 
-		concordancesClient, err := concordances.NewClient(*concordancesReaderAddress)
+		var deadLetterClient sqs.DeadLetterClient
+		if *deadLetterQueueURL != "" {
+			deadLetterClient, err = sqs.NewDeadLetterClient(*sqsRegion, *deadLetterQueueURL, *sqsEndpoint)
+			if err != nil {
+				logger.WithError(err).Fatal("Error creating dead-letter SQS client")
+			}
+		}
+
+		concordancesClient, err := concordances.NewClient(*concordancesReaderAddress, resiliencePolicy)
 		if err != nil {
 			logger.WithError(err).Fatal("Error creating Concordances client")
 		}
 
-		kinesisClient, err := kinesis.NewClient(*kinesisStreamName, *kinesisRegion, *crossAccountRoleARN)
+		kinesisClient, err := kinesis.NewClient(*kinesisStreamName, *kinesisRegion, *crossAccountRoleARN, producerConfig)
 		if err != nil {
 			logger.WithError(err).Fatal("Error creating Kinesis client")
 		}
 
+		mergePolicy, err := concept.LoadMergePolicy(*mergePolicyFile)
+		if err != nil {
+			logger.WithError(err).Fatal("Error loading merge policy")
+		}
+		aliasRules, err := concept.LoadAliasRules(*aliasRulesFile)
+		if err != nil {
+			logger.WithError(err).Fatal("Error loading alias rules")
+		}
+		scopeNoteRules, err := concept.LoadScopeNoteRules(*scopeNoteRulesFile)
+		if err != nil {
+			logger.WithError(err).Fatal("Error loading scope note rules")
+		}
+		typeHierarchy, err := concept.LoadTypeHierarchy(*typeHierarchyFile)
+		if err != nil {
+			logger.WithError(err).Fatal("Error loading type hierarchy")
+		}
+		purgeCascade, err := concept.LoadPurgeCascade(*purgeCascadeFile)
+		if err != nil {
+			logger.WithError(err).Fatal("Error loading purge cascade")
+		}
+		routingTable, err := concept.LoadRoutingTable(*routingTableFile)
+		if err != nil {
+			logger.WithError(err).Fatal("Error loading routing table")
+		}
+		if err = concept.PrimaryAuthorities(*primaryAuthorities).Validate(); err != nil {
+			logger.WithError(err).Fatal("Error validating primary authorities")
+		}
+		conceptHashStore, err := concept.OpenHashStore(*hashStore, *hashStoreFile)
+		if err != nil {
+			logger.WithError(err).Fatal("Error opening hash store")
+		}
+		sinkPipeline, err := concept.LoadSinkPipeline(*sinkPipelineFile)
+		if err != nil {
+			logger.WithError(err).Fatal("Error loading sink pipeline")
+		}
+
 		feedback := make(chan bool)
 		done := make(chan struct{})
 
@@ -248,7 +514,24 @@ func main() {
 			defaultHTTPClient(maxWorkers),
 			feedback,
 			done,
-			requestTimeout)
+			requestTimeout,
+			concept.WithMergePolicy(mergePolicy),
+			concept.WithAliasRules(aliasRules),
+			concept.WithScopeNoteRules(scopeNoteRules),
+			concept.WithTypeHierarchy(typeHierarchy),
+			concept.WithPurgeCascade(purgeCascade),
+			concept.WithRoutingTable(routingTable),
+			concept.WithPrimaryAuthorities(*primaryAuthorities),
+			concept.WithCanonicalCache(*canonicalCacheSize, time.Second*time.Duration(*canonicalCacheTTL)),
+			concept.WithHierarchyCycleGuard(*hierarchyCycleGuardDepth),
+			concept.WithHashStore(conceptHashStore),
+			concept.WithSinkPipeline(sinkPipeline),
+			concept.WithCheckpointTTL(time.Second*time.Duration(*checkpointTTL)),
+			concept.WithDeadLetterQueue(deadLetterClient),
+			concept.WithMaxReceiveCount(*maxReceiveCount),
+			concept.WithResilience(resiliencePolicy),
+			concept.WithElasticsearchBulk(*elasticsearchBulkSize, time.Millisecond*time.Duration(*elasticsearchBulkFlushInterval)),
+			concept.WithPurgeCoalescing(time.Millisecond*time.Duration(*purgeCoalesceWindow), *purgeBatchSize))
 
 		handler := concept.NewHandler(svc, requestTimeout)
 		hs := concept.NewHealthService(svc, *appSystemCode, *appName, *port, appDescription)
@@ -0,0 +1,115 @@
+package resilience
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+)
+
+// CircuitOpenError is returned for calls to a destination while its circuit breaker is open.
+type CircuitOpenError struct {
+	Destination string
+	Failures    int
+	Until       time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker for %s is open after %d consecutive failures, until %s", e.Destination, e.Failures, e.Until.Format(time.RFC3339))
+}
+
+// breaker opens after threshold consecutive failed calls, failing calls fast for cooldown. Once the cooldown
+// has passed a single trial call is let through: the breaker closes if it succeeds and opens again if it fails.
+// A threshold of zero or less disables the breaker.
+type breaker struct {
+	mu          sync.Mutex
+	destination string
+	threshold   int
+	cooldown    time.Duration
+	now         func() time.Time
+	failures    int
+	open        bool
+	openedAt    time.Time
+	trial       bool
+}
+
+func newBreaker(destination string, threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		destination: destination,
+		threshold:   threshold,
+		cooldown:    cooldown,
+		now:         time.Now,
+	}
+}
+
+// allow returns a *CircuitOpenError unless a call can be made.
+func (b *breaker) allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return nil
+	}
+	if b.trial || b.now().Before(b.openedAt.Add(b.cooldown)) {
+		return b.openErr()
+	}
+	b.trial = true
+	return nil
+}
+
+// record counts the outcome of a call that allow let through.
+func (b *breaker) record(failed bool) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if !failed {
+		if b.open {
+			logger.WithField("destination", b.destination).Info("Circuit breaker closed")
+		}
+		b.failures = 0
+		b.open = false
+		return
+	}
+	b.failures++
+	if b.open || b.failures >= b.threshold {
+		if !b.open {
+			logger.WithField("destination", b.destination).
+				WithField("failures", b.failures).
+				Warn("Circuit breaker opened")
+		}
+		b.open = true
+		b.openedAt = b.now()
+	}
+}
+
+// release gives up a call that allow let through without counting its outcome.
+func (b *breaker) release() {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+func (b *breaker) err() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open || !b.now().Before(b.openedAt.Add(b.cooldown)) {
+		return nil
+	}
+	return b.openErr()
+}
+
+func (b *breaker) openErr() error {
+	return &CircuitOpenError{Destination: b.destination, Failures: b.failures, Until: b.openedAt.Add(b.cooldown)}
+}
@@ -0,0 +1,169 @@
+package resilience
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+)
+
+// Doer makes HTTP requests, as *http.Client does.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Policy sets how calls to a destination are retried, and when its circuit breaker opens. The zero Policy makes
+// a single attempt and never opens the breaker.
+type Policy struct {
+	// Retries is the number of attempts made after the first when a call fails with an error or a 5xx status.
+	Retries int
+	// BaseDelay is the delay before the first retry, which doubles for each retry up to MaxDelay. Each delay
+	// is jittered down by up to half.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// FailureThreshold is the number of consecutive failed calls that opens the breaker, 0 to disable it.
+	FailureThreshold int
+	// Cooldown is how long the breaker stays open before letting a trial call through.
+	Cooldown time.Duration
+}
+
+// Validate checks that no setting of the policy is negative, and that the delays of a policy with retries do not
+// cap every delay below BaseDelay.
+func (p Policy) Validate() error {
+	if p.Retries < 0 || p.BaseDelay < 0 || p.MaxDelay < 0 || p.FailureThreshold < 0 || p.Cooldown < 0 {
+		return fmt.Errorf("resilience policy: settings cannot be negative: %+v", p)
+	}
+	if p.Retries > 0 && p.MaxDelay < p.BaseDelay {
+		return fmt.Errorf("resilience policy: max delay %v cannot be less than base delay %v", p.MaxDelay, p.BaseDelay)
+	}
+	return nil
+}
+
+// Client makes calls to a single destination through a Doer, retrying failed calls with exponential backoff
+// and failing fast while the circuit breaker of the destination is open.
+type Client struct {
+	destination string
+	doer        Doer
+	policy      Policy
+	breaker     *breaker
+	sleep       func(ctx context.Context, d time.Duration) error
+	jitter      func(d time.Duration) time.Duration
+}
+
+func NewClient(destination string, doer Doer, policy Policy) *Client {
+	return &Client{
+		destination: destination,
+		doer:        doer,
+		policy:      policy,
+		breaker:     newBreaker(destination, policy.FailureThreshold, policy.Cooldown),
+		sleep:       sleep,
+		jitter:      jitter,
+	}
+}
+
+// Do sends req, retrying it when it fails with an error or a 5xx status. The response of the last attempt is
+// returned. Requests whose body cannot be rewound are only attempted once.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	var resp *http.Response
+	var err error
+	for attempt := 0; ; attempt++ {
+		resp, err = c.doer.Do(req)
+		if req.Context().Err() != nil {
+			// The caller gave up, which says nothing about the destination
+			c.breaker.release()
+			return resp, err
+		}
+		if !failed(resp, err) || attempt >= c.policy.Retries || !rewind(req) {
+			break
+		}
+
+		delay := c.backoff(attempt)
+		logger.WithField("destination", c.destination).
+			WithField("attempt", attempt+1).
+			Warnf("Call to %s failed, retrying in %v: %v", req.URL, delay, failure(resp, err))
+		discard(resp)
+		if sleepErr := c.sleep(req.Context(), delay); sleepErr != nil {
+			c.breaker.release()
+			return nil, sleepErr
+		}
+	}
+	c.breaker.record(failed(resp, err))
+	return resp, err
+}
+
+// BreakerErr returns a *CircuitOpenError while the circuit breaker of the destination is open, and nil once it
+// is closed or ready to let a trial call through.
+func (c *Client) BreakerErr() error {
+	return c.breaker.err()
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.policy.BaseDelay << uint(attempt)
+	if delay > c.policy.MaxDelay || delay < c.policy.BaseDelay {
+		delay = c.policy.MaxDelay
+	}
+	return c.jitter(delay)
+}
+
+func failed(resp *http.Response, err error) bool {
+	return err != nil || resp.StatusCode >= http.StatusInternalServerError
+}
+
+func failure(resp *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("status %d", resp.StatusCode)
+}
+
+// rewind resets the body of req for another attempt, returning false when it cannot be reset.
+func rewind(req *http.Request) bool {
+	if req.Body == nil || req.Body == http.NoBody {
+		return true
+	}
+	if req.GetBody == nil {
+		return false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return false
+	}
+	req.Body = body
+	return true
+}
+
+func discard(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
@@ -0,0 +1,221 @@
+package resilience
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	logger.InitDefaultLogger("test")
+}
+
+type mockDoer struct {
+	statuses []int
+	errs     []error
+	bodies   []string
+	calls    int
+}
+
+func (m *mockDoer) Do(req *http.Request) (*http.Response, error) {
+	i := m.calls
+	m.calls++
+	if req.Body != nil {
+		body, _ := ioutil.ReadAll(req.Body)
+		m.bodies = append(m.bodies, string(body))
+	}
+	if i < len(m.errs) && m.errs[i] != nil {
+		return nil, m.errs[i]
+	}
+	status := http.StatusOK
+	if i < len(m.statuses) {
+		status = m.statuses[i]
+	}
+	return &http.Response{StatusCode: status, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+}
+
+func newTestClient(doer Doer, policy Policy) (*Client, *[]time.Duration) {
+	var delays []time.Duration
+	c := NewClient("writer", doer, policy)
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	c.jitter = func(d time.Duration) time.Duration { return d }
+	return c, &delays
+}
+
+func TestClient_Do_Retries(t *testing.T) {
+	timeout := errors.New("i/o timeout")
+	testCases := map[string]struct {
+		statuses       []int
+		errs           []error
+		expectedStatus int
+		expectedErr    error
+		expectedCalls  int
+		expectedDelays []time.Duration
+	}{
+		"Success": {
+			expectedStatus: http.StatusOK,
+			expectedCalls:  1,
+		},
+		"Retried until success": {
+			statuses:       []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			expectedStatus: http.StatusOK,
+			expectedCalls:  3,
+			expectedDelays: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond},
+		},
+		"Timeout retried": {
+			errs:           []error{timeout},
+			expectedStatus: http.StatusOK,
+			expectedCalls:  2,
+			expectedDelays: []time.Duration{100 * time.Millisecond},
+		},
+		"Retries exhausted": {
+			statuses:       []int{500, 500, 500, 500, 500},
+			expectedStatus: http.StatusInternalServerError,
+			expectedCalls:  4,
+			expectedDelays: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 250 * time.Millisecond},
+		},
+		"Client error not retried": {
+			statuses:       []int{http.StatusNotFound},
+			expectedStatus: http.StatusNotFound,
+			expectedCalls:  1,
+		},
+		"Error returned once retries exhausted": {
+			errs:           []error{timeout, timeout, timeout, timeout},
+			expectedErr:    timeout,
+			expectedCalls:  4,
+			expectedDelays: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 250 * time.Millisecond},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			doer := &mockDoer{statuses: tc.statuses, errs: tc.errs}
+			c, delays := newTestClient(doer, Policy{Retries: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: 250 * time.Millisecond})
+			req, _ := http.NewRequest("GET", "http://writer/things/a", nil)
+
+			resp, err := c.Do(req)
+			if tc.expectedErr != nil {
+				assert.Equal(t, tc.expectedErr, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+			}
+			assert.Equal(t, tc.expectedCalls, doer.calls)
+			assert.Equal(t, tc.expectedDelays, *delays)
+		})
+	}
+}
+
+func TestClient_Do_ResendsBody(t *testing.T) {
+	doer := &mockDoer{statuses: []int{http.StatusServiceUnavailable, http.StatusOK}}
+	c, _ := newTestClient(doer, Policy{Retries: 1})
+	req, _ := http.NewRequest("PUT", "http://writer/things/a", bytes.NewReader([]byte(`{"prefUUID":"a"}`)))
+
+	resp, err := c.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{`{"prefUUID":"a"}`, `{"prefUUID":"a"}`}, doer.bodies)
+}
+
+func TestClient_Do_BodyThatCannotBeRewoundNotRetried(t *testing.T) {
+	doer := &mockDoer{statuses: []int{http.StatusServiceUnavailable, http.StatusOK}}
+	c, _ := newTestClient(doer, Policy{Retries: 1})
+	req, _ := http.NewRequest("PUT", "http://writer/things/a", ioutil.NopCloser(strings.NewReader("body")))
+
+	resp, err := c.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, 1, doer.calls)
+}
+
+func TestClient_Do_CancelledContextStopsRetries(t *testing.T) {
+	doer := &mockDoer{statuses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}}
+	c, _ := newTestClient(doer, Policy{Retries: 3, FailureThreshold: 1, Cooldown: time.Minute})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://writer/things/a", nil)
+
+	_, err := c.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, doer.calls)
+	assert.NoError(t, c.BreakerErr(), "calls given up by the caller should not open the breaker")
+}
+
+func TestClient_Do_CircuitBreaker(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	doer := &mockDoer{statuses: []int{500, 500, 500, 500, 200, 500, 200}}
+	c, _ := newTestClient(doer, Policy{FailureThreshold: 2, Cooldown: time.Minute})
+	c.breaker.now = func() time.Time { return now }
+	get := func() (*http.Response, error) {
+		req, _ := http.NewRequest("GET", "http://writer/things/a", nil)
+		return c.Do(req)
+	}
+
+	_, err := get()
+	assert.NoError(t, err)
+	assert.NoError(t, c.BreakerErr())
+	_, err = get()
+	assert.NoError(t, err)
+
+	var openErr *CircuitOpenError
+	assert.True(t, errors.As(c.BreakerErr(), &openErr), "breaker should open after 2 failures")
+	_, err = get()
+	assert.EqualError(t, err, "circuit breaker for writer is open after 2 consecutive failures, until 2020-01-01T00:01:00Z")
+	assert.Equal(t, 2, doer.calls, "calls should fail fast while the breaker is open")
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, c.BreakerErr(), "breaker should be ready for a trial call after the cooldown")
+	resp, err := get()
+	assert.NoError(t, err)
+	assert.Equal(t, 500, resp.StatusCode)
+	assert.Error(t, c.BreakerErr(), "failed trial call should open the breaker again")
+
+	now = now.Add(time.Minute)
+	_, err = get()
+	assert.NoError(t, err)
+	assert.Error(t, c.BreakerErr())
+
+	now = now.Add(time.Minute)
+	resp, err = get()
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, c.BreakerErr(), "successful trial call should close the breaker")
+	_, err = get()
+	assert.NoError(t, err)
+	assert.NoError(t, c.BreakerErr(), "a single failure should not open the closed breaker")
+}
+
+func TestBreaker_SingleTrialCall(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := newBreaker("writer", 1, time.Minute)
+	b.now = func() time.Time { return now }
+
+	assert.NoError(t, b.allow())
+	b.record(true)
+	assert.Error(t, b.allow())
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, b.allow())
+	assert.Error(t, b.allow(), "only one trial call should be let through")
+	b.release()
+	assert.NoError(t, b.allow(), "a released trial call should let another through")
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	assert.NoError(t, Policy{Retries: 2, BaseDelay: time.Second, MaxDelay: time.Minute, FailureThreshold: 5, Cooldown: time.Minute}.Validate())
+	assert.NoError(t, Policy{}.Validate())
+	assert.Error(t, Policy{Retries: -1}.Validate())
+	assert.EqualError(t, Policy{Retries: 2, BaseDelay: time.Second}.Validate(), "resilience policy: max delay 0s cannot be less than base delay 1s")
+	assert.NoError(t, Policy{BaseDelay: time.Second}.Validate(), "the delays of a policy without retries are never used")
+}
@@ -8,6 +8,7 @@ import (
 
 	"fmt"
 	"strconv"
+	"time"
 
 	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
 	"github.com/Financial-Times/go-logger"
@@ -30,6 +31,7 @@ type NotificationClient struct {
 	sqs          *sqs.SQS
 	listenParams sqs.ReceiveMessageInput
 	queueUrl     string
+	eventFormat  string
 }
 
 func NewClient(awsRegion string, queueURL string, endpoint string, messagesToProcess int, visibilityTimeout int, waitTime int) (Client, error) {
@@ -44,6 +46,7 @@ func NewClient(awsRegion string, queueURL string, endpoint string, messagesToPro
 		MaxNumberOfMessages: aws.Int64(int64(messagesToProcess)),
 		VisibilityTimeout:   aws.Int64(int64(visibilityTimeout)),
 		WaitTimeSeconds:     aws.Int64(int64(waitTime)),
+		AttributeNames:      []*string{aws.String(sqs.MessageSystemAttributeNameApproximateReceiveCount)},
 	}
 
 	conf := &aws.Config{
@@ -74,15 +77,34 @@ func (c *NotificationClient) ListenAndServeQueue(ctx context.Context) []ConceptU
 	return getNotificationsFromMessages(messages.Messages)
 }
 
+// NewEventsClient returns a client of the events queue that sends events in eventFormat.
+func NewEventsClient(awsRegion string, queueURL string, endpoint string, messagesToProcess int, visibilityTimeout int, waitTime int, eventFormat string) (Client, error) {
+	if err := ValidateEventFormat(eventFormat); err != nil {
+		return nil, err
+	}
+	client, err := NewClient(awsRegion, queueURL, endpoint, messagesToProcess, visibilityTimeout, waitTime)
+	if err != nil {
+		return client, err
+	}
+	client.(*NotificationClient).eventFormat = eventFormat
+	return client, nil
+}
+
+// SendEvents sends messages to the queue in the event format of the client. Events are sent in the legacy format
+// by a client that was not created with NewEventsClient.
 func (c *NotificationClient) SendEvents(ctx context.Context, messages []Event) error {
 	if c.queueUrl == "" {
 		return nil
 	}
+	sentAt := time.Now()
 	var entries []*sqs.SendMessageBatchRequestEntry
 
 	for i, msg := range messages {
 
-		jsonBytes, _ := json.Marshal(msg)
+		jsonBytes, err := EncodeEvent(c.eventFormat, msg, eventID(msg, i), sentAt)
+		if err != nil {
+			return err
+		}
 
 		entries = append(entries, &sqs.SendMessageBatchRequestEntry{
 			MessageBody: aws.String(string(jsonBytes)),
@@ -111,6 +133,11 @@ func (c *NotificationClient) SendEvents(ctx context.Context, messages []Event) e
 	return nil
 }
 
+// eventID identifies the event at index i of a batch, the same for every attempt to send the batch.
+func eventID(msg Event, i int) string {
+	return msg.TransactionID + "_" + msg.ConceptUUID + "_" + strconv.Itoa(i)
+}
+
 func (c *NotificationClient) RemoveMessageFromQueue(ctx context.Context, receiptHandle *string) error {
 	deleteParams := sqs.DeleteMessageInput{
 		QueueUrl:      aws.String(c.queueUrl),
@@ -153,11 +180,14 @@ func getNotificationsFromMessages(messages []*sqs.Message) []ConceptUpdate {
 		}
 
 		bookmark := msgRecord.Records[0].Bookmark
+		receiveCount, _ := strconv.Atoi(aws.StringValue(message.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount]))
 
 		notifications = append(notifications, ConceptUpdate{
 			UUID:          strings.Replace(key, "/", "-", 4),
 			Bookmark:      bookmark, //no need to verify via regex, because neo4j might change the pattern..
 			ReceiptHandle: receiptHandle,
+			MessageID:     aws.StringValue(message.MessageId),
+			ReceiveCount:  receiveCount,
 		})
 	}
 
@@ -0,0 +1,53 @@
+package sqs
+
+import (
+	"context"
+	"encoding/json"
+
+	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
+	"github.com/Financial-Times/go-logger"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// DeadLetterClient forwards concept updates that cannot be processed to a dead-letter queue.
+type DeadLetterClient interface {
+	SendDeadLetter(ctx context.Context, letter DeadLetter) error
+	Healthcheck() fthealth.Check
+}
+
+type DeadLetterQueueClient struct {
+	NotificationClient
+}
+
+func NewDeadLetterClient(awsRegion string, queueURL string, endpoint string) (DeadLetterClient, error) {
+	client, err := NewClient(awsRegion, queueURL, endpoint, 0, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &DeadLetterQueueClient{NotificationClient: *client.(*NotificationClient)}, nil
+}
+
+func (c *DeadLetterQueueClient) SendDeadLetter(ctx context.Context, letter DeadLetter) error {
+	body, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	input := &sqs.SendMessageInput{
+		QueueUrl:    aws.String(c.queueUrl),
+		MessageBody: aws.String(string(body)),
+	}
+	if _, err = c.sqs.SendMessageWithContext(ctx, input); err != nil {
+		logger.WithError(err).WithUUID(letter.UUID).Error("Error sending message to dead-letter SQS queue")
+		return err
+	}
+	return nil
+}
+
+func (c *DeadLetterQueueClient) Healthcheck() fthealth.Check {
+	check := c.NotificationClient.Healthcheck()
+	check.BusinessImpact = "Concept updates that cannot be processed will stay on the concepts queue until they expire"
+	check.Name = "Check connectivity to dead-letter SQS queue"
+	check.TechnicalSummary = `Cannot connect to the dead-letter SQS queue. If this check fails, check that Amazon SQS is available`
+	return check
+}
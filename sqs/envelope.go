@@ -0,0 +1,195 @@
+package sqs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Formats of the messages sent to the events queue
+const (
+	// EventFormatLegacy sends the Event as it is, without a schema version.
+	EventFormatLegacy = "legacy"
+	// EventFormatCompatible sends the Event as it is, with the fields of the envelope added to it, so that
+	// consumers can move to the envelope while the others keep reading the legacy fields.
+	EventFormatCompatible = "compatible"
+	// EventFormatEnvelope sends an EventEnvelope.
+	EventFormatEnvelope = "envelope"
+	// EventFormatCloudEvents sends a CloudEvents 1.0 JSON event whose data is the concept and concordance of
+	// the envelope.
+	EventFormatCloudEvents = "cloudevents"
+)
+
+// EventSchemaVersion is the version of the envelope, raised whenever a field is removed or its meaning changes.
+const EventSchemaVersion = "1"
+
+const cloudEventsSource = "/aggregate-concept-transformer"
+
+// EventKind is what happened to the concept of an event.
+type EventKind string
+
+const (
+	ConceptUpdated     EventKind = "ConceptUpdated"
+	ConcordanceAdded   EventKind = "ConcordanceAdded"
+	ConcordanceRemoved EventKind = "ConcordanceRemoved"
+)
+
+// eventKinds maps the event types reported by the Neo4j writer to their kinds.
+var eventKinds = map[string]EventKind{
+	"Concept Updated":     ConceptUpdated,
+	"Concordance Added":   ConcordanceAdded,
+	"Concordance Removed": ConcordanceRemoved,
+}
+
+// cloudEventTypes are the CloudEvents types of the event kinds.
+var cloudEventTypes = map[EventKind]string{
+	ConceptUpdated:     "com.ft.upp.concept.updated",
+	ConcordanceAdded:   "com.ft.upp.concordance.added",
+	ConcordanceRemoved: "com.ft.upp.concordance.removed",
+}
+
+// EventEnvelope is an event of the events queue with a typed kind and a schema version.
+type EventEnvelope struct {
+	SchemaVersion string            `json:"schemaVersion"`
+	ID            string            `json:"id"`
+	Kind          EventKind         `json:"kind"`
+	Time          time.Time         `json:"time"`
+	TransactionID string            `json:"transactionID"`
+	Concept       EventConcept      `json:"concept"`
+	Concordance   *EventConcordance `json:"concordance,omitempty"`
+}
+
+// EventConcept is the concept an event is about.
+type EventConcept struct {
+	Type          string `json:"type"`
+	UUID          string `json:"uuid"`
+	AggregateHash string `json:"aggregateHash,omitempty"`
+}
+
+// EventConcordance is the concordance added or removed by a concordance event.
+type EventConcordance struct {
+	OldID string `json:"oldID"`
+	NewID string `json:"newID"`
+}
+
+type compatibleEvent struct {
+	Event
+	SchemaVersion string    `json:"schemaVersion"`
+	ID            string    `json:"id"`
+	Kind          EventKind `json:"kind"`
+	Time          time.Time `json:"time"`
+}
+
+type cloudEvent struct {
+	SpecVersion     string         `json:"specversion"`
+	ID              string         `json:"id"`
+	Source          string         `json:"source"`
+	Type            string         `json:"type"`
+	Subject         string         `json:"subject"`
+	Time            time.Time      `json:"time"`
+	DataContentType string         `json:"datacontenttype"`
+	SchemaVersion   string         `json:"schemaversion"`
+	TransactionID   string         `json:"transactionid"`
+	Data            cloudEventData `json:"data"`
+}
+
+type cloudEventData struct {
+	Concept     EventConcept      `json:"concept"`
+	Concordance *EventConcordance `json:"concordance,omitempty"`
+}
+
+// ValidateEventFormat checks that format is one of the event formats.
+func ValidateEventFormat(format string) error {
+	switch format {
+	case EventFormatLegacy, EventFormatCompatible, EventFormatEnvelope, EventFormatCloudEvents:
+		return nil
+	}
+	return fmt.Errorf("unknown event format %q", format)
+}
+
+// NewEventEnvelope wraps e, sent at t, in an envelope with the given id. The kind is read from the event type of
+// the details of e; event types that are not known have their spaces removed, e.g. ConceptDeleted.
+func NewEventEnvelope(e Event, id string, t time.Time) EventEnvelope {
+	details := eventDetails(e.EventDetails)
+	kind, ok := eventKinds[details.eventType()]
+	if !ok {
+		kind = EventKind(strings.ReplaceAll(details.eventType(), " ", ""))
+	}
+	envelope := EventEnvelope{
+		SchemaVersion: EventSchemaVersion,
+		ID:            id,
+		Kind:          kind,
+		Time:          t.UTC(),
+		TransactionID: e.TransactionID,
+		Concept:       EventConcept{Type: e.ConceptType, UUID: e.ConceptUUID, AggregateHash: e.AggregateHash},
+	}
+	if details.OldID != "" || details.NewID != "" {
+		envelope.Concordance = &EventConcordance{OldID: details.OldID, NewID: details.NewID}
+	}
+	return envelope
+}
+
+// EncodeEvent returns the message body of e, sent at t, in format. An empty format is EventFormatLegacy.
+func EncodeEvent(format string, e Event, id string, t time.Time) ([]byte, error) {
+	switch format {
+	case "", EventFormatLegacy:
+		return json.Marshal(e)
+	case EventFormatCompatible:
+		envelope := NewEventEnvelope(e, id, t)
+		return json.Marshal(compatibleEvent{
+			Event:         e,
+			SchemaVersion: envelope.SchemaVersion,
+			ID:            envelope.ID,
+			Kind:          envelope.Kind,
+			Time:          envelope.Time,
+		})
+	case EventFormatEnvelope:
+		return json.Marshal(NewEventEnvelope(e, id, t))
+	case EventFormatCloudEvents:
+		envelope := NewEventEnvelope(e, id, t)
+		eventType, ok := cloudEventTypes[envelope.Kind]
+		if !ok {
+			eventType = "com.ft.upp." + strings.ToLower(string(envelope.Kind))
+		}
+		return json.Marshal(cloudEvent{
+			SpecVersion:     "1.0",
+			ID:              envelope.ID,
+			Source:          cloudEventsSource,
+			Type:            eventType,
+			Subject:         envelope.Concept.UUID,
+			Time:            envelope.Time,
+			DataContentType: "application/json",
+			SchemaVersion:   envelope.SchemaVersion,
+			TransactionID:   envelope.TransactionID,
+			Data:            cloudEventData{Concept: envelope.Concept, Concordance: envelope.Concordance},
+		})
+	}
+	return nil, fmt.Errorf("unknown event format %q", format)
+}
+
+// rawEventDetails reads the details of an event whether they are a ConceptEvent, a ConcordanceEvent or the
+// details decoded from the response of the Neo4j writer, which name the event type "type".
+type rawEventDetails struct {
+	Type      string `json:"type"`
+	EventType string `json:"eventType"`
+	OldID     string `json:"oldID"`
+	NewID     string `json:"newID"`
+}
+
+func eventDetails(details interface{}) rawEventDetails {
+	var raw rawEventDetails
+	data, err := json.Marshal(details)
+	if err != nil {
+		return raw
+	}
+	_ = json.Unmarshal(data, &raw)
+	return raw
+}
+
+func (d rawEventDetails) eventType() string {
+	if d.EventType != "" {
+		return d.EventType
+	}
+	return d.Type
+}
@@ -0,0 +1,90 @@
+package sqs
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var sentAt = time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+var concordanceEvent = Event{
+	ConceptType:   "Person",
+	ConceptUUID:   "new",
+	AggregateHash: "1234567890",
+	TransactionID: "tid_test",
+	EventDetails:  map[string]interface{}{"type": "Concordance Added", "oldID": "old", "newID": "new"},
+}
+
+func TestNewEventEnvelope(t *testing.T) {
+	envelope := NewEventEnvelope(concordanceEvent, "tid_test_new_0", sentAt)
+	assert.Equal(t, EventEnvelope{
+		SchemaVersion: EventSchemaVersion,
+		ID:            "tid_test_new_0",
+		Kind:          ConcordanceAdded,
+		Time:          sentAt,
+		TransactionID: "tid_test",
+		Concept:       EventConcept{Type: "Person", UUID: "new", AggregateHash: "1234567890"},
+		Concordance:   &EventConcordance{OldID: "old", NewID: "new"},
+	}, envelope)
+
+	envelope = NewEventEnvelope(Event{ConceptUUID: "uuid", EventDetails: ConceptEvent{Type: "Concept Updated"}}, "id", sentAt)
+	assert.Equal(t, ConceptUpdated, envelope.Kind)
+	assert.Nil(t, envelope.Concordance)
+
+	envelope = NewEventEnvelope(Event{ConceptUUID: "uuid", EventDetails: ConcordanceEvent{Type: "Concordance Removed", OldID: "old", NewID: "new"}}, "id", sentAt)
+	assert.Equal(t, ConcordanceRemoved, envelope.Kind)
+	assert.Equal(t, &EventConcordance{OldID: "old", NewID: "new"}, envelope.Concordance)
+
+	envelope = NewEventEnvelope(Event{ConceptUUID: "uuid", EventDetails: map[string]interface{}{"type": "Concept Deleted"}}, "id", sentAt)
+	assert.Equal(t, EventKind("ConceptDeleted"), envelope.Kind)
+}
+
+func TestEncodeEvent(t *testing.T) {
+	legacy := `{"type": "Person", "uuid": "new", "aggregateHash": "1234567890", "transactionID": "tid_test",
+		"eventDetails": {"type": "Concordance Added", "oldID": "old", "newID": "new"}}`
+
+	for _, format := range []string{"", EventFormatLegacy} {
+		body, err := EncodeEvent(format, concordanceEvent, "tid_test_new_0", sentAt)
+		assert.NoError(t, err)
+		assert.JSONEq(t, legacy, string(body), format)
+	}
+
+	body, err := EncodeEvent(EventFormatCompatible, concordanceEvent, "tid_test_new_0", sentAt)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type": "Person", "uuid": "new", "aggregateHash": "1234567890", "transactionID": "tid_test",
+		"eventDetails": {"type": "Concordance Added", "oldID": "old", "newID": "new"},
+		"schemaVersion": "1", "id": "tid_test_new_0", "kind": "ConcordanceAdded", "time": "2020-01-01T12:00:00Z"}`, string(body))
+	var old Event
+	assert.NoError(t, json.Unmarshal(body, &old))
+	assert.Equal(t, "new", old.ConceptUUID, "consumers of the legacy shape should still read compatible events")
+
+	body, err = EncodeEvent(EventFormatEnvelope, concordanceEvent, "tid_test_new_0", sentAt)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"schemaVersion": "1", "id": "tid_test_new_0", "kind": "ConcordanceAdded", "time": "2020-01-01T12:00:00Z",
+		"transactionID": "tid_test", "concept": {"type": "Person", "uuid": "new", "aggregateHash": "1234567890"},
+		"concordance": {"oldID": "old", "newID": "new"}}`, string(body))
+
+	body, err = EncodeEvent(EventFormatCloudEvents, concordanceEvent, "tid_test_new_0", sentAt)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"specversion": "1.0", "id": "tid_test_new_0", "source": "/aggregate-concept-transformer",
+		"type": "com.ft.upp.concordance.added", "subject": "new", "time": "2020-01-01T12:00:00Z",
+		"datacontenttype": "application/json", "schemaversion": "1", "transactionid": "tid_test",
+		"data": {"concept": {"type": "Person", "uuid": "new", "aggregateHash": "1234567890"}, "concordance": {"oldID": "old", "newID": "new"}}}`, string(body))
+
+	_, err = EncodeEvent("xml", concordanceEvent, "id", sentAt)
+	assert.EqualError(t, err, `unknown event format "xml"`)
+}
+
+func TestValidateEventFormat(t *testing.T) {
+	for _, format := range []string{EventFormatLegacy, EventFormatCompatible, EventFormatEnvelope, EventFormatCloudEvents} {
+		assert.NoError(t, ValidateEventFormat(format))
+	}
+	assert.EqualError(t, ValidateEventFormat(""), `unknown event format ""`)
+}
+
+func TestEventID(t *testing.T) {
+	assert.Equal(t, "tid_test_new_1", eventID(concordanceEvent, 1))
+}
@@ -1,9 +1,14 @@
 package sqs
 
+import "time"
+
 type ConceptUpdate struct {
 	UUID          string
 	Bookmark      string
 	ReceiptHandle *string
+	MessageID     string
+	// ReceiveCount is the number of times SQS has handed out the message, including this one.
+	ReceiveCount int
 }
 
 //SQS Message Format
@@ -51,3 +56,21 @@ type ConcordanceEvent struct {
 	OldID string `json:"oldID"`
 	NewID string `json:"newID"`
 }
+
+//Dead letters
+type DeadLetter struct {
+	UUID         string    `json:"uuid"`
+	Bookmark     string    `json:"bookmark,omitempty"`
+	MessageID    string    `json:"messageID,omitempty"`
+	ErrorClass   string    `json:"errorClass"`
+	Error        string    `json:"error"`
+	ReceiveCount int       `json:"receiveCount"`
+	Attempts     []Attempt `json:"attempts"`
+}
+
+type Attempt struct {
+	Time         time.Time `json:"time"`
+	ReceiveCount int       `json:"receiveCount"`
+	ErrorClass   string    `json:"errorClass"`
+	Error        string    `json:"error"`
+}